@@ -0,0 +1,32 @@
+package catalog
+
+// Descriptions of the check codes compiled into the program. These are used
+// when the catalog does not describe a check.
+var builtinDescriptions = map[string]string{
+	"AA-001": "Value set violation",
+	"AA-002": "Illegal concept identifier",
+	"AA-003": "Inconsistency between PK and source value",
+	"AA-004": "Unexpected fact",
+	"AA-005": "Incorrect concept id vocabulary",
+	"AA-006": "Violate inclusion criteria",
+	"BA-001": "Missing Data",
+	"BA-002": "No matching concepts",
+	"BA-003": "Missing Expected Concept",
+	"BA-004": "Insufficient facts for visits",
+	"CA-001": "Future event",
+	"CA-002": "Past event",
+	"CA-003": "Pre-birth fact",
+	"CA-004": "Post-death fact",
+	"CA-005": "Unexpected change in number of records between data cycles",
+	"CA-006": "Unexpected change in missingness of a field between data cycles",
+	"CA-007": "Identification of entity outliers",
+	"CA-008": "Identifcation of specific dates with high number of facts",
+	"CA-009": "Identifcation of sudden change in distribution of facts",
+	"CA-010": "Total number of records is too low",
+	"CA-011": "Implausible Numerical Values",
+	"CA-012": "Unexpected field distribution",
+	"CA-013": "Unexpected most frequent values",
+	"CA-014": "Inconsistency of null values between source values and concept ids",
+	"CA-015": "Inconsistency of most frequent values between source values and concept ids",
+	"CB-001": "Unexpected fact to patient ratio",
+}
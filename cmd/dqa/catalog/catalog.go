@@ -0,0 +1,477 @@
+// Package catalog provides the DQA check catalog. The catalog describes each
+// check code and the thresholds used to resolve conflicts between issues.
+// It can be loaded from a local checkout of the Data-Quality-Analysis
+// repository, from a cached snapshot or fetched from GitHub.
+package catalog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PEDSnet/tools/cmd/dqa/uni"
+)
+
+const (
+	owner = "PEDSnet"
+
+	catalogRepo = "Data-Quality-Analysis"
+	catalogPath = "DQA_Catalog/"
+
+	conflictRepo             = "Data-Quality-Results"
+	conflictAssociationsPath = "SecondaryReports/ConflictResolution/conflict_associations.csv"
+)
+
+// Sources of a catalog.
+const (
+	SourceBuiltin = "builtin"
+	SourceGitHub  = "github"
+	SourceDir     = "dir"
+)
+
+// Matches the check code and alias in the name of a catalog file,
+// e.g. CA-005_num_records.csv.
+var checkFileRe = regexp.MustCompile(`^([A-Z][A-Z]-\d{3})_(.*)\.csv$`)
+
+// CodeRe matches check codes in the current format. Codes in the legacy
+// format are migrated using the migrate-codes command.
+var CodeRe = regexp.MustCompile(`^[A-Z][A-Z]-\d{3}$`)
+
+// Threshold is the range of expected values of a check for a table and field.
+type Threshold struct {
+	Table string `json:"table"`
+	Field string `json:"field"`
+	Lower int    `json:"lower"`
+	Upper int    `json:"upper"`
+}
+
+// Check is a check in the catalog.
+type Check struct {
+	Code        string       `json:"code"`
+	Alias       string       `json:"alias,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Thresholds  []*Threshold `json:"thresholds,omitempty"`
+}
+
+// Threshold returns the threshold for the table and field.
+func (c *Check) Threshold(table, field string) *Threshold {
+	for _, t := range c.Thresholds {
+		if t.Table == table && t.Field == field {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// Catalog is the set of checks by code.
+type Catalog struct {
+	// Source the catalog was loaded from.
+	Source string `json:"source"`
+
+	// Commit of the catalog repository the catalog was loaded from.
+	Commit string `json:"commit,omitempty"`
+
+	// Time the catalog was loaded from the source.
+	Loaded time.Time `json:"loaded"`
+
+	// Associations maps the code of a check to the code of the issue whose
+	// conflicts are resolved using the thresholds of the check.
+	Associations map[string]string `json:"associations,omitempty"`
+
+	Checks map[string]*Check `json:"checks"`
+}
+
+// Check returns the check for the code or nil if it does not exist.
+func (c *Catalog) Check(code string) *Check {
+	return c.Checks[code]
+}
+
+// Codes returns the sorted check codes.
+func (c *Catalog) Codes() []string {
+	codes := make([]string, 0, len(c.Checks))
+
+	for code := range c.Checks {
+		codes = append(codes, code)
+	}
+
+	sort.Strings(codes)
+
+	return codes
+}
+
+// Description returns the description of a check code.
+func (c *Catalog) Description(code string) string {
+	if ch, ok := c.Checks[code]; ok {
+		return ch.Description
+	}
+
+	return ""
+}
+
+// conflictChecks returns the checks whose thresholds are used for conflicts
+// of the issue code. A check without an association applies to its own code.
+func (c *Catalog) conflictChecks(code string) []*Check {
+	var checks []*Check
+
+	for _, k := range c.Codes() {
+		target := k
+
+		if t, ok := c.Associations[k]; ok {
+			target = t
+		}
+
+		if target == code && len(c.Checks[k].Thresholds) > 0 {
+			checks = append(checks, c.Checks[k])
+		}
+	}
+
+	return checks
+}
+
+// HasThresholds returns true if thresholds are defined for conflicts of
+// the issue code.
+func (c *Catalog) HasThresholds(code string) bool {
+	return len(c.conflictChecks(code)) > 0
+}
+
+// Threshold returns the threshold used to resolve conflicts of the issue
+// code for the table and field.
+func (c *Catalog) Threshold(code, table, field string) *Threshold {
+	for _, ch := range c.conflictChecks(code) {
+		if t := ch.Threshold(table, field); t != nil {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// add adds a check to the catalog merging it with an existing check.
+func (c *Catalog) add(ch *Check) {
+	x, ok := c.Checks[ch.Code]
+
+	if !ok {
+		c.Checks[ch.Code] = ch
+		return
+	}
+
+	if ch.Alias != "" {
+		x.Alias = ch.Alias
+	}
+
+	if ch.Description != "" {
+		x.Description = ch.Description
+	}
+
+	x.Thresholds = append(x.Thresholds, ch.Thresholds...)
+}
+
+// Builtin returns the catalog of check codes and descriptions that is
+// compiled into the program. It does not contain any thresholds.
+func Builtin() *Catalog {
+	c := &Catalog{
+		Source: SourceBuiltin,
+		Checks: make(map[string]*Check, len(builtinDescriptions)),
+	}
+
+	for code, desc := range builtinDescriptions {
+		c.Checks[code] = &Check{
+			Code:        code,
+			Description: desc,
+		}
+	}
+
+	return c
+}
+
+// newCatalog returns a catalog seeded with the builtin checks.
+func newCatalog(source string) *Catalog {
+	c := Builtin()
+	c.Source = source
+	c.Loaded = time.Now().UTC()
+	c.Associations = make(map[string]string)
+	return c
+}
+
+// parseFile parses a file in the catalog directory. Files named after a
+// check contain thresholds, other files with a `check_code` column
+// describe the checks.
+func (c *Catalog) parseFile(name string, content []byte) error {
+	if m := checkFileRe.FindStringSubmatch(name); m != nil {
+		thresholds, err := parseThresholds(m[1], content)
+		if err != nil {
+			return err
+		}
+
+		c.add(&Check{
+			Code:       m[1],
+			Alias:      m[2],
+			Thresholds: thresholds,
+		})
+
+		return nil
+	}
+
+	if strings.HasSuffix(strings.ToLower(name), ".csv") {
+		return c.parseInventory(content)
+	}
+
+	return nil
+}
+
+// parseInventory parses a file describing the checks. Files without a
+// `check_code` column are ignored.
+func (c *Catalog) parseInventory(content []byte) error {
+	cr := csv.NewReader(uni.New(bytes.NewReader(content)))
+
+	head, err := cr.Read()
+	if err != nil {
+		return nil
+	}
+
+	code, alias, desc := -1, -1, -1
+
+	for i, col := range head {
+		switch strings.Replace(strings.ToLower(strings.TrimSpace(col)), " ", "_", -1) {
+		case "check_code", "issue_code":
+			code = i
+		case "check_alias", "alias":
+			alias = i
+		case "check_type", "check_description", "issue_description", "description":
+			desc = i
+		}
+	}
+
+	if code == -1 {
+		return nil
+	}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		ch := &Check{
+			Code: strings.TrimSpace(row[code]),
+		}
+
+		if !CodeRe.MatchString(ch.Code) {
+			continue
+		}
+
+		if alias >= 0 {
+			ch.Alias = strings.TrimSpace(row[alias])
+		}
+
+		if desc >= 0 {
+			ch.Description = strings.TrimSpace(row[desc])
+		}
+
+		c.add(ch)
+	}
+
+	return nil
+}
+
+// parseAssociations parses the conflict associations file.
+func (c *Catalog) parseAssociations(content []byte) error {
+	cr := csv.NewReader(uni.New(bytes.NewReader(content)))
+
+	if _, err := cr.Read(); err != nil {
+		return err
+	}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		c.Associations[row[1]] = row[0]
+	}
+
+	return nil
+}
+
+// parseThresholds parses the thresholds in a catalog file.
+func parseThresholds(code string, content []byte) ([]*Threshold, error) {
+	cr := csv.NewReader(uni.New(bytes.NewReader(content)))
+
+	head, err := cr.Read()
+	if err != nil {
+		return nil, nil
+	}
+
+	hlen := len(head)
+	if hlen != 5 && hlen != 6 {
+		return nil, fmt.Errorf("[%s] expected 5 or 6 columns, got %d", code, hlen)
+	}
+
+	var thresholds []*Threshold
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		t := &Threshold{
+			Table: strings.ToLower(row[1]),
+		}
+
+		if hlen == 5 {
+			t.Field = strings.ToLower(row[2])
+			t.Lower, _ = strconv.Atoi(row[3])
+			t.Upper, _ = strconv.Atoi(row[4])
+		} else {
+			t.Field = strings.ToLower(strings.Join([]string{row[2], row[3]}, ","))
+			t.Lower, _ = strconv.Atoi(row[4])
+			t.Upper, _ = strconv.Atoi(row[5])
+		}
+
+		thresholds = append(thresholds, t)
+	}
+
+	return thresholds, nil
+}
+
+// LoadDir loads the catalog from a local checkout of the catalog repository.
+// The conflict associations are read from the associations file if it is
+// not empty.
+func LoadDir(dir, associations string) (*Catalog, error) {
+	c := newCatalog(SourceDir)
+
+	fis, err := ioutil.ReadDir(filepath.Join(dir, catalogPath))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading catalog directory: %s", err)
+	}
+
+	for _, fi := range fis {
+		if fi.IsDir() {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, catalogPath, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.parseFile(fi.Name(), b); err != nil {
+			return nil, err
+		}
+	}
+
+	if associations != "" {
+		b, err := ioutil.ReadFile(associations)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading conflict associations: %s", err)
+		}
+
+		if err := c.parseAssociations(b); err != nil {
+			return nil, err
+		}
+	}
+
+	c.Commit = gitCommit(dir)
+
+	return c, nil
+}
+
+// gitCommit returns the commit checked out in a git repository. An empty
+// string is returned if the directory is not a repository.
+func gitCommit(dir string) string {
+	gitDir := filepath.Join(dir, ".git")
+
+	b, err := ioutil.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+
+	head := strings.TrimSpace(string(b))
+
+	// Detached head.
+	if !strings.HasPrefix(head, "ref: ") {
+		return head
+	}
+
+	ref := strings.TrimPrefix(head, "ref: ")
+
+	if b, err := ioutil.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref))); err == nil {
+		return strings.TrimSpace(string(b))
+	}
+
+	// The ref may only exist in the packed refs.
+	b, err = ioutil.ReadFile(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		toks := strings.Fields(line)
+
+		if len(toks) == 2 && toks[1] == ref {
+			return toks[0]
+		}
+	}
+
+	return ""
+}
+
+// ReadFile reads a catalog snapshot.
+func ReadFile(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var c Catalog
+
+	if err := json.NewDecoder(f).Decode(&c); err != nil {
+		return nil, fmt.Errorf("Error decoding catalog snapshot: %s", err)
+	}
+
+	return &c, nil
+}
+
+// WriteFile writes a snapshot of the catalog.
+func (c *Catalog) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// DefaultCache returns the default path of the catalog snapshot.
+func DefaultCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "pedsnet-dqa", "catalog.json")
+}
@@ -0,0 +1,166 @@
+package catalog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/gh"
+	"github.com/PEDSnet/tools/cmd/dqa/gh/ghtest"
+)
+
+const (
+	testCommit      = "0123456789abcdef0123456789abcdef01234567"
+	testCheckout    = "testdata/github/PEDSnet/Data-Quality-Analysis/contents"
+	testAssociation = "testdata/github/PEDSnet/Data-Quality-Results/contents/SecondaryReports/ConflictResolution/conflict_associations.csv"
+)
+
+func checkCatalog(t *testing.T, c *Catalog) {
+	ch := c.Check("CA-005")
+	if ch == nil {
+		t.Fatal("expected check CA-005")
+	}
+
+	if ch.Alias != "num_records" {
+		t.Errorf("expected alias num_records, got %s", ch.Alias)
+	}
+
+	// Described by the inventory file.
+	if d := c.Description("CB-002"); d != "Unexpected change in missingness of related fields between data cycles" {
+		t.Errorf("unexpected description for CB-002: %s", d)
+	}
+
+	// Builtin description.
+	if d := c.Description("AA-001"); d != "Value set violation" {
+		t.Errorf("unexpected description for AA-001: %s", d)
+	}
+
+	thres := c.Threshold("CA-005", "visit_occurrence", "visit_occurrence_id")
+	if thres == nil {
+		t.Fatal("expected threshold for visit_occurrence.visit_occurrence_id")
+	}
+
+	if thres.Lower != -15 || thres.Upper != 25 {
+		t.Errorf("expected thresholds -15 and 25, got %d and %d", thres.Lower, thres.Upper)
+	}
+
+	// Mapped through the conflict associations.
+	if c.Threshold("CA-006", "person", "gender_concept_id,gender_source_value") == nil {
+		t.Error("expected CB-002 thresholds to be associated with CA-006")
+	}
+
+	if c.HasThresholds("CB-002") {
+		t.Error("expected CB-002 thresholds to only apply to CA-006")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	srv := ghtest.NewServer("testdata/github")
+	defer srv.Close()
+
+	client, err := gh.NewClient(gh.Config{
+		BaseURL: srv.BaseURL(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Fetch(client, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Source != SourceGitHub || c.Commit != testCommit {
+		t.Errorf("expected github source at %s, got %s at %s", testCommit, c.Source, c.Commit)
+	}
+
+	checkCatalog(t, c)
+}
+
+func TestLoadDir(t *testing.T) {
+	c, err := LoadDir(testCheckout, testAssociation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Source != SourceDir {
+		t.Errorf("expected dir source, got %s", c.Source)
+	}
+
+	checkCatalog(t, c)
+}
+
+func TestGitCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqa-catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/master\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, ".git", "packed-refs"), []byte("# pack-refs with: peeled\n"+testCommit+" refs/heads/master\n"), 0644)
+
+	if c := gitCommit(dir); c != testCommit {
+		t.Errorf("expected commit from packed refs, got `%s`", c)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	srv := ghtest.NewServer("testdata/github")
+	defer srv.Close()
+
+	client, err := gh.NewClient(gh.Config{
+		BaseURL: srv.BaseURL(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "dqa-catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache := filepath.Join(dir, "catalog.json")
+
+	// No snapshot and no client.
+	c, err := Load(Options{Cache: cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Source != SourceBuiltin {
+		t.Errorf("expected builtin catalog, got %s", c.Source)
+	}
+
+	// Fetched and written to the snapshot.
+	if _, err = Load(Options{Cache: cache, Client: client}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read from the snapshot without a client.
+	c, err = Load(Options{Cache: cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Source != SourceGitHub || c.Commit != testCommit {
+		t.Errorf("expected snapshot of github catalog, got %s at %s", c.Source, c.Commit)
+	}
+
+	checkCatalog(t, c)
+
+	// The snapshot is used instead of fetching.
+	srv.Close()
+
+	if _, err = Load(Options{Cache: cache, Client: client}); err != nil {
+		t.Errorf("expected snapshot to be used, got %s", err)
+	}
+
+	if _, err = Load(Options{Cache: cache, Client: client, Refresh: true}); err == nil {
+		t.Error("expected refresh to fail")
+	}
+}
@@ -0,0 +1,113 @@
+package catalog
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/PEDSnet/tools/cmd/dqa/gh"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var Cmd = &cobra.Command{
+	Use: "catalog",
+
+	Short: "Shows the DQA check catalog.",
+
+	Example: `Show the cached catalog or fetch it if there is no cached snapshot:
+  pedsnet-dqa catalog --token=...
+
+Refresh the cached snapshot:
+  pedsnet-dqa catalog --refresh --token=...
+
+Show the catalog of a local checkout:
+  pedsnet-dqa catalog --catalog=../Data-Quality-Analysis`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := ConfigOptions("catalog")
+		opts.Refresh = viper.GetBool("catalog.refresh")
+
+		token := viper.GetString("catalog.token")
+
+		if token != "" || opts.Refresh {
+			client, err := gh.NewClient(gh.Config{
+				Token:   token,
+				BaseURL: viper.GetString("catalog.github-url"),
+				Log:     os.Stderr,
+			})
+			if err != nil {
+				cmd.Println(err)
+				os.Exit(1)
+			}
+
+			opts.Client = client
+			opts.Workers = viper.GetInt("catalog.workers")
+		}
+
+		c, err := Load(opts)
+		if err != nil {
+			cmd.Printf("Error loading catalog: %s\n", err)
+			os.Exit(1)
+		}
+
+		cmd.Printf("Source: %s\n", c.Source)
+
+		if c.Commit != "" {
+			cmd.Printf("Commit: %s\n", c.Commit)
+		}
+
+		if !c.Loaded.IsZero() {
+			cmd.Printf("Loaded: %s\n", c.Loaded.Format("2006-01-02 15:04:05 MST"))
+		}
+
+		cmd.Println("")
+
+		tw := tablewriter.NewWriter(cmd.OutOrStdout())
+		tw.SetHeader([]string{"Code", "Alias", "Description", "Thresholds"})
+
+		for _, code := range c.Codes() {
+			ch := c.Check(code)
+			tw.Append([]string{ch.Code, ch.Alias, ch.Description, strconv.Itoa(len(ch.Thresholds))})
+		}
+
+		tw.Render()
+	},
+}
+
+// BindFlags adds the flags to locate the catalog to a command and binds
+// them under the key prefix.
+func BindFlags(flags *pflag.FlagSet, prefix string) {
+	flags.String("catalog", "", "Local checkout of the Data-Quality-Analysis repository.")
+	flags.String("catalog-associations", "", "Local copy of the conflict associations file.")
+	flags.String("catalog-cache", DefaultCache(), "Path of the cached catalog snapshot.")
+
+	viper.BindPFlag(prefix+".catalog", flags.Lookup("catalog"))
+	viper.BindPFlag(prefix+".catalog-associations", flags.Lookup("catalog-associations"))
+	viper.BindPFlag(prefix+".catalog-cache", flags.Lookup("catalog-cache"))
+}
+
+// ConfigOptions returns the options of the flags bound by BindFlags.
+func ConfigOptions(prefix string) Options {
+	return Options{
+		Dir:          viper.GetString(prefix + ".catalog"),
+		Associations: viper.GetString(prefix + ".catalog-associations"),
+		Cache:        viper.GetString(prefix + ".catalog-cache"),
+	}
+}
+
+func init() {
+	flags := Cmd.Flags()
+	flags.String("token", "", "Token used to authenticate with GitHub.")
+	flags.Bool("refresh", false, "Fetch the catalog from GitHub and update the cached snapshot.")
+	flags.Int("workers", gh.DefaultWorkers, "Number of concurrent requests to GitHub.")
+	flags.String("github-url", "", "Base URL of the GitHub API.")
+
+	viper.BindPFlag("catalog.token", flags.Lookup("token"))
+	viper.BindPFlag("catalog.refresh", flags.Lookup("refresh"))
+	viper.BindPFlag("catalog.workers", flags.Lookup("workers"))
+	viper.BindPFlag("catalog.github-url", flags.Lookup("github-url"))
+
+	BindFlags(flags, "catalog")
+}
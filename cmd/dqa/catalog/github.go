@@ -0,0 +1,174 @@
+package catalog
+
+import (
+	"context"
+	"os"
+	"path"
+
+	"github.com/PEDSnet/tools/cmd/dqa/gh"
+	"github.com/google/go-github/github"
+)
+
+// Fetch fetches the catalog and the conflict associations from GitHub. The
+// catalog files are fetched concurrently.
+func Fetch(client *github.Client, workers int) (*Catalog, error) {
+	ctx := context.Background()
+
+	c := newCatalog(SourceGitHub)
+
+	commit, _, err := client.Repositories.GetCommitSHA1(ctx, owner, catalogRepo, "master", "")
+	if err != nil {
+		return nil, err
+	}
+
+	c.Commit = commit
+
+	// Get conflict associations
+	fileContent, _, _, err := client.Repositories.GetContents(ctx, owner, conflictRepo, conflictAssociationsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := fileContent.GetContent()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.parseAssociations([]byte(content)); err != nil {
+		return nil, err
+	}
+
+	_, dirContent, _, err := client.Repositories.GetContents(ctx, owner, catalogRepo, catalogPath, &github.RepositoryContentGetOptions{
+		Ref: commit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tasks []gh.Task
+		names []string
+	)
+
+	files := make([][]byte, 0, len(dirContent))
+
+	for _, file := range dirContent {
+		if file.GetType() == "dir" || path.Ext(file.GetName()) != ".csv" {
+			continue
+		}
+
+		i := len(tasks)
+		p := file.GetPath()
+
+		names = append(names, file.GetName())
+		files = append(files, nil)
+
+		tasks = append(tasks, func() error {
+			file, _, _, err := client.Repositories.GetContents(ctx, owner, catalogRepo, p, &github.RepositoryContentGetOptions{
+				Ref: commit,
+			})
+			if err != nil {
+				return err
+			}
+
+			content, err := file.GetContent()
+			if err != nil {
+				return err
+			}
+
+			files[i] = []byte(content)
+			return nil
+		})
+	}
+
+	pool := gh.Pool{
+		Workers:  workers,
+		Progress: gh.NewProgress(os.Stderr, "Fetching DQA catalog"),
+	}
+
+	for _, err := range pool.Run(tasks) {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Parsed in order so checks described in multiple files are merged
+	// consistently.
+	for i, name := range names {
+		if err := c.parseFile(name, files[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Options for loading the catalog.
+type Options struct {
+	// Dir is a local checkout of the catalog repository. If set, the catalog
+	// is loaded from it and the cache is not used.
+	Dir string
+
+	// Associations is a local copy of the conflict associations file used
+	// with Dir.
+	Associations string
+
+	// Cache is the path of the catalog snapshot.
+	Cache string
+
+	// Refresh forces the catalog to be fetched from GitHub and the snapshot
+	// to be updated.
+	Refresh bool
+
+	// Client used to fetch the catalog. If nil, the catalog is never fetched
+	// and the builtin catalog is used if there is no snapshot.
+	Client *github.Client
+
+	// Workers is the number of concurrent requests.
+	Workers int
+}
+
+// Load loads the catalog from the local checkout, the snapshot or GitHub in
+// that order. A catalog fetched from GitHub is written to the snapshot.
+func Load(opts Options) (*Catalog, error) {
+	if opts.Dir != "" {
+		return LoadDir(opts.Dir, opts.Associations)
+	}
+
+	var (
+		cached *Catalog
+		err    error
+	)
+
+	if opts.Cache != "" {
+		cached, err = ReadFile(opts.Cache)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	if cached != nil && !opts.Refresh {
+		return cached, nil
+	}
+
+	if opts.Client == nil {
+		if cached != nil {
+			return cached, nil
+		}
+
+		return Builtin(), nil
+	}
+
+	c, err := Fetch(opts.Client, opts.Workers)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Cache != "" {
+		if err := c.WriteFile(opts.Cache); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
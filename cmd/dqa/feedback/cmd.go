@@ -1,13 +1,18 @@
 package feedback
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/PEDSnet/tools/cmd/dqa/gh"
+	"github.com/PEDSnet/tools/cmd/dqa/models"
 	"github.com/PEDSnet/tools/cmd/dqa/results"
+	dms "github.com/chop-dbhi/data-models-service/client"
 	"github.com/google/go-github/github"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -54,111 +59,52 @@ var SyncCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		gr := NewGitHubReport("", "", dataCycle, token)
+		gr := NewGitHubReport("", "", dataCycle, newClient(cmd, token))
 
-		issuesById := make(map[int]*github.Issue)
-
-		// Iterate over each file and incrementally post the issues.
-		for name, file := range files {
-			var causeChanges, statusChanges int
-
-			for _, result := range file.Results {
-				if gr.Site == "" {
-					// This is a bit weird, but the site and ETL version are set using the result.
-					gr.Site = result.SiteName()
-					gr.ETLVersion = result.ETLVersion()
-
-					issues, err := gr.FetchIssues()
-					if err != nil {
-						cmd.Printf("Error fetching issues: %s\n", err)
-						os.Exit(1)
-					}
-
-					for _, issue := range issues {
-						issuesById[*issue.Number] = issue
-					}
-
-					cmd.Printf("Fetched %d issues.\n", len(issuesById))
-				}
-
-				if !result.IsIssue() || result.GithubID == "" {
-					continue
+		// The site and ETL version are set using the first result with a site.
+		for _, file := range files {
+			for _, r := range file.Results {
+				if gr.Site == "" && r.SiteName() != "" {
+					gr.Site = r.SiteName()
+					gr.ETLVersion = r.ETLVersion()
 				}
+			}
+		}
 
-				id, err := strconv.Atoi(result.GithubID)
-				if err != nil {
-					cmd.Printf("Invalid GitHub ID: `%s`\n", result.GithubID)
-					os.Exit(1)
-				}
-
-				issue, ok := issuesById[id]
-				if !ok {
-					cmd.Printf("Github issue %d is being referenced, but was not found on GitHub.\n", id)
-					os.Exit(1)
-				}
-
-				var status, cause string
-
-				for _, label := range issue.Labels {
-					kind, value, err := ParseLabel(*label.Name)
-					if err != nil {
-						continue
-					}
-
-					switch strings.ToLower(kind) {
-					case "status":
-						if status != "" {
-							cmd.Printf("Duplicate Status label on issue %s. Remove it and re-run.\n", *issue.HTMLURL)
-							os.Exit(1)
-						}
-
-						status = value
-
-					case "cause":
-						if cause != "" {
-							cmd.Printf("Duplicate Cause label on issue %s. Remove it and re-run.\n", *issue.HTMLURL)
-							os.Exit(1)
-						}
+		// Without a site there are no issues to sync with.
+		if gr.Site == "" {
+			cmd.Println("No changes to sync.")
+			return
+		}
 
-						cause = value
-					}
-				}
+		issues, err := gr.FetchIssues()
+		if err != nil {
+			cmd.Printf("Error fetching issues: %s\n", err)
+			os.Exit(1)
+		}
 
-				if cause != result.Cause {
-					fmt.Printf("Changing %s cause %s -> %s\n", result, result.Cause, cause)
-					result.Cause = cause
-					causeChanges++
-				}
+		cmd.Printf("Fetched %d issues.\n", len(issues))
 
-				if status != result.Status {
-					fmt.Printf("Changing %s status %s -> %s\n", result, result.Status, status)
-					result.Status = status
-					statusChanges++
-				}
-			}
+		changes, err := Sync(files, issues)
+		if err != nil {
+			cmd.Println(err)
+			os.Exit(1)
+		}
 
-			// Nothing to do.
-			if causeChanges == 0 && statusChanges == 0 {
-				cmd.Printf("No changes to sync for '%s'.\n", name)
-				continue
-			}
+		for _, c := range changes {
+			cmd.Printf("Changing %s %s %s -> %s\n", c.Result, c.Kind, c.Old, c.New)
+		}
 
-			// File opened successfully.
-			f, err := os.Create(filepath.Join(dir, name))
-			if err != nil {
-				cmd.Printf("Error opening file to write issue IDs: %s\n", err)
-				os.Exit(1)
-			}
-			defer f.Close()
-			w := results.NewWriter(f)
+		changed := changes.Files()
 
-			if err := w.WriteAll(file.Results); err != nil {
-				cmd.Printf("Error writing results to file.")
-				os.Exit(1)
-			}
+		if len(changed) == 0 {
+			cmd.Println("No changes to sync.")
+			return
+		}
 
-			if err := w.Flush(); err != nil {
-				cmd.Printf("Error flushing results to file.")
+		for _, name := range changed {
+			if err := results.WriteFile(filepath.Join(dir, name), files[name]); err != nil {
+				cmd.Printf("Error writing results to '%s': %s\n", name, err)
 				os.Exit(1)
 			}
 
@@ -184,6 +130,7 @@ var GenerateCmd = &cobra.Command{
 		dataCycle := viper.GetString("feedback.cycle")
 		post := viper.GetBool("feedback.generate.post")
 		printSummary := viper.GetBool("feedback.generate.print-summary")
+		workers := viper.GetInt("feedback.workers")
 
 		if dataCycle == "" {
 			cmd.Println("The data cycle could not be detected. Please supply it using the --cycle option.")
@@ -202,9 +149,21 @@ var GenerateCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		gr := NewGitHubReport("", "", dataCycle, token)
+		gr := NewGitHubReport("", "", dataCycle, newClient(cmd, token))
+
+		// GitHub requests are queued and run concurrently once all
+		// issues have been built.
+		var tasks []gh.Task
+
+		// Issues by file name.
+		fileIssues := make(map[string]results.Results)
+
+		// The IDs of new issues are saved to a file once all of its issues
+		// are posted, so an interrupted run only loses the IDs of the files
+		// still being posted.
+		var mu sync.Mutex
+		posts := make(map[string]*postState)
 
-		// Iterate over each file and incrementally post the issues.
 		for name, file := range files {
 			var newIssues results.Results
 
@@ -229,77 +188,60 @@ var GenerateCmd = &cobra.Command{
 					os.Exit(1)
 				}
 
-				if post {
-					// New issue.
-					if result.GithubID == "" {
+				if !post {
+					continue
+				}
+
+				name, file, result := name, file, result
+
+				// New issue.
+				if result.GithubID == "" {
+					st, ok := posts[name]
+					if !ok {
+						st = &postState{}
+						posts[name] = st
+					}
+
+					st.pending++
+
+					tasks = append(tasks, func() error {
 						issue, err := gr.PostIssue(ir)
-						if err != nil {
-							cmd.Printf("Error posting issue to GitHub: %s\n", err)
-							continue
-						}
 
-						result.GithubID = fmt.Sprintf("%d", *issue.Number)
+						mu.Lock()
+						defer mu.Unlock()
 
-						// Existing issue that should be tagged with the new label
-						// and re-opened.
-					} else {
-						num, err := strconv.Atoi(result.GithubID)
-						if err != nil {
-							cmd.Printf("Error converting GithubID #%s to integer: %s", result.GithubID, err)
-							continue
-						}
+						st.pending--
 
-						issue, err := gr.FetchIssue(num)
-						if err != nil {
-							cmd.Printf("Error fetching issue #%d:\n%s", num, err)
-							continue
+						if err == nil {
+							result.GithubID = fmt.Sprintf("%d", *issue.Number)
+							st.saved++
 						}
 
-						var sameDataCycle bool
-
-						// Compare new labels, if one has changed, then re-open and update.
-						for _, label := range issue.Labels {
-							kind, value, err := ParseLabel(*label.Name)
-							if err != nil {
-								continue
-							}
-
-							if kind == "Data Cycle" {
-								if value == gr.DataCycle {
-									sameDataCycle = true
-									break
-								}
-							}
+						if st.pending == 0 && st.saved > 0 {
+							saveIssueIDs(cmd, dir, file, name, fileIssues[name])
 						}
 
-						// Different data cycle, so reopen and add the new label.
-						if !sameDataCycle {
-							if *issue.State == "closed" {
-								err := gr.OpenIssue(num)
-								if err != nil {
-									cmd.Printf("Error opening issue #%d:\n%s", num, err)
-									continue
-								}
-							}
-
-							_, err = gr.AddLabels(num, []string{
-								dataCycleLabel(gr.DataCycle),
-							})
-
-							if err != nil {
-								cmd.Printf("Error adding Data Cycle label on issue #%d:\n%s", num, err)
-								continue
-							}
-
-							body := fmt.Sprintf("Latest finding: %s", result.Finding)
-							err = gr.CreateComment(num, body)
-							if err != nil {
-								cmd.Printf("Error creating `Latest finding` comment on issue #%d:\n%s", num, err)
-								continue
-							}
+						if err != nil {
+							return fmt.Errorf("Error posting issue for %s to GitHub: %s", result, err)
 						}
-					}
+
+						return nil
+					})
+
+					continue
+				}
+
+				// Existing issue that should be tagged with the new label
+				// and re-opened.
+				num, err := strconv.Atoi(result.GithubID)
+				if err != nil {
+					cmd.Printf("Error converting GithubID #%s to integer: %s\n", result.GithubID, err)
+					continue
 				}
+
+				tasks = append(tasks, func() error {
+					return gr.CarryIssue(num, result.Finding)
+				})
 			}
 
 			if len(newIssues) == 0 {
@@ -308,41 +250,18 @@ var GenerateCmd = &cobra.Command{
 			}
 
 			cmd.Printf("%d issues found in '%s'\n", len(newIssues), name)
+			fileIssues[name] = newIssues
+		}
 
-			//
-			if post {
-				success := true
-				f, err := os.Create(filepath.Join(dir, name))
-
-				// File opened successfully.
-				if err == nil {
-					defer f.Close()
-					w := results.NewWriter(f)
-
-					if err := w.WriteAll(file.Results); err != nil {
-						success = false
-						cmd.Printf("Error writing results to file.")
-					}
-
-					if err := w.Flush(); err != nil {
-						success = false
-						cmd.Printf("Error flushing results to file.")
-					}
-
-					cmd.Printf("Saved new issue IDs to '%s'\n", name)
-				} else {
-					success = false
-					cmd.Printf("Error opening file to write issue IDs: %s\n", err)
-				}
+		if len(tasks) > 0 {
+			pool := gh.Pool{
+				Workers:  workers,
+				Progress: gh.NewProgress(os.Stderr, "Updating GitHub issues"),
+			}
 
-				// Fallback to writing to standard out.
-				if !success {
-					cmd.Printf("Falling back to printing the results so they can be copy and pasted into '%s'.", name)
-					// Only print the new issues to stdout.
-					w := results.NewWriter(os.Stdout)
-					w.WriteAll(newIssues)
-					w.Flush()
-					continue
+			for _, err := range pool.Run(tasks) {
+				if err != nil {
+					cmd.Println(err)
 				}
 			}
 		}
@@ -390,17 +309,197 @@ var GenerateCmd = &cobra.Command{
 	},
 }
 
+var LabelsCmd = &cobra.Command{
+	Use: "labels <site>...",
+
+	Short: "Checks the feedback labels in site repositories and optionally fixes them.",
+
+	Long: `The feedback workflow depends on a set of labels in each site repository.
+The expected labels are derived from the model tables and the valid ranks, causes
+and statuses. Missing labels and labels with a different color are reported as
+well as labels of a known kind (e.g. Cause) with a value that is no longer valid.
+
+Labels can be renamed across all repositories using the --rename option. Issues
+keep the renamed label. Changes are only made to GitHub if --apply is supplied.`,
+
+	Example: `pedsnet-dqa feedback labels --token=abc123 --version=2.3.0 CHOP Boston
+
+Rename a cause across repositories:
+  pedsnet-dqa feedback labels --token=abc123 --version=2.3.0 --apply \
+    --rename="Cause: ETL: programming=Cause: ETL: programming error" CHOP Boston`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			cmd.Usage()
+			os.Exit(0)
+		}
+
+		token := viper.GetString("feedback.token")
+		dataCycle := viper.GetString("feedback.cycle")
+		workers := viper.GetInt("feedback.workers")
+		modelName := viper.GetString("feedback.labels.model")
+		modelVersion := viper.GetString("feedback.labels.version")
+		apply := viper.GetBool("feedback.labels.apply")
+
+		if token == "" {
+			cmd.Println("A token is required to access GitHub.")
+			os.Exit(1)
+		}
+
+		if modelVersion == "" {
+			cmd.Println("Model version required. Specify using the --version option.")
+			os.Exit(1)
+		}
+
+		renames := make(map[string]string)
+
+		for _, r := range viper.GetStringSlice("feedback.labels.rename") {
+			toks := strings.SplitN(r, "=", 2)
+			if len(toks) != 2 || toks[0] == "" || toks[1] == "" {
+				cmd.Printf("Invalid rename `%s`. The format is `old=new`.\n", r)
+				os.Exit(1)
+			}
+
+			renames[toks[0]] = toks[1]
+		}
+
+		model, err := models.Revision(models.ConfigProvider("feedback.labels"), modelName, modelVersion)
+		if err != nil {
+			cmd.Printf("Error fetching model revision '%s/%s': %s\n", modelName, modelVersion, err)
+			os.Exit(1)
+		}
+
+		var tables []string
+
+		for _, t := range model.Tables.List() {
+			tables = append(tables, t.Name)
+		}
+
+		expected := ExpectedLabels(tables, dataCycle)
+
+		ctx := context.Background()
+		gc := newClient(cmd, token)
+		pool := gh.Pool{
+			Workers: workers,
+		}
+
+		for _, site := range args {
+			actual, err := FetchLabels(ctx, gc, site)
+			if err != nil {
+				cmd.Printf("Error fetching labels for %s: %s\n", site, err)
+				os.Exit(1)
+			}
+
+			diff := DiffLabels(site, expected, actual, renames)
+			printLabelDiff(cmd, diff)
+
+			if !apply || diff.Len() == 0 {
+				continue
+			}
+
+			renameTasks, tasks := labelTasks(ctx, gc, diff)
+
+			for _, tasks := range [][]gh.Task{renameTasks, tasks} {
+				for _, err := range pool.Run(tasks) {
+					if err != nil {
+						cmd.Println(err)
+					}
+				}
+			}
+
+			cmd.Printf("Applied %d label changes to %s.\n", diff.Len(), site)
+		}
+	},
+}
+
+func printLabelDiff(cmd *cobra.Command, diff *LabelDiff) {
+	if diff.Len() == 0 && len(diff.Unknown) == 0 && len(diff.Conflicts) == 0 {
+		cmd.Printf("Labels are up-to-date for %s.\n", diff.Site)
+		return
+	}
+
+	cmd.Printf("Labels for %s:\n", diff.Site)
+
+	for _, r := range diff.Renames {
+		cmd.Printf("* Rename `%s` to `%s`\n", r[0], r[1])
+	}
+
+	for _, r := range diff.Conflicts {
+		cmd.Printf("* Cannot rename `%s` since `%s` already exists\n", r[0], r[1])
+	}
+
+	for _, l := range diff.Missing {
+		cmd.Printf("* Missing `%s`\n", l.Name)
+	}
+
+	for _, l := range diff.Recolor {
+		cmd.Printf("* Color of `%s` should be #%s\n", l.Name, l.Color)
+	}
+
+	for _, n := range diff.Unknown {
+		cmd.Printf("* Unknown `%s`\n", n)
+	}
+}
+
+// newClient initializes a GitHub client using the feedback options.
+func newClient(cmd *cobra.Command, token string) *github.Client {
+	client, err := gh.NewClient(gh.Config{
+		Token:   token,
+		BaseURL: viper.GetString("feedback.github-url"),
+		Log:     os.Stderr,
+	})
+
+	if err != nil {
+		cmd.Println(err)
+		os.Exit(1)
+	}
+
+	return client
+}
+
+// postState tracks the new issues of a file being posted.
+type postState struct {
+	pending int
+	saved   int
+}
+
+// saveIssueIDs writes the results back to the file so the GitHub IDs of new
+// issues are saved. If the file cannot be written, the new issues are printed
+// so they can be copied manually.
+func saveIssueIDs(cmd *cobra.Command, dir string, file *results.File, name string, newIssues results.Results) {
+	err := results.WriteFile(filepath.Join(dir, name), file)
+	if err == nil {
+		cmd.Printf("Saved new issue IDs to '%s'\n", name)
+		return
+	}
+
+	cmd.Printf("Error writing issue IDs to '%s': %s\n", name, err)
+
+	// Fallback to writing to standard out.
+	cmd.Printf("Falling back to printing the results so they can be copy and pasted into '%s'.\n", name)
+
+	// Only print the new issues to stdout.
+	w := results.NewWriter(os.Stdout)
+	w.WriteAll(newIssues)
+	w.Flush()
+}
+
 func init() {
 	Cmd.AddCommand(GenerateCmd)
 	Cmd.AddCommand(SyncCmd)
+	Cmd.AddCommand(LabelsCmd)
 
 	pflags := Cmd.PersistentFlags()
 
 	pflags.String("token", "", "Token used to authenticate with GitHub.")
 	pflags.String("cycle", "", "The data cycle for this report.")
+	pflags.Int("workers", gh.DefaultWorkers, "Number of concurrent requests to GitHub.")
+	pflags.String("github-url", "", "Base URL of the GitHub API.")
 
 	viper.BindPFlag("feedback.cycle", pflags.Lookup("cycle"))
 	viper.BindPFlag("feedback.token", pflags.Lookup("token"))
+	viper.BindPFlag("feedback.workers", pflags.Lookup("workers"))
+	viper.BindPFlag("feedback.github-url", pflags.Lookup("github-url"))
 
 	// Generate flags.
 	gflags := GenerateCmd.Flags()
@@ -410,4 +509,21 @@ func init() {
 
 	viper.BindPFlag("feedback.generate.post", gflags.Lookup("post"))
 	viper.BindPFlag("feedback.generate.print-summary", gflags.Lookup("print-summary"))
+
+	// Labels flags.
+	lflags := LabelsCmd.Flags()
+
+	lflags.String("model", "pedsnet", "The model the table labels are derived from.")
+	lflags.String("version", "", "The version of the model the table labels are derived from.")
+	lflags.String("url", dms.DefaultServiceURL, "Data models service URL.")
+	lflags.Bool("apply", false, "Creates, updates and renames labels on GitHub.")
+	lflags.StringSlice("rename", nil, "Renames a label in the form `old=new`. Can be repeated.")
+
+	viper.BindPFlag("feedback.labels.model", lflags.Lookup("model"))
+	viper.BindPFlag("feedback.labels.version", lflags.Lookup("version"))
+	viper.BindPFlag("feedback.labels.url", lflags.Lookup("url"))
+	viper.BindPFlag("feedback.labels.apply", lflags.Lookup("apply"))
+	viper.BindPFlag("feedback.labels.rename", lflags.Lookup("rename"))
+
+	models.BindFlags(lflags, "feedback.labels")
 }
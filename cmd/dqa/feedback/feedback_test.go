@@ -0,0 +1,143 @@
+package feedback
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/gh/ghtest"
+	"github.com/PEDSnet/tools/cmd/dqa/internal/testutil"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/google/go-github/github"
+)
+
+func runCmd(t *testing.T, args ...string) {
+	Cmd.SetArgs(args)
+	Cmd.SetOutput(ioutil.Discard)
+
+	if err := Cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readResults(t *testing.T, dir, name string) results.Results {
+	files, err := results.ReadFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files[name].Results
+}
+
+func hasLabel(issue *github.Issue, name string) bool {
+	for _, l := range issue.Labels {
+		if l.GetName() == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestGenerateAndSync(t *testing.T) {
+	srv := ghtest.NewServer("testdata/github")
+	defer srv.Close()
+
+	dir := testutil.CopyDir(t, "testdata/ETLv2")
+	defer os.RemoveAll(dir)
+
+	runCmd(t, "generate", "--post", "--token=abc123", "--cycle=April 2016", "--github-url="+srv.BaseURL(), dir)
+
+	issues := srv.Issues("PEDSnet", "CHOP")
+
+	// Existing issue, the new issue and the summary.
+	if len(issues) != 3 {
+		t.Fatalf("expected 3 issues, got %d", len(issues))
+	}
+
+	carried := issues[0]
+
+	if carried.GetState() != "open" {
+		t.Errorf("expected issue #1 to be re-opened")
+	}
+
+	if !hasLabel(carried, "Data Cycle: April 2016") {
+		t.Errorf("expected issue #1 to have the new data cycle label")
+	}
+
+	if n := len(srv.Comments("PEDSnet", "CHOP", 1)); n != 1 {
+		t.Errorf("expected 1 comment on issue #1, got %d", n)
+	}
+
+	if !hasLabel(issues[2], dataQualitySummaryLabel) {
+		t.Errorf("expected the last issue to be the summary")
+	}
+
+	var newResult *results.Result
+
+	for _, r := range readResults(t, dir, "person.csv") {
+		if r.Field == "gender_source_value" {
+			newResult = r
+		}
+	}
+
+	if newResult.GithubID != "2" {
+		t.Fatalf("expected GitHub ID 2 to be saved, got `%s`", newResult.GithubID)
+	}
+
+	// Label the new issue on GitHub and sync it back.
+	cause := "Cause: ETL: programming error"
+	status := "Status: solution proposed"
+
+	for i, l := range issues[1].Labels {
+		if kind, _, _ := ParseLabel(l.GetName()); kind == "Status" {
+			issues[1].Labels[i] = github.Label{Name: &status}
+		}
+	}
+
+	issues[1].Labels = append(issues[1].Labels, github.Label{Name: &cause})
+
+	runCmd(t, "sync", "--token=abc123", "--cycle=April 2016", "--github-url="+srv.BaseURL(), dir)
+
+	for _, r := range readResults(t, dir, "person.csv") {
+		if r.Field != "gender_source_value" {
+			continue
+		}
+
+		if r.Cause != "ETL: programming error" {
+			t.Errorf("expected cause to be synced, got `%s`", r.Cause)
+		}
+
+		if r.Status != "solution proposed" {
+			t.Errorf("expected status to be synced, got `%s`", r.Status)
+		}
+	}
+}
+
+func TestSyncNoSite(t *testing.T) {
+	srv := ghtest.NewServer("testdata/github")
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "dqa-feedback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+
+	Cmd.SetArgs([]string{"sync", "--token=abc123", "--cycle=April 2016", "--github-url=" + srv.BaseURL(), dir})
+	Cmd.SetOutput(&buf)
+	defer Cmd.SetOutput(nil)
+
+	// The command exits if the issues are fetched without a site.
+	if err := Cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "No changes to sync.") {
+		t.Errorf("expected no changes, got %s", buf.String())
+	}
+}
@@ -8,8 +8,6 @@ import (
 	"fmt"
 	"strings"
 
-	"golang.org/x/oauth2"
-
 	"github.com/PEDSnet/tools/cmd/dqa/results"
 	"github.com/google/go-github/github"
 )
@@ -86,7 +84,7 @@ func (gr *GithubReport) FetchSummaryIssue(ir *github.IssueRequest) (*github.Issu
 		urls := make([]string, len(issues))
 
 		for i, issue := range issues {
-			urls[i] = fmt.Sprintf("- %s", issue.HTMLURL)
+			urls[i] = fmt.Sprintf("- %s", *issue.HTMLURL)
 		}
 
 		return nil, fmt.Errorf("Multiple issues match:\n%s", strings.Join(urls, "\n"))
@@ -145,6 +143,46 @@ func (gr *GithubReport) FetchIssue(id int) (*github.Issue, error) {
 	return issue, nil
 }
 
+// CarryIssue updates an issue from a previous data cycle that was found again
+// in this one. If the issue does not have the label for this data cycle, it is
+// re-opened if closed, labeled and commented on with the latest finding.
+func (gr *GithubReport) CarryIssue(id int, finding string) error {
+	issue, err := gr.FetchIssue(id)
+	if err != nil {
+		return fmt.Errorf("Error fetching issue #%d:\n%s", id, err)
+	}
+
+	// Compare new labels, if one has changed, then re-open and update.
+	for _, label := range issue.Labels {
+		kind, value, err := ParseLabel(*label.Name)
+		if err != nil {
+			continue
+		}
+
+		if kind == "Data Cycle" && value == gr.DataCycle {
+			return nil
+		}
+	}
+
+	// Different data cycle, so reopen and add the new label.
+	if *issue.State == "closed" {
+		if err := gr.OpenIssue(id); err != nil {
+			return fmt.Errorf("Error opening issue #%d:\n%s", id, err)
+		}
+	}
+
+	if _, err := gr.AddLabels(id, []string{dataCycleLabel(gr.DataCycle)}); err != nil {
+		return fmt.Errorf("Error adding Data Cycle label on issue #%d:\n%s", id, err)
+	}
+
+	body := fmt.Sprintf("Latest finding: %s", finding)
+	if err := gr.CreateComment(id, body); err != nil {
+		return fmt.Errorf("Error creating `Latest finding` comment on issue #%d:\n%s", id, err)
+	}
+
+	return nil
+}
+
 func (gr *GithubReport) OpenIssue(id int) error {
 	state := "open"
 
@@ -268,22 +306,12 @@ func (gr *GithubReport) PostIssue(ir *github.IssueRequest) (*github.Issue, error
 }
 
 // NewGitHubReport initializes a new report for posting to GitHub.
-func NewGitHubReport(site, etl, cycle, token string) *GithubReport {
-	tk := &oauth2.Token{
-		AccessToken: token,
-	}
-
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(tk)
-	tc := oauth2.NewClient(oauth2.NoContext, ts)
-
-	client := github.NewClient(tc)
-
+func NewGitHubReport(site, etl, cycle string, client *github.Client) *GithubReport {
 	return &GithubReport{
 		Site:       site,
 		ETLVersion: etl,
 		DataCycle:  cycle,
 		client:     client,
-		ctx:        ctx,
+		ctx:        context.Background(),
 	}
 }
@@ -0,0 +1,291 @@
+package feedback
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PEDSnet/tools/cmd/dqa/gh"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/google/go-github/github"
+)
+
+// Colors of the labels by kind.
+var labelColors = map[string]string{
+	dataQualityLabel:        "5319e7",
+	dataQualitySummaryLabel: "1d76db",
+	"Data Cycle":            "c5def5",
+	"Table":                 "bfdadc",
+	"Rank":                  "fbca04",
+	"Cause":                 "d93f0b",
+	"Status":                "0e8a16",
+}
+
+// Label kinds with a fixed set of values. Labels of these kinds with
+// values not in the set are reported as unknown.
+var closedLabelKinds = []string{
+	"Table",
+	"Rank",
+	"Cause",
+	"Status",
+}
+
+// Label is a GitHub label name and color.
+type Label struct {
+	Name  string
+	Color string
+}
+
+// labelColor returns the color for a label based on its kind.
+func labelColor(name string) string {
+	if c, ok := labelColors[name]; ok {
+		return c
+	}
+
+	kind, _, err := ParseLabel(name)
+	if err != nil {
+		return ""
+	}
+
+	return labelColors[kind]
+}
+
+// ExpectedLabels returns the labels used by the feedback workflow. The
+// table labels are derived from the tables, the remaining labels from the
+// set of valid ranks, causes and statuses. If the data cycle is not empty,
+// the label for it is included.
+func ExpectedLabels(tables []string, cycle string) []*Label {
+	names := []string{
+		dataQualityLabel,
+		dataQualitySummaryLabel,
+	}
+
+	if cycle != "" {
+		names = append(names, dataCycleLabel(cycle))
+	}
+
+	for _, t := range tables {
+		if _, ok := results.ExcludedTables[t]; ok {
+			continue
+		}
+
+		names = append(names, tableLabel(t))
+	}
+
+	for _, r := range []results.Rank{results.HighRank, results.MediumRank, results.LowRank} {
+		names = append(names, rankLabel(r))
+	}
+
+	for _, c := range results.Causes {
+		names = append(names, causeLabel(c))
+	}
+
+	for _, s := range results.Statuses {
+		names = append(names, statusLabel(s))
+	}
+
+	labels := make([]*Label, len(names))
+
+	for i, n := range names {
+		labels[i] = &Label{
+			Name:  n,
+			Color: labelColor(n),
+		}
+	}
+
+	return labels
+}
+
+// LabelDiff describes how the labels of a site repository differ from the
+// expected labels.
+type LabelDiff struct {
+	Site string
+
+	// Expected labels that do not exist.
+	Missing []*Label
+
+	// Expected labels with a different color.
+	Recolor []*Label
+
+	// Labels of a known kind with a value that is not expected.
+	Unknown []string
+
+	// Labels to be renamed as pairs of old and new names.
+	Renames [][2]string
+
+	// Renames that cannot be applied because the new label already exists.
+	Conflicts [][2]string
+}
+
+// Len returns the number of changes in the diff.
+func (d *LabelDiff) Len() int {
+	return len(d.Missing) + len(d.Recolor) + len(d.Renames)
+}
+
+// DiffLabels compares the labels in a repository with the expected labels.
+// Renames are applied before the comparison, so a label that will be renamed
+// to an expected label is not reported as missing.
+func DiffLabels(site string, expected []*Label, actual []*github.Label, renames map[string]string) *LabelDiff {
+	diff := LabelDiff{
+		Site: site,
+	}
+
+	existing := make(map[string]string, len(actual))
+
+	for _, l := range actual {
+		existing[l.GetName()] = l.GetColor()
+	}
+
+	// Sorted for consistent output.
+	var olds []string
+
+	for o := range renames {
+		olds = append(olds, o)
+	}
+
+	sort.Strings(olds)
+
+	for _, o := range olds {
+		n := renames[o]
+
+		color, ok := existing[o]
+		if !ok {
+			continue
+		}
+
+		if _, ok := existing[n]; ok {
+			diff.Conflicts = append(diff.Conflicts, [2]string{o, n})
+			continue
+		}
+
+		diff.Renames = append(diff.Renames, [2]string{o, n})
+
+		delete(existing, o)
+		existing[n] = color
+	}
+
+	known := make(map[string]struct{}, len(expected))
+
+	for _, l := range expected {
+		known[l.Name] = struct{}{}
+
+		color, ok := existing[l.Name]
+
+		if !ok {
+			diff.Missing = append(diff.Missing, l)
+		} else if !strings.EqualFold(color, l.Color) {
+			diff.Recolor = append(diff.Recolor, l)
+		}
+	}
+
+	for name := range existing {
+		if _, ok := known[name]; ok {
+			continue
+		}
+
+		kind, _, err := ParseLabel(name)
+		if err != nil {
+			continue
+		}
+
+		for _, k := range closedLabelKinds {
+			if k == kind {
+				diff.Unknown = append(diff.Unknown, name)
+				break
+			}
+		}
+	}
+
+	sort.Strings(diff.Unknown)
+
+	return &diff
+}
+
+// FetchLabels fetches all labels in a site repository.
+func FetchLabels(ctx context.Context, client *github.Client, site string) ([]*github.Label, error) {
+	opts := &github.ListOptions{
+		PerPage: 100,
+	}
+
+	var labels []*github.Label
+
+	for {
+		page, resp, err := client.Issues.ListLabels(ctx, repoOwner, site, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		labels = append(labels, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opts.Page = resp.NextPage
+	}
+
+	return labels, nil
+}
+
+// labelTasks returns the tasks that apply the diff to the site repository.
+// Renames are returned separately since they must be applied before labels
+// are created.
+func labelTasks(ctx context.Context, client *github.Client, diff *LabelDiff) (renames, changes []gh.Task) {
+	for _, r := range diff.Renames {
+		old, name := r[0], r[1]
+		color := labelColor(name)
+
+		renames = append(renames, func() error {
+			label := &github.Label{
+				Name: &name,
+			}
+
+			if color != "" {
+				label.Color = &color
+			}
+
+			if _, _, err := client.Issues.EditLabel(ctx, repoOwner, diff.Site, old, label); err != nil {
+				return fmt.Errorf("Error renaming label `%s` in %s: %s", old, diff.Site, err)
+			}
+
+			return nil
+		})
+	}
+
+	for _, l := range diff.Missing {
+		l := l
+
+		changes = append(changes, func() error {
+			label := &github.Label{
+				Name:  &l.Name,
+				Color: &l.Color,
+			}
+
+			if _, _, err := client.Issues.CreateLabel(ctx, repoOwner, diff.Site, label); err != nil {
+				return fmt.Errorf("Error creating label `%s` in %s: %s", l.Name, diff.Site, err)
+			}
+
+			return nil
+		})
+	}
+
+	for _, l := range diff.Recolor {
+		l := l
+
+		changes = append(changes, func() error {
+			label := &github.Label{
+				Name:  &l.Name,
+				Color: &l.Color,
+			}
+
+			if _, _, err := client.Issues.EditLabel(ctx, repoOwner, diff.Site, l.Name, label); err != nil {
+				return fmt.Errorf("Error updating color of label `%s` in %s: %s", l.Name, diff.Site, err)
+			}
+
+			return nil
+		})
+	}
+
+	return renames, changes
+}
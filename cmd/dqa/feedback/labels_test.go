@@ -0,0 +1,59 @@
+package feedback
+
+import (
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/google/go-github/github"
+)
+
+func newLabel(name, color string) *github.Label {
+	return &github.Label{
+		Name:  &name,
+		Color: &color,
+	}
+}
+
+func TestDiffLabels(t *testing.T) {
+	expected := ExpectedLabels([]string{"person", "concept"}, "April 2016")
+
+	actual := []*github.Label{
+		newLabel("Data Quality", "5319e7"),
+		newLabel("Table: person", "000000"),
+		newLabel("Cause: ETL: programming", "d93f0b"),
+		newLabel("Status: obsolete", "0e8a16"),
+		newLabel("bug", "ee0701"),
+	}
+
+	renames := map[string]string{
+		"Cause: ETL: programming": "Cause: ETL: programming error",
+	}
+
+	diff := DiffLabels("CHOP", expected, actual, renames)
+
+	if len(diff.Renames) != 1 {
+		t.Errorf("expected 1 rename, got %d", len(diff.Renames))
+	}
+
+	if len(diff.Recolor) != 1 || diff.Recolor[0].Name != "Table: person" {
+		t.Errorf("expected `Table: person` to be recolored, got %v", diff.Recolor)
+	}
+
+	if len(diff.Unknown) != 1 || diff.Unknown[0] != "Status: obsolete" {
+		t.Errorf("expected `Status: obsolete` to be unknown, got %v", diff.Unknown)
+	}
+
+	// The vocabulary table is excluded and the renamed label exists.
+	for _, l := range diff.Missing {
+		switch l.Name {
+		case "Table: concept", "Cause: ETL: programming error", "Data Quality":
+			t.Errorf("unexpected missing label `%s`", l.Name)
+		}
+	}
+
+	// Summary, cycle, 3 ranks, remaining causes and statuses.
+	exp := 2 + 3 + len(results.Causes) - 1 + len(results.Statuses)
+	if len(diff.Missing) != exp {
+		t.Errorf("expected %d missing labels, got %d", exp, len(diff.Missing))
+	}
+}
@@ -0,0 +1,142 @@
+package feedback
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/google/go-github/github"
+)
+
+// LabelChange is a change of the cause or status of a result.
+type LabelChange struct {
+	File   string
+	Result *results.Result
+	Kind   string
+	Old    string
+	New    string
+}
+
+// LabelChanges are the changes made by a sync.
+type LabelChanges []*LabelChange
+
+// Files returns the sorted names of the changed files.
+func (c LabelChanges) Files() []string {
+	seen := make(map[string]struct{})
+
+	var names []string
+
+	for _, x := range c {
+		if _, ok := seen[x.File]; ok {
+			continue
+		}
+
+		seen[x.File] = struct{}{}
+		names = append(names, x.File)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// issueLabels returns the values of the Cause and Status labels of an issue.
+func issueLabels(issue *github.Issue) (string, string, error) {
+	var status, cause string
+
+	for _, label := range issue.Labels {
+		kind, value, err := ParseLabel(label.GetName())
+		if err != nil {
+			continue
+		}
+
+		switch strings.ToLower(kind) {
+		case "status":
+			if status != "" {
+				return "", "", fmt.Errorf("Duplicate Status label on issue %s. Remove it and re-run.", issue.GetHTMLURL())
+			}
+
+			status = value
+
+		case "cause":
+			if cause != "" {
+				return "", "", fmt.Errorf("Duplicate Cause label on issue %s. Remove it and re-run.", issue.GetHTMLURL())
+			}
+
+			cause = value
+		}
+	}
+
+	return cause, status, nil
+}
+
+// Sync sets the cause and status of the issues in the files from the labels
+// of the GitHub issues they reference. The files are changed in place.
+func Sync(files map[string]*results.File, issues []*github.Issue) (LabelChanges, error) {
+	issuesById := make(map[int]*github.Issue)
+
+	for _, issue := range issues {
+		issuesById[issue.GetNumber()] = issue
+	}
+
+	var names []string
+
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var changes LabelChanges
+
+	for _, name := range names {
+		for _, result := range files[name].Results {
+			if !result.IsIssue() || result.GithubID == "" {
+				continue
+			}
+
+			id, err := strconv.Atoi(result.GithubID)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid GitHub ID: `%s`", result.GithubID)
+			}
+
+			issue, ok := issuesById[id]
+			if !ok {
+				return nil, fmt.Errorf("Github issue %d is being referenced, but was not found on GitHub.", id)
+			}
+
+			cause, status, err := issueLabels(issue)
+			if err != nil {
+				return nil, err
+			}
+
+			if cause != result.Cause {
+				changes = append(changes, &LabelChange{
+					File:   name,
+					Result: result,
+					Kind:   "cause",
+					Old:    result.Cause,
+					New:    cause,
+				})
+
+				result.Cause = cause
+			}
+
+			if status != result.Status {
+				changes = append(changes, &LabelChange{
+					File:   name,
+					Result: result,
+					Kind:   "status",
+					Old:    result.Status,
+					New:    status,
+				})
+
+				result.Status = status
+			}
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,77 @@
+package feedback
+
+import (
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/google/go-github/github"
+)
+
+func testIssue(number int, labels ...string) *github.Issue {
+	issue := &github.Issue{
+		Number: github.Int(number),
+	}
+
+	for _, l := range labels {
+		issue.Labels = append(issue.Labels, github.Label{Name: github.String(l)})
+	}
+
+	return issue
+}
+
+func TestSync(t *testing.T) {
+	r := results.NewResult()
+	r.Table = "person"
+	r.Field = "year_of_birth"
+	r.CheckCode = "BA-001"
+	r.Rank = results.HighRank
+	r.Status = "new"
+	r.GithubID = "1"
+
+	f := results.NewFile("person.csv")
+	f.Results = results.Results{r}
+
+	files := map[string]*results.File{
+		"person.csv": f,
+	}
+
+	issues := []*github.Issue{
+		testIssue(1, "Cause: ETL: programming error", "Status: solution proposed", "Data Cycle: April 2016"),
+	}
+
+	changes, err := Sync(files, issues)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d", len(changes))
+	}
+
+	if r.Cause != "ETL: programming error" || r.Status != "solution proposed" {
+		t.Errorf("unexpected cause `%s` and status `%s`", r.Cause, r.Status)
+	}
+
+	if names := changes.Files(); len(names) != 1 || names[0] != "person.csv" {
+		t.Errorf("expected person.csv to change, got %v", names)
+	}
+
+	// Already in sync.
+	if changes, err = Sync(files, issues); err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes, got %d (%v)", len(changes), err)
+	}
+
+	// Duplicate labels.
+	issues = []*github.Issue{
+		testIssue(1, "Status: solution proposed", "Status: persistent"),
+	}
+
+	if _, err := Sync(files, issues); err == nil {
+		t.Error("expected an error for duplicate labels")
+	}
+
+	// Missing issue.
+	if _, err := Sync(files, nil); err == nil {
+		t.Error("expected an error for a missing issue")
+	}
+}
@@ -0,0 +1,100 @@
+package generate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	dms "github.com/chop-dbhi/data-models-service/client"
+)
+
+// DefaultSelect selects the issues carried forward by default.
+const DefaultSelect = "status in (persistent, 'under review')"
+
+// Dropped is a selected result that could not be carried forward.
+type Dropped struct {
+	File   string
+	Result *results.Result
+	Reason string
+}
+
+// Carry selects the results of the previous report that are carried
+// forward to the template.
+type Carry struct {
+	Select *results.Selector
+
+	// ResetStatus sets the status of carried results to persistent.
+	ResetStatus bool
+}
+
+// Apply returns the carried results by table and the selected results that
+// were dropped because their table or field is not in the model. The
+// carried results are copies that keep their GitHub ID and check alias.
+func (c *Carry) Apply(files map[string]*results.File, model *dms.Model) (map[string][]*results.Result, []*Dropped) {
+	carried := make(map[string][]*results.Result)
+
+	var (
+		names   []string
+		dropped []*Dropped
+	)
+
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, r := range files[name].Results {
+			if c.Select != nil && !c.Select.Match(r) {
+				continue
+			}
+
+			if reason := checkModel(r, model); reason != "" {
+				dropped = append(dropped, &Dropped{
+					File:   name,
+					Result: r,
+					Reason: reason,
+				})
+				continue
+			}
+
+			res := r.Migrate()
+
+			if c.ResetStatus {
+				res.Status = "persistent"
+			}
+
+			carried[res.Table] = append(carried[res.Table], res)
+		}
+	}
+
+	return carried, dropped
+}
+
+// checkModel returns the reason the table or fields of a result are not in
+// the model.
+func checkModel(r *results.Result, model *dms.Model) string {
+	if _, ok := results.ExcludedTables[r.Table]; ok {
+		return fmt.Sprintf("table %s is excluded", r.Table)
+	}
+
+	t := model.Tables.Get(r.Table)
+
+	if t == nil {
+		return fmt.Sprintf("table %s is not in %s/%s", r.Table, model.Name, model.Version)
+	}
+
+	if r.Field == "" {
+		return ""
+	}
+
+	// A result may refer to multiple fields.
+	for _, f := range r.Fields() {
+		if t.Fields.Get(f) == nil {
+			return fmt.Sprintf("field %s.%s is not in %s/%s", r.Table, f, model.Name, model.Version)
+		}
+	}
+
+	return ""
+}
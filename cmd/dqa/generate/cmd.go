@@ -1,18 +1,19 @@
 package generate
 
 import (
-	"fmt"
 	"os"
-	"path/filepath"
+	"strconv"
 
+	"github.com/PEDSnet/tools/cmd/dqa/models"
 	"github.com/PEDSnet/tools/cmd/dqa/results"
 	dms "github.com/chop-dbhi/data-models-service/client"
+	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var Cmd = &cobra.Command{
-	Use: "generate-templates <site> <cycle>",
+	Use: "generate-templates [<site>] <cycle>",
 
 	Short: "Generates a Secondary Report template for a site and data cycle.",
 
@@ -24,122 +25,172 @@ assessment.
 The typical process is to generate a new template per site and derive subsequent
 reports from the previous. This can be done using the --copy-persistent
 flag which ensures all persistent issues are copied to the new template.
+The issues that are copied can be chosen with a --select expression of
+conditions on the status, rank, cause, check_code and other columns:
+
+  status in (persistent, 'under review') and rank != Low
+
+Issues on tables or fields that are no longer in the model are reported.
+
+Templates for many sites can be generated in one run with a --sites manifest.
+The manifest is a CSV file with the columns site, root and previous, which are
+the site name, the directory to write the files to and the directory of the
+previous report to copy issues from.
 `,
 
 	Example: `Generate a new Secondary Report template:
-  pedsnet-dqa generate-templates --root=SecondaryReports/CHOP/ETLv5 CHOP ETLv5`,
+  pedsnet-dqa generate-templates --root=SecondaryReports/CHOP/ETLv5 CHOP ETLv5
+
+Generate the templates of all sites in a manifest:
+  pedsnet-dqa generate-templates --sites=sites.csv ETLv5`,
 
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) < 2 {
+		manifest := viper.GetString("generate.sites")
+
+		if (manifest == "" && len(args) < 2) || (manifest != "" && len(args) != 1) {
 			cmd.Usage()
 			return
 		}
 
-		// Positional.
-		siteName := args[0]
-		dataCycle := args[1]
-
 		// Options.
 		modelName := viper.GetString("generate.model")
 		modelVersion := viper.GetString("generate.version")
-		dqaVersion := viper.GetString("generate.dqa-version")
-		outDir := viper.GetString("generate.root")
-		serviceUrl := viper.GetString("generate.url")
-		copyPersistent := viper.GetString("generate.copy-persistent")
 
 		if modelVersion == "" {
 			cmd.Println("Model version required. Specify using the --version option.")
 			os.Exit(1)
 		}
 
-		// Derived value.
-		dataVersion := fmt.Sprintf("%s-%s-%s-%s", modelName, modelVersion, siteName, dataCycle)
+		sel, err := results.ParseSelector(viper.GetString("generate.select"))
+		if err != nil {
+			cmd.Printf("Invalid --select expression: %s\n", err)
+			os.Exit(1)
+		}
 
-		var pfiles map[string]*results.File
+		var (
+			sites     []*Site
+			dataCycle string
+		)
 
-		// Load the previous set of results.
-		if copyPersistent != "" {
-			var err error
-			pfiles, err = results.ReadFromDir(copyPersistent)
+		if manifest != "" {
+			dataCycle = args[0]
 
-			if err != nil {
+			if sites, err = ReadManifest(manifest); err != nil {
 				cmd.Println(err)
 				os.Exit(1)
 			}
+		} else {
+			dataCycle = args[1]
+
+			sites = []*Site{{
+				Name:     args[0],
+				Root:     viper.GetString("generate.root"),
+				Previous: viper.GetString("generate.copy-persistent"),
+			}}
 		}
 
-		// Create the necessary directories to write the files to.
-		if err := os.MkdirAll(outDir, os.ModeDir|0775); err != nil {
-			cmd.Printf("Error creating output directory '%s': %s", outDir, err)
-			os.Exit(1)
-		}
-
-		client, err := dms.New(serviceUrl)
+		// The model is fetched once for all sites.
+		model, err := models.Revision(models.ConfigProvider("generate"), modelName, modelVersion)
 		if err != nil {
-			cmd.Printf("Bad service URL: %s", err)
+			cmd.Printf("Error fetching model revision '%s/%s': %s\n", modelName, modelVersion, err)
 			os.Exit(1)
 		}
 
-		model, err := client.ModelRevision(modelName, modelVersion)
-		if err != nil {
-			cmd.Printf("Error fetching model revision '%s/%s': %s", modelName, modelVersion, err)
+		tmpl := &Template{
+			Model:      model,
+			Cycle:      dataCycle,
+			DQAVersion: viper.GetString("generate.dqa-version"),
+			Carry: &Carry{
+				Select:      sel,
+				ResetStatus: viper.GetBool("generate.reset-status"),
+			},
 		}
 
-		// Copied issue count.
-		count := 0
-
-		// Create a file per table.
-		for _, table := range model.Tables.List() {
-			// Ignore certain tables from the template file.
-			if _, ok := results.ExcludedTables[table.Name]; ok {
-				continue
-			}
-
-			// Path to output file.
-			path := filepath.Join(outDir, fmt.Sprintf("%s.csv", table.Name))
-
-			file, err := os.Create(path)
+		if manifest == "" {
+			sum, err := tmpl.Generate(sites[0])
 			if err != nil {
-				cmd.Printf("Error creating output file: %s", err)
+				cmd.Println(err)
 				os.Exit(1)
 			}
 
-			// New writer for issues. At a minimum this will add the header.
-			w := results.NewWriter(file)
+			cmd.Printf("Wrote files to '%s' for model '%s/%s'\n", sum.Site.Root, model.Name, model.Version)
+
+			if sum.Site.Previous != "" {
+				cmd.Printf("Copied %d issues from '%s'\n", sum.Copied, sum.Site.Previous)
+			}
 
-			// Copy persistent issues.
-			if copyPersistent != "" {
-				if pfile, ok := pfiles[fmt.Sprintf("%s.csv", table.Name)]; ok {
-					for _, r := range pfile.Results {
-						if r.IsPersistent() || r.IsUnresolved() {
+			printDropped(cmd, []*Summary{sum})
+			return
+		}
 
-							res := r.Migrate()
+		var (
+			sums   []*Summary
+			failed bool
+		)
 
-							// Update for this version.
-							res.Model = modelName
-							res.ModelVersion = modelVersion
-							res.DataVersion = dataVersion
-							res.DQAVersion = dqaVersion
+		tw := tablewriter.NewWriter(cmd.OutOrStdout())
+		tw.SetHeader([]string{"Site", "Directory", "Previous", "Copied", "Not Copied", "Error"})
 
-							count++
-							w.Write(res)
-						}
-					}
-				}
+		for _, site := range sites {
+			sum, err := tmpl.Generate(site)
+			if err != nil {
+				failed = true
+				tw.Append([]string{site.Name, site.Root, site.Previous, "", "", err.Error()})
+				continue
 			}
 
-			w.Flush()
-			file.Close()
+			sums = append(sums, sum)
+			tw.Append([]string{site.Name, site.Root, site.Previous, strconv.Itoa(sum.Copied), strconv.Itoa(len(sum.Dropped)), ""})
 		}
 
-		cmd.Printf("Wrote files to '%s' for model '%s/%s'\n", outDir, modelName, modelVersion)
+		cmd.Printf("Generated templates for %d sites for model '%s/%s'\n\n", len(sums), model.Name, model.Version)
+		tw.Render()
+
+		printDropped(cmd, sums)
 
-		if copyPersistent != "" {
-			cmd.Printf("Copied %d issues from '%s'\n", count, copyPersistent)
+		if failed {
+			os.Exit(1)
 		}
 	},
 }
 
+// printDropped prints the issues that were not carried forward.
+func printDropped(cmd *cobra.Command, sums []*Summary) {
+	var n int
+
+	for _, s := range sums {
+		n += len(s.Dropped)
+	}
+
+	if n == 0 {
+		return
+	}
+
+	cmd.Printf("\n%d issues were not copied:\n", n)
+
+	tw := tablewriter.NewWriter(cmd.OutOrStdout())
+
+	if len(sums) > 1 {
+		tw.SetHeader([]string{"Site", "File", "Table", "Field", "Check Code", "Github ID", "Reason"})
+	} else {
+		tw.SetHeader([]string{"File", "Table", "Field", "Check Code", "Github ID", "Reason"})
+	}
+
+	for _, s := range sums {
+		for _, d := range s.Dropped {
+			row := []string{d.File, d.Result.Table, d.Result.Field, d.Result.CheckCode, d.Result.GithubID, d.Reason}
+
+			if len(sums) > 1 {
+				row = append([]string{s.Site.Name}, row...)
+			}
+
+			tw.Append(row)
+		}
+	}
+
+	tw.Render()
+}
+
 func init() {
 	flags := Cmd.Flags()
 
@@ -149,6 +200,9 @@ func init() {
 	flags.String("dqa-version", "0", "The DQA version.")
 	flags.String("url", dms.DefaultServiceURL, "Data models service URL.")
 	flags.String("copy-persistent", "", "Copies issues in the specified path with a status of 'persistent' from an existing Secondary Report.")
+	flags.String("select", DefaultSelect, "Expression that selects the issues copied from the existing Secondary Report.")
+	flags.Bool("reset-status", false, "Sets the status of copied issues to 'persistent'.")
+	flags.String("sites", "", "CSV manifest of the sites to generate templates for.")
 
 	viper.BindPFlag("generate.root", flags.Lookup("root"))
 	viper.BindPFlag("generate.model", flags.Lookup("model"))
@@ -156,4 +210,9 @@ func init() {
 	viper.BindPFlag("generate.dqa-version", flags.Lookup("dqa-version"))
 	viper.BindPFlag("generate.url", flags.Lookup("url"))
 	viper.BindPFlag("generate.copy-persistent", flags.Lookup("copy-persistent"))
+	viper.BindPFlag("generate.select", flags.Lookup("select"))
+	viper.BindPFlag("generate.reset-status", flags.Lookup("reset-status"))
+	viper.BindPFlag("generate.sites", flags.Lookup("sites"))
+
+	models.BindFlags(flags, "generate")
 }
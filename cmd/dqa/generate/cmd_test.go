@@ -0,0 +1,199 @@
+package generate
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/models"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/internal/datamodels"
+	dms "github.com/chop-dbhi/data-models-service/client"
+)
+
+func testModel(t *testing.T) *dms.Model {
+	p := &datamodels.Provider{
+		File: "testdata/pedsnet-2.3.0.json",
+	}
+
+	m, err := models.Revision(p, "pedsnet", "2.3.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func TestGenerateCarry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqa-generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+
+	Cmd.SetArgs([]string{
+		"--root=" + dir,
+		"--version=2.3.0",
+		"--model-file=testdata/pedsnet-2.3.0.json",
+		"--copy-persistent=testdata/ETLv4",
+		"--select=status in (persistent, 'under review') and rank != Low",
+		"--reset-status",
+		"CHOP",
+		"ETLv5",
+	})
+	Cmd.SetOutput(&buf)
+
+	if err := Cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := results.ReadFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 {
+		t.Errorf("expected 2 files, got %d", len(files))
+	}
+
+	person := files["person.csv"].Results
+
+	if len(person) != 1 {
+		t.Fatalf("expected 1 issue carried, got %d", len(person))
+	}
+
+	r := person[0]
+
+	if r.Field != "person_id" || r.GithubID != "4" || r.CheckAlias != "num_records" {
+		t.Errorf("expected person_id issue with github id and alias, got %s %s %s", r.Field, r.GithubID, r.CheckAlias)
+	}
+
+	if r.Status != "persistent" || r.DataVersion != "pedsnet-2.3.0-CHOP-ETLv5" {
+		t.Errorf("unexpected status %s or data version %s", r.Status, r.DataVersion)
+	}
+
+	out := buf.String()
+
+	if !strings.Contains(out, "1 issues were not copied") || !strings.Contains(out, "pn_gestational_age") {
+		t.Errorf("expected dropped issue to be reported, got:\n%s", out)
+	}
+}
+
+func TestCarryApply(t *testing.T) {
+	files, err := results.ReadFromDir("testdata/ETLv4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sel, err := results.ParseSelector(DefaultSelect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Carry{Select: sel}
+
+	carried, dropped := c.Apply(files, testModel(t))
+
+	if len(carried["person"]) != 2 {
+		t.Errorf("expected 2 person issues, got %d", len(carried["person"]))
+	}
+
+	if len(dropped) != 2 {
+		t.Fatalf("expected 2 dropped issues, got %d", len(dropped))
+	}
+
+	if dropped[1].Reason != "table visit_payer is not in pedsnet/2.3.0" {
+		t.Errorf("unexpected reason `%s`", dropped[1].Reason)
+	}
+
+	for _, r := range carried["person"] {
+		if r.Field != "person_id" && r.Field != "gender_source_value" {
+			t.Errorf("unexpected carried issue %s", r)
+		}
+	}
+}
+
+func TestGenerateSites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqa-generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest := filepath.Join(dir, "sites.csv")
+
+	err = ioutil.WriteFile(manifest, []byte(strings.Join([]string{
+		"site,root,previous",
+		"CHOP," + filepath.Join(dir, "CHOP", "ETLv5") + ",testdata/ETLv4",
+		"BCH," + filepath.Join(dir, "BCH", "ETLv5") + ",",
+	}, "\n")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	Cmd.SetArgs([]string{
+		"--version=2.3.0",
+		"--model-file=testdata/pedsnet-2.3.0.json",
+		"--select=" + DefaultSelect,
+		"--reset-status=false",
+		"--sites=" + manifest,
+		"ETLv5",
+	})
+	Cmd.SetOutput(&buf)
+
+	if err := Cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	chop, err := results.ReadFromDir(filepath.Join(dir, "CHOP", "ETLv5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(chop["person.csv"].Results); n != 2 {
+		t.Errorf("expected 2 issues carried for CHOP, got %d", n)
+	}
+
+	for _, r := range chop["person.csv"].Results {
+		if r.DataVersion != "pedsnet-2.3.0-CHOP-ETLv5" {
+			t.Errorf("unexpected data version %s", r.DataVersion)
+		}
+	}
+
+	bch, err := results.ReadFromDir(filepath.Join(dir, "BCH", "ETLv5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bch) != 2 || len(bch["person.csv"].Results) != 0 {
+		t.Errorf("expected empty template for BCH, got %d files", len(bch))
+	}
+
+	out := buf.String()
+
+	if !strings.Contains(out, "Generated templates for 2 sites") || !strings.Contains(out, "| BCH ") {
+		t.Errorf("expected per-site summary, got:\n%s", out)
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqa-generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "sites.csv")
+	ioutil.WriteFile(fn, []byte("site,root\nCHOP,\n"), 0644)
+
+	if _, err := ReadManifest(fn); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error on line 2, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package generate
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/dqa/uni"
+	dms "github.com/chop-dbhi/data-models-service/client"
+)
+
+// Site is a site a template is generated for.
+type Site struct {
+	// Name of the site used in the data version.
+	Name string
+
+	// Root is the directory the files are written to.
+	Root string
+
+	// Previous is the directory of the Secondary Report of the previous data
+	// cycle. Issues are only carried forward if it is set.
+	Previous string
+}
+
+// Summary summarizes the template generated for a site.
+type Summary struct {
+	Site    *Site
+	Copied  int
+	Dropped []*Dropped
+}
+
+// Template generates Secondary Report templates for a model revision and
+// data cycle.
+type Template struct {
+	Model      *dms.Model
+	Cycle      string
+	DQAVersion string
+	Carry      *Carry
+}
+
+// Generate writes a file per table of the model to the root directory of
+// the site, including the issues carried forward from the previous report.
+func (t *Template) Generate(site *Site) (*Summary, error) {
+	var pfiles map[string]*results.File
+
+	// Load the previous set of results.
+	if site.Previous != "" {
+		var err error
+
+		if pfiles, err = results.ReadFromDir(site.Previous); err != nil {
+			return nil, err
+		}
+	}
+
+	// Create the necessary directories to write the files to.
+	if err := os.MkdirAll(site.Root, os.ModeDir|0775); err != nil {
+		return nil, fmt.Errorf("Error creating output directory '%s': %s", site.Root, err)
+	}
+
+	carried, dropped := t.Carry.Apply(pfiles, t.Model)
+
+	dataVersion := fmt.Sprintf("%s-%s-%s-%s", t.Model.Name, t.Model.Version, site.Name, t.Cycle)
+
+	sum := &Summary{
+		Site:    site,
+		Dropped: dropped,
+	}
+
+	// Create a file per table.
+	for _, table := range t.Model.Tables.List() {
+		// Ignore certain tables from the template file.
+		if _, ok := results.ExcludedTables[table.Name]; ok {
+			continue
+		}
+
+		// Path to output file.
+		path := filepath.Join(site.Root, fmt.Sprintf("%s.csv", table.Name))
+
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating output file: %s", err)
+		}
+
+		// New writer for issues. At a minimum this will add the header.
+		w := results.NewWriter(file)
+
+		// Copy carried issues.
+		for _, res := range carried[table.Name] {
+			// Update for this version.
+			res.Model = t.Model.Name
+			res.ModelVersion = t.Model.Version
+			res.DataVersion = dataVersion
+			res.DQAVersion = t.DQAVersion
+
+			sum.Copied++
+			w.Write(res)
+		}
+
+		err = w.Flush()
+		file.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("Error writing output file: %s", err)
+		}
+	}
+
+	return sum, nil
+}
+
+// ReadManifest reads a CSV file of sites with the columns site, root and
+// previous. The previous column is optional.
+func ReadManifest(path string) ([]*Site, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(uni.New(f))
+	cr.FieldsPerRecord = -1
+
+	head, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("Manifest '%s' is empty", path)
+	} else if err != nil {
+		return nil, err
+	}
+
+	cols := map[string]int{
+		"site":     -1,
+		"root":     -1,
+		"previous": -1,
+	}
+
+	for i, h := range head {
+		h = strings.ToLower(strings.TrimSpace(h))
+
+		if _, ok := cols[h]; ok {
+			cols[h] = i
+		}
+	}
+
+	if cols["site"] < 0 || cols["root"] < 0 {
+		return nil, fmt.Errorf("Manifest '%s' requires the columns site and root", path)
+	}
+
+	value := func(row []string, col string) string {
+		i := cols[col]
+
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+
+		return strings.TrimSpace(row[i])
+	}
+
+	var sites []*Site
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		s := &Site{
+			Name:     value(row, "site"),
+			Root:     value(row, "root"),
+			Previous: value(row, "previous"),
+		}
+
+		if s.Name == "" && s.Root == "" {
+			continue
+		}
+
+		if s.Name == "" || s.Root == "" {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("Manifest '%s' line %d: site and root are required", path, line)
+		}
+
+		sites = append(sites, s)
+	}
+
+	return sites, nil
+}
@@ -0,0 +1,79 @@
+// Package gh provides the GitHub client shared by the commands that read from
+// or write to GitHub. Requests are retried when GitHub's rate limits are hit
+// and GET requests are made conditional so repeated reads are cheap.
+package gh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+)
+
+var errNoRewind = errors.New("request body cannot be rewound for a retry")
+
+// DefaultWorkers is the default number of concurrent requests.
+const DefaultWorkers = 4
+
+// Config is the configuration of a GitHub client.
+type Config struct {
+	// Token used to authenticate with GitHub.
+	Token string
+
+	// BaseURL of the GitHub API. Defaults to the public API and is primarily
+	// set to use a local server in tests.
+	BaseURL string
+
+	// Log is written to when requests are delayed due to rate limiting.
+	Log io.Writer
+}
+
+// NewClient initializes a GitHub client for the config.
+func NewClient(cfg Config) (*github.Client, error) {
+	t := &Transport{}
+
+	if cfg.Log != nil {
+		t.Wait = func(req *http.Request, d time.Duration) {
+			fmt.Fprintf(cfg.Log, "Rate limited by GitHub. Retrying %s %s in %s\n", req.Method, req.URL.Path, d)
+		}
+	}
+
+	var rt http.RoundTripper = t
+
+	if cfg.Token != "" {
+		rt = &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{
+				AccessToken: cfg.Token,
+			}),
+			Base: t,
+		}
+	}
+
+	client := github.NewClient(&http.Client{
+		Transport: rt,
+	})
+
+	if cfg.BaseURL != "" {
+		base := cfg.BaseURL
+
+		// The client requires a trailing slash.
+		if !strings.HasSuffix(base, "/") {
+			base += "/"
+		}
+
+		u, err := url.Parse(base)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid GitHub URL: %s", err)
+		}
+
+		client.BaseURL = u
+	}
+
+	return client, nil
+}
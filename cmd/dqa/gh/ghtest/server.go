@@ -0,0 +1,706 @@
+// Package ghtest provides an in-process fake of the parts of the GitHub API
+// used by the dqa commands. The server is seeded from fixture files so
+// commands can be tested end-to-end without network access.
+//
+// The fixture directory is organized by owner and repository:
+//
+//	<owner>/<repo>/contents/...   Files and directories served by the contents API.
+//	<owner>/<repo>/issues.json    Array of issues.
+//	<owner>/<repo>/labels.json    Array of labels.
+//	<owner>/<repo>/comments.json  Object of comment arrays keyed by issue number.
+//	<owner>/<repo>/HEAD           SHA returned for any commit ref.
+//
+// Issues, labels and comments are kept in memory and can be modified through
+// the API. The fixture files are never written to.
+package ghtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+// repo is the in-memory state of a repository.
+type repo struct {
+	issues   []*github.Issue
+	labels   []*github.Label
+	comments map[int][]*github.IssueComment
+}
+
+func (r *repo) issue(n int) *github.Issue {
+	for _, i := range r.issues {
+		if i.GetNumber() == n {
+			return i
+		}
+	}
+
+	return nil
+}
+
+func (r *repo) label(name string) *github.Label {
+	for _, l := range r.labels {
+		if strings.EqualFold(l.GetName(), name) {
+			return l
+		}
+	}
+
+	return nil
+}
+
+// labelsFor returns the labels with the names, creating the ones that
+// do not exist like GitHub does.
+func (r *repo) labelsFor(names []string) []github.Label {
+	labels := make([]github.Label, len(names))
+
+	for i, n := range names {
+		l := r.label(n)
+
+		if l == nil {
+			n := n
+			color := "ededed"
+			l = &github.Label{
+				Name:  &n,
+				Color: &color,
+			}
+			r.labels = append(r.labels, l)
+		}
+
+		labels[i] = *l
+	}
+
+	return labels
+}
+
+// Server is a fake GitHub API server.
+type Server struct {
+	*httptest.Server
+
+	// Requests counts the requests by method and path.
+	Requests map[string]int
+
+	dir   string
+	mux   sync.Mutex
+	repos map[string]*repo
+}
+
+// BaseURL returns the URL to be used as the base URL of the GitHub client.
+func (s *Server) BaseURL() string {
+	return s.URL + "/"
+}
+
+// Issues returns the issues in the repository.
+func (s *Server) Issues(owner, name string) []*github.Issue {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r, err := s.repo(owner, name)
+	if err != nil {
+		return nil
+	}
+
+	return r.issues
+}
+
+// Labels returns the labels in the repository.
+func (s *Server) Labels(owner, name string) []*github.Label {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r, err := s.repo(owner, name)
+	if err != nil {
+		return nil
+	}
+
+	return r.labels
+}
+
+// Comments returns the comments on an issue in the repository.
+func (s *Server) Comments(owner, name string, number int) []*github.IssueComment {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r, err := s.repo(owner, name)
+	if err != nil {
+		return nil
+	}
+
+	return r.comments[number]
+}
+
+// repo returns the state of the repository, loading it from the fixtures
+// on first access.
+func (s *Server) repo(owner, name string) (*repo, error) {
+	key := owner + "/" + name
+
+	if r, ok := s.repos[key]; ok {
+		return r, nil
+	}
+
+	r := &repo{
+		comments: make(map[int][]*github.IssueComment),
+	}
+
+	dir := filepath.Join(s.dir, owner, name)
+
+	if err := readFixture(filepath.Join(dir, "issues.json"), &r.issues); err != nil {
+		return nil, err
+	}
+
+	if err := readFixture(filepath.Join(dir, "labels.json"), &r.labels); err != nil {
+		return nil, err
+	}
+
+	var comments map[string][]*github.IssueComment
+
+	if err := readFixture(filepath.Join(dir, "comments.json"), &comments); err != nil {
+		return nil, err
+	}
+
+	for k, c := range comments {
+		n, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid issue number in comments: %s", k)
+		}
+
+		r.comments[n] = c
+	}
+
+	s.repos[key] = r
+
+	return r, nil
+}
+
+// readFixture decodes a JSON fixture file. Missing files are ignored.
+func readFixture(name string, v interface{}) error {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("error decoding fixture %s: %s", name, err)
+	}
+
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{
+		"message": msg,
+	})
+}
+
+// paginate writes the page of items requested and sets the Link header
+// if there is a next page.
+func paginate(w http.ResponseWriter, r *http.Request, n int, page func(i, j int) interface{}) {
+	q := r.URL.Query()
+
+	perPage, _ := strconv.Atoi(q.Get("per_page"))
+	if perPage <= 0 {
+		perPage = 30
+	}
+
+	p, _ := strconv.Atoi(q.Get("page"))
+	if p <= 0 {
+		p = 1
+	}
+
+	i := (p - 1) * perPage
+	j := i + perPage
+
+	if i > n {
+		i = n
+	}
+
+	if j > n {
+		j = n
+	}
+
+	if j < n {
+		u := *r.URL
+		q.Set("page", strconv.Itoa(p+1))
+		u.RawQuery = q.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.String()))
+	}
+
+	writeJSON(w, http.StatusOK, page(i, j))
+}
+
+// ServeHTTP routes requests to the repository endpoints.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.Requests[r.Method+" "+r.URL.Path]++
+
+	toks := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 5)
+
+	if len(toks) < 4 || toks[0] != "repos" {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	owner, name, kind := toks[1], toks[2], toks[3]
+
+	var rest string
+	if len(toks) == 5 {
+		rest = toks[4]
+	}
+
+	switch kind {
+	case "contents":
+		s.serveContents(w, r, owner, name, rest)
+		return
+	case "commits":
+		s.serveCommit(w, r, owner, name, rest)
+		return
+	}
+
+	rp, err := s.repo(owner, name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	switch kind {
+	case "issues":
+		s.serveIssues(w, r, rp, rest)
+	case "labels":
+		s.serveLabels(w, r, rp, rest)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (s *Server) serveContents(w http.ResponseWriter, r *http.Request, owner, name, p string) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	p = strings.Trim(p, "/")
+	fn := filepath.Join(s.dir, owner, name, "contents", filepath.FromSlash(p))
+
+	fi, err := os.Stat(fn)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	if fi.IsDir() {
+		fis, err := ioutil.ReadDir(fn)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		var list []*github.RepositoryContent
+
+		for _, fi := range fis {
+			typ := "file"
+			if fi.IsDir() {
+				typ = "dir"
+			}
+
+			n := fi.Name()
+			fp := path.Join(p, n)
+			size := int(fi.Size())
+
+			list = append(list, &github.RepositoryContent{
+				Type: &typ,
+				Name: &n,
+				Path: &fp,
+				Size: &size,
+			})
+		}
+
+		writeJSON(w, http.StatusOK, list)
+		return
+	}
+
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "raw") {
+		w.Write(b)
+		return
+	}
+
+	typ := "file"
+	n := path.Base(p)
+	enc := "base64"
+	content := base64.StdEncoding.EncodeToString(b)
+	size := len(b)
+
+	writeJSON(w, http.StatusOK, &github.RepositoryContent{
+		Type:     &typ,
+		Name:     &n,
+		Path:     &p,
+		Size:     &size,
+		Encoding: &enc,
+		Content:  &content,
+	})
+}
+
+// serveCommit responds with the SHA of a ref. The SHA is read from a
+// `<owner>/<repo>/HEAD` fixture file.
+func (s *Server) serveCommit(w http.ResponseWriter, r *http.Request, owner, name, ref string) {
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, owner, name, "HEAD"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	w.Write([]byte(strings.TrimSpace(string(b))))
+}
+
+func (s *Server) serveIssues(w http.ResponseWriter, r *http.Request, rp *repo, rest string) {
+	toks := strings.Split(rest, "/")
+
+	// List or create.
+	if rest == "" {
+		switch r.Method {
+		case "GET":
+			s.listIssues(w, r, rp)
+		case "POST":
+			s.createIssue(w, r, rp)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+
+		return
+	}
+
+	n, err := strconv.Atoi(toks[0])
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	issue := rp.issue(n)
+	if issue == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	if len(toks) == 1 {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, http.StatusOK, issue)
+		case "PATCH":
+			s.editIssue(w, r, rp, issue)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+
+		return
+	}
+
+	switch toks[1] {
+	case "labels":
+		if r.Method != "POST" {
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		var names []string
+		if err := json.NewDecoder(r.Body).Decode(&names); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		for _, l := range issue.Labels {
+			names = append(names, l.GetName())
+		}
+
+		issue.Labels = rp.labelsFor(uniqueStrings(names))
+		writeJSON(w, http.StatusOK, issue.Labels)
+
+	case "comments":
+		switch r.Method {
+		case "GET":
+			writeJSON(w, http.StatusOK, rp.comments[n])
+
+		case "POST":
+			var c github.IssueComment
+			if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			id := int64(len(rp.comments[n]) + 1)
+			c.ID = &id
+
+			rp.comments[n] = append(rp.comments[n], &c)
+			writeJSON(w, http.StatusCreated, &c)
+
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (s *Server) listIssues(w http.ResponseWriter, r *http.Request, rp *repo) {
+	q := r.URL.Query()
+
+	state := q.Get("state")
+	if state == "" {
+		state = "open"
+	}
+
+	var labels []string
+	if v := q.Get("labels"); v != "" {
+		labels = strings.Split(v, ",")
+	}
+
+	var matched []*github.Issue
+
+	for _, issue := range rp.issues {
+		if state != "all" && issue.GetState() != state {
+			continue
+		}
+
+		if hasLabels(issue, labels) {
+			matched = append(matched, issue)
+		}
+	}
+
+	paginate(w, r, len(matched), func(i, j int) interface{} {
+		page := matched[i:j]
+
+		// Encode an empty array rather than null.
+		if page == nil {
+			page = []*github.Issue{}
+		}
+
+		return page
+	})
+}
+
+func (s *Server) createIssue(w http.ResponseWriter, r *http.Request, rp *repo) {
+	var ir github.IssueRequest
+	if err := json.NewDecoder(r.Body).Decode(&ir); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	num := 1
+	for _, i := range rp.issues {
+		if i.GetNumber() >= num {
+			num = i.GetNumber() + 1
+		}
+	}
+
+	state := "open"
+	url := fmt.Sprintf("%s/issues/%d", s.URL, num)
+
+	issue := &github.Issue{
+		Number:  &num,
+		State:   &state,
+		Title:   ir.Title,
+		Body:    ir.Body,
+		HTMLURL: &url,
+	}
+
+	if ir.Labels != nil {
+		issue.Labels = rp.labelsFor(*ir.Labels)
+	}
+
+	rp.issues = append(rp.issues, issue)
+	writeJSON(w, http.StatusCreated, issue)
+}
+
+func (s *Server) editIssue(w http.ResponseWriter, r *http.Request, rp *repo, issue *github.Issue) {
+	var ir github.IssueRequest
+	if err := json.NewDecoder(r.Body).Decode(&ir); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if ir.State != nil {
+		issue.State = ir.State
+	}
+
+	if ir.Title != nil {
+		issue.Title = ir.Title
+	}
+
+	if ir.Body != nil {
+		issue.Body = ir.Body
+	}
+
+	if ir.Labels != nil {
+		issue.Labels = rp.labelsFor(*ir.Labels)
+	}
+
+	writeJSON(w, http.StatusOK, issue)
+}
+
+func (s *Server) serveLabels(w http.ResponseWriter, r *http.Request, rp *repo, name string) {
+	if name == "" {
+		switch r.Method {
+		case "GET":
+			paginate(w, r, len(rp.labels), func(i, j int) interface{} {
+				page := rp.labels[i:j]
+
+				if page == nil {
+					page = []*github.Label{}
+				}
+
+				return page
+			})
+
+		case "POST":
+			var l github.Label
+			if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			if rp.label(l.GetName()) != nil {
+				writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+				return
+			}
+
+			rp.labels = append(rp.labels, &l)
+			writeJSON(w, http.StatusCreated, &l)
+
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+
+		return
+	}
+
+	label := rp.label(name)
+	if label == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, label)
+
+	case "PATCH":
+		var l github.Label
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if l.Name != nil {
+			label.Name = l.Name
+		}
+
+		if l.Color != nil {
+			label.Color = l.Color
+		}
+
+		// Issues keep renamed labels.
+		for _, issue := range rp.issues {
+			for i, il := range issue.Labels {
+				if strings.EqualFold(il.GetName(), name) {
+					issue.Labels[i] = *label
+				}
+			}
+		}
+
+		writeJSON(w, http.StatusOK, label)
+
+	case "DELETE":
+		for i, l := range rp.labels {
+			if l == label {
+				rp.labels = append(rp.labels[:i], rp.labels[i+1:]...)
+				break
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// hasLabels returns true if the issue has all labels.
+func hasLabels(issue *github.Issue, labels []string) bool {
+	for _, name := range labels {
+		found := false
+
+		for _, l := range issue.Labels {
+			if strings.EqualFold(l.GetName(), name) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func uniqueStrings(a []string) []string {
+	seen := make(map[string]struct{}, len(a))
+
+	var u []string
+
+	for _, s := range a {
+		if _, ok := seen[s]; ok {
+			continue
+		}
+
+		seen[s] = struct{}{}
+		u = append(u, s)
+	}
+
+	sort.Strings(u)
+
+	return u
+}
+
+// NewServer starts a fake GitHub server seeded with the fixtures in dir.
+// The server must be closed when done.
+func NewServer(dir string) *Server {
+	s := &Server{
+		Requests: make(map[string]int),
+		dir:      dir,
+		repos:    make(map[string]*repo),
+	}
+
+	s.Server = httptest.NewServer(s)
+
+	return s
+}
@@ -0,0 +1,82 @@
+package gh
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Task is a unit of work run by a Pool.
+type Task func() error
+
+// Pool runs tasks concurrently using a bounded number of workers.
+type Pool struct {
+	// Workers is the maximum number of tasks run at the same time.
+	Workers int
+
+	// Progress is called after each task completes.
+	Progress func(done, total int)
+}
+
+// Run runs all tasks and blocks until they complete. The returned slice
+// contains the error of each task by position.
+func (p *Pool) Run(tasks []Task) []error {
+	workers := p.Workers
+
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mux  sync.Mutex
+		done int
+	)
+
+	errs := make([]error, len(tasks))
+	queue := make(chan int)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range queue {
+				errs[i] = tasks[i]()
+
+				if p.Progress != nil {
+					mux.Lock()
+					done++
+					p.Progress(done, len(tasks))
+					mux.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range tasks {
+		queue <- i
+	}
+
+	close(queue)
+	wg.Wait()
+
+	return errs
+}
+
+// NewProgress returns a progress function that writes a single updating
+// line to the writer.
+func NewProgress(w io.Writer, label string) func(done, total int) {
+	return func(done, total int) {
+		fmt.Fprintf(w, "\r%s: %d/%d", label, done, total)
+
+		if done == total {
+			fmt.Fprintln(w)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package gh
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Default number of times a request is retried after being rate limited.
+	defaultMaxRetries = 5
+
+	// Default upper bound on a single wait. If GitHub asks for a longer wait
+	// the response is returned as is so the caller can report it.
+	defaultMaxWait = 5 * time.Minute
+)
+
+// cachedResponse is a GET response that was returned with an ETag.
+type cachedResponse struct {
+	etag   string
+	status int
+	header http.Header
+	body   []byte
+}
+
+// response rebuilds the cached response for the request. The rate limit
+// headers of the revalidation response are copied over so the caller sees
+// the current limits rather than the ones at the time of caching.
+func (c *cachedResponse) response(req *http.Request, fresh *http.Response) *http.Response {
+	header := make(http.Header, len(c.header))
+
+	for k, v := range c.header {
+		header[k] = v
+	}
+
+	for k, v := range fresh.Header {
+		if strings.HasPrefix(k, "X-Ratelimit-") {
+			header[k] = v
+		}
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(c.status),
+		StatusCode:    c.status,
+		Proto:         fresh.Proto,
+		ProtoMajor:    fresh.ProtoMajor,
+		ProtoMinor:    fresh.ProtoMinor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+		Request:       req,
+	}
+}
+
+// Transport is an http.RoundTripper that waits and retries requests that
+// were rejected by GitHub's rate limiting and makes GET requests conditional
+// using the ETag of previous responses. Conditional requests that result in
+// a 304 do not count against the rate limit.
+type Transport struct {
+	// Base is the underlying transport. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// MaxRetries is the number of times a request is retried.
+	MaxRetries int
+
+	// MaxWait is the longest the transport will wait before a retry.
+	MaxWait time.Duration
+
+	// Wait is called before sleeping for a retry. It is used to report the
+	// wait to the user.
+	Wait func(req *http.Request, d time.Duration)
+
+	mux   sync.Mutex
+	cache map[string]*cachedResponse
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+
+	return t.Base
+}
+
+func (t *Transport) maxRetries() int {
+	if t.MaxRetries == 0 {
+		return defaultMaxRetries
+	}
+
+	return t.MaxRetries
+}
+
+func (t *Transport) maxWait() time.Duration {
+	if t.MaxWait == 0 {
+		return defaultMaxWait
+	}
+
+	return t.MaxWait
+}
+
+func (t *Transport) lookup(key string) *cachedResponse {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	return t.cache[key]
+}
+
+func (t *Transport) store(key string, c *cachedResponse) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.cache == nil {
+		t.cache = make(map[string]*cachedResponse)
+	}
+
+	t.cache[key] = c
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		key    string
+		cached *cachedResponse
+	)
+
+	if req.Method == "GET" {
+		key = req.URL.String()
+
+		if cached = t.lookup(key); cached != nil {
+			req = cloneRequest(req)
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, errNoRewind
+			}
+
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+
+			req = cloneRequest(req)
+			req.Body = body
+		}
+
+		resp, err := t.base().RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusNotModified && cached != nil {
+			resp.Body.Close()
+			return cached.response(req, resp), nil
+		}
+
+		if wait, ok := retryWait(resp, attempt); ok && attempt < t.maxRetries() && wait <= t.maxWait() {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+
+			if t.Wait != nil {
+				t.Wait(req, wait)
+			}
+
+			select {
+			case <-time.After(wait):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+
+			continue
+		}
+
+		if key != "" && resp.StatusCode == http.StatusOK {
+			if etag := resp.Header.Get("ETag"); etag != "" {
+				body, err := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+
+				t.store(key, &cachedResponse{
+					etag:   etag,
+					status: resp.StatusCode,
+					header: resp.Header,
+					body:   body,
+				})
+
+				resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+// retryWait determines if the response was rate limited and how long to
+// wait before retrying. Secondary (abuse) limits set the Retry-After header,
+// primary limits set X-RateLimit-Remaining to zero and X-RateLimit-Reset to
+// the time the limit resets. A 429 without either falls back to an exponential
+// backoff.
+func retryWait(resp *http.Response, attempt int) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+
+		if t, err := http.ParseTime(v); err == nil {
+			return waitUntil(t), true
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if secs, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			// Add a second to account for clock skew.
+			return waitUntil(time.Unix(secs, 0)) + time.Second, true
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return time.Second << uint(attempt), true
+	}
+
+	return 0, false
+}
+
+func waitUntil(t time.Time) time.Duration {
+	d := t.Sub(time.Now())
+
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
+
+// cloneRequest returns a shallow copy of the request with a copy of the headers.
+func cloneRequest(req *http.Request) *http.Request {
+	r := new(http.Request)
+	*r = *req
+
+	r.Header = make(http.Header, len(req.Header))
+
+	for k, v := range req.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+
+	return r
+}
@@ -0,0 +1,113 @@
+package gh
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransportRetry(t *testing.T) {
+	var calls int
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		if calls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	c := &http.Client{Transport: &Transport{}}
+
+	resp, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestTransportETag(t *testing.T) {
+	var notModified int
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("body"))
+	}))
+	defer ts.Close()
+
+	c := &http.Client{Transport: &Transport{}}
+
+	for i := 0; i < 2; i++ {
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(b) != "body" {
+			t.Errorf("expected cached body, got %q", string(b))
+		}
+	}
+
+	if notModified != 1 {
+		t.Errorf("expected 1 conditional request, got %d", notModified)
+	}
+}
+
+func TestPool(t *testing.T) {
+	var done int
+
+	tasks := make([]Task, 10)
+	out := make([]int, 10)
+
+	for i := range tasks {
+		i := i
+		tasks[i] = func() error {
+			out[i] = i
+			return nil
+		}
+	}
+
+	p := Pool{
+		Workers: 3,
+		Progress: func(n, total int) {
+			done = n
+		},
+	}
+
+	for i, err := range p.Run(tasks) {
+		if err != nil {
+			t.Errorf("task %d: %s", i, err)
+		}
+
+		if out[i] != i {
+			t.Errorf("task %d did not run", i)
+		}
+	}
+
+	if done != 10 {
+		t.Errorf("expected progress of 10, got %d", done)
+	}
+}
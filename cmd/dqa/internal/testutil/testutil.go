@@ -0,0 +1,47 @@
+// Package testutil provides helpers shared by the tests of the dqa commands.
+package testutil
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// CopyDir copies the files in a directory to a temporary directory so tests
+// can modify them. The caller is responsible for removing the directory.
+func CopyDir(t testing.TB, src string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "dqa-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fis, err := ioutil.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fi := range fis {
+		in, err := os.Open(filepath.Join(src, fi.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := os.Create(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := io.Copy(out, in); err != nil {
+			t.Fatal(err)
+		}
+
+		in.Close()
+		out.Close()
+	}
+
+	return dir
+}
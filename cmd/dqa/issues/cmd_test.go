@@ -0,0 +1,107 @@
+package issues
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/gh/ghtest"
+	"github.com/PEDSnet/tools/cmd/dqa/internal/testutil"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+)
+
+func TestMergeIssues(t *testing.T) {
+	srv := ghtest.NewServer("testdata/github")
+	defer srv.Close()
+
+	dir := testutil.CopyDir(t, "testdata/ETLv2")
+	defer os.RemoveAll(dir)
+
+	Cmd.SetArgs([]string{"--dry-run=false", "--format=text", "--token=abc123", "--github-url=" + srv.BaseURL(), "--catalog-cache=" + filepath.Join(dir, "catalog.json"), dir, "testdata/person_issues.csv"})
+	Cmd.SetOutput(ioutil.Discard)
+
+	if err := Cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := results.ReadFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := files["person.csv"].Results
+
+	if len(res) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(res))
+	}
+
+	if res[0].Field != "gender_source_value" || res[0].Status != "new" {
+		t.Errorf("expected new issue for gender_source_value, got %s (%s)", res[0], res[0].Status)
+	}
+
+	// The CA-005 finding is within the thresholds, so the persistent issue
+	// is kept with the latest finding.
+	if res[1].Field != "person_id" || res[1].Status != "persistent" || res[1].Finding != "5% increase" {
+		t.Errorf("expected resolved issue for person_id, got %s (%s, %s)", res[1], res[1].Status, res[1].Finding)
+	}
+}
+
+func TestMergeIssuesDryRun(t *testing.T) {
+	srv := ghtest.NewServer("testdata/github")
+	defer srv.Close()
+
+	dir := testutil.CopyDir(t, "testdata/ETLv2")
+	defer os.RemoveAll(dir)
+
+	before, err := ioutil.ReadFile(filepath.Join(dir, "person.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	Cmd.SetArgs([]string{"--dry-run", "--format=json", "--github-url=" + srv.BaseURL(), "--catalog-cache=" + filepath.Join(dir, "catalog.json"), dir, "testdata/person_issues.csv", "testdata/care_site_issues.csv"})
+	Cmd.SetOutput(&buf)
+
+	if err := Cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	var rep Report
+
+	if err := json.Unmarshal(buf.Bytes(), &rep); err != nil {
+		t.Fatalf("error decoding report: %s\n%s", err, buf.String())
+	}
+
+	counts := rep.Counts()
+
+	for action, n := range map[string]int{
+		ActionAppended:     1,
+		ActionResolved:     1,
+		ActionUnknownTable: 1,
+	} {
+		if counts[action] != n {
+			t.Errorf("expected %d %s, got %d", n, action, counts[action])
+		}
+	}
+
+	if len(rep.Files) != 1 || rep.Files[0].Name != "person.csv" || rep.Files[0].Merged != 2 {
+		t.Errorf("expected 2 issues merged into person.csv, got %+v", rep.Files)
+	}
+
+	after, err := ioutil.ReadFile(filepath.Join(dir, "person.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(before, after) {
+		t.Error("expected person.csv to be unchanged in a dry run")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "care_site.csv")); !os.IsNotExist(err) {
+		t.Error("expected care_site.csv to not be created")
+	}
+}
@@ -0,0 +1,495 @@
+package issues
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/dqa/uni"
+	"github.com/tealeg/xlsx"
+)
+
+// Formats of issue logs.
+const (
+	FormatCSV    = "csv"
+	FormatTSV    = "tsv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatXLSX   = "xlsx"
+)
+
+// Format of issue logs by file extension. Files with other extensions are
+// read as CSV.
+var logFormats = map[string]string{
+	".csv":    FormatCSV,
+	".tsv":    FormatTSV,
+	".tab":    FormatTSV,
+	".json":   FormatJSON,
+	".ndjson": FormatNDJSON,
+	".jsonl":  FormatNDJSON,
+	".xlsx":   FormatXLSX,
+}
+
+// Columns of an issue log.
+const (
+	colDataVersion = "data_version"
+	colTable       = "table"
+	colField       = "field"
+	colCheckCode   = "check_code"
+	colCheckType   = "check_type"
+	colCheckAlias  = "check_alias"
+	colFinding     = "finding"
+	colPrevalence  = "prevalence"
+	colRank        = "rank"
+	colCause       = "cause"
+)
+
+// Required columns in the order they are reported.
+var requiredColumns = []string{
+	colDataVersion,
+	colTable,
+	colField,
+	colCheckCode,
+	colCheckType,
+	colCheckAlias,
+	colFinding,
+	colPrevalence,
+}
+
+// Aliases of the columns. Column names are compared case-insensitively with
+// spaces and dashes treated as underscores.
+var columnAliases = map[string]string{
+	"data_version":      colDataVersion,
+	"g_data_version":    colDataVersion,
+	"table":             colTable,
+	"table_name":        colTable,
+	"field":             colField,
+	"field_name":        colField,
+	"check_code":        colCheckCode,
+	"issue_code":        colCheckCode,
+	"check_type":        colCheckType,
+	"issue_description": colCheckType,
+	"check_description": colCheckType,
+	"check_alias":       colCheckAlias,
+	"alias":             colCheckAlias,
+	"finding":           colFinding,
+	"prevalence":        colPrevalence,
+	"rank":              colRank,
+	"cause":             colCause,
+}
+
+func normalizeColumn(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.Replace(s, " ", "_", -1)
+	return strings.Replace(s, "-", "_", -1)
+}
+
+// LogIssue is an issue read from a log file.
+type LogIssue struct {
+	*results.Result
+
+	File string
+	Line int
+}
+
+// RowError is an error in a row of an issue log.
+type RowError struct {
+	File string
+	Line int
+	Err  string
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+}
+
+// logRecord is a row of an issue log keyed by column.
+type logRecord struct {
+	line   int
+	values map[string]string
+}
+
+// readIssues reads the issues in a log file or the files in a directory. The
+// errors of rows that are invalid are returned along with the valid issues.
+func readIssues(fn string) ([]*LogIssue, []error) {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	// Directory. Read files in directory, but not recursively.
+	if fi.IsDir() {
+		var (
+			allissues []*LogIssue
+			allerrs   []error
+		)
+
+		files, err := ioutil.ReadDir(fn)
+		if err != nil {
+			return nil, []error{fmt.Errorf("error reading directory: %s", err)}
+		}
+
+		for _, fi := range files {
+			// No recursion.
+			if fi.IsDir() {
+				continue
+			}
+
+			issues, errs := readIssues(path.Join(fn, fi.Name()))
+
+			allissues = append(allissues, issues...)
+			allerrs = append(allerrs, errs...)
+		}
+
+		return allissues, allerrs
+	}
+
+	format, ok := logFormats[strings.ToLower(filepath.Ext(fn))]
+	if !ok {
+		format = FormatCSV
+	}
+
+	var records []*logRecord
+
+	if format == FormatXLSX {
+		records, err = readXLSX(fn)
+	} else {
+		records, err = readFile(fn, format)
+	}
+
+	if err != nil {
+		return nil, []error{fmt.Errorf("%s: %s", fn, err)}
+	}
+
+	var (
+		issues []*LogIssue
+		errs   []error
+	)
+
+	for _, rec := range records {
+		res, err := parseRecord(rec)
+		if err != nil {
+			errs = append(errs, &RowError{
+				File: fn,
+				Line: rec.line,
+				Err:  err.Error(),
+			})
+			continue
+		}
+
+		issues = append(issues, &LogIssue{
+			Result: res,
+			File:   fn,
+			Line:   rec.line,
+		})
+	}
+
+	return issues, errs
+}
+
+// readFile reads the records of a text-based issue log.
+func readFile(fn, format string) ([]*logRecord, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch format {
+	case FormatTSV:
+		return readDelimited(f, '\t')
+	case FormatJSON:
+		return readJSON(f)
+	case FormatNDJSON:
+		return readNDJSON(f)
+	}
+
+	return readDelimited(f, ',')
+}
+
+// parseHeader maps the position of each column to the canonical column name.
+// An error is returned if required columns are missing.
+func parseHeader(head []string) ([]string, error) {
+	cols := make([]string, len(head))
+	seen := make(map[string]struct{}, len(head))
+
+	for i, h := range head {
+		if c, ok := columnAliases[normalizeColumn(h)]; ok {
+			cols[i] = c
+			seen[c] = struct{}{}
+		}
+	}
+
+	if err := checkColumns(seen); err != nil {
+		return nil, err
+	}
+
+	return cols, nil
+}
+
+// checkColumns returns an error listing the required columns that were not seen.
+func checkColumns(seen map[string]struct{}) error {
+	var missing []string
+
+	for _, c := range requiredColumns {
+		if _, ok := seen[c]; !ok {
+			missing = append(missing, c)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required columns: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// newRecord maps the values of a row to the columns.
+func newRecord(line int, cols, row []string) *logRecord {
+	rec := &logRecord{
+		line:   line,
+		values: make(map[string]string, len(cols)),
+	}
+
+	for i, c := range cols {
+		if c != "" && i < len(row) {
+			rec.values[c] = strings.TrimSpace(row[i])
+		}
+	}
+
+	return rec
+}
+
+func readDelimited(r io.Reader, comma rune) ([]*logRecord, error) {
+	cr := csv.NewReader(uni.New(r))
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+
+	head, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	cols, err := parseHeader(head)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*logRecord
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		records = append(records, newRecord(line, cols, row))
+	}
+
+	return records, nil
+}
+
+func readXLSX(fn string) ([]*logRecord, error) {
+	sheets, err := xlsx.FileToSlice(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Issues are expected on the first sheet.
+	if len(sheets) == 0 || len(sheets[0]) == 0 {
+		return nil, nil
+	}
+
+	rows := sheets[0]
+
+	cols, err := parseHeader(rows[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*logRecord
+
+	for i, row := range rows[1:] {
+		// Skip blank rows.
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+
+		// Row numbers start at 1 and the first is the header.
+		records = append(records, newRecord(i+2, cols, row))
+	}
+
+	return records, nil
+}
+
+// jsonRecord converts the values of a JSON object to a record.
+func jsonRecord(line int, obj map[string]interface{}) *logRecord {
+	rec := &logRecord{
+		line:   line,
+		values: make(map[string]string, len(obj)),
+	}
+
+	for k, v := range obj {
+		c, ok := columnAliases[normalizeColumn(k)]
+		if !ok || v == nil {
+			continue
+		}
+
+		rec.values[c] = strings.TrimSpace(fmt.Sprint(v))
+	}
+
+	return rec
+}
+
+// checkRecordColumns checks the required columns are present in the first
+// record. JSON records do not have a header so the columns of each record
+// may differ.
+func checkRecordColumns(records []*logRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]struct{})
+
+	for c := range records[0].values {
+		seen[c] = struct{}{}
+	}
+
+	return checkColumns(seen)
+}
+
+// readJSON reads an array of objects. The line of each record is its
+// position in the array.
+func readJSON(r io.Reader) ([]*logRecord, error) {
+	var objs []map[string]interface{}
+
+	if err := json.NewDecoder(uni.New(r)).Decode(&objs); err != nil {
+		return nil, err
+	}
+
+	records := make([]*logRecord, len(objs))
+
+	for i, obj := range objs {
+		records[i] = jsonRecord(i+1, obj)
+	}
+
+	return records, checkRecordColumns(records)
+}
+
+// readNDJSON reads one object per line.
+func readNDJSON(r io.Reader) ([]*logRecord, error) {
+	var records []*logRecord
+
+	sc := bufio.NewScanner(uni.New(r))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+
+	for sc.Scan() {
+		line++
+
+		b := strings.TrimSpace(sc.Text())
+		if b == "" {
+			continue
+		}
+
+		var obj map[string]interface{}
+
+		if err := json.Unmarshal([]byte(b), &obj); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+
+		records = append(records, jsonRecord(line, obj))
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, checkRecordColumns(records)
+}
+
+// parseRecord validates a record and converts it into a result.
+func parseRecord(rec *logRecord) (*results.Result, error) {
+	v := rec.values
+
+	// The field column is required, but is empty for issues on a table.
+	for _, c := range []string{colDataVersion, colTable, colCheckCode} {
+		if v[c] == "" {
+			return nil, fmt.Errorf("%s is required", c)
+		}
+	}
+
+	// Data version is of the form <model>-<version>-<site>-<etl>.
+	toks := strings.Split(v[colDataVersion], "-")
+	if len(toks) < 4 {
+		return nil, fmt.Errorf("invalid data version '%s'", v[colDataVersion])
+	}
+
+	if p := v[colPrevalence]; p != "" && !inStringSlice(p, results.Prevalences) {
+		return nil, fmt.Errorf("invalid prevalence '%s'", p)
+	}
+
+	res := &results.Result{
+		Model:        toks[0],
+		ModelVersion: toks[1],
+		DataVersion:  v[colDataVersion],
+		DQAVersion:   "0",
+		Table:        v[colTable],
+		Field:        v[colField],
+		CheckCode:    v[colCheckCode],
+		CheckAlias:   v[colCheckAlias],
+		CheckType:    v[colCheckType],
+		Finding:      v[colFinding],
+		Prevalence:   v[colPrevalence],
+		Status:       "new",
+		Method:       "auto",
+	}
+
+	switch v[colRank] {
+	case "":
+	case "High":
+		res.Rank = results.HighRank
+	case "Medium":
+		res.Rank = results.MediumRank
+	case "Low":
+		res.Rank = results.LowRank
+	default:
+		return nil, fmt.Errorf("invalid rank '%s'", v[colRank])
+	}
+
+	if c := v[colCause]; c != "" {
+		if !inStringSlice(c, results.Causes) {
+			return nil, fmt.Errorf("invalid cause '%s'", c)
+		}
+
+		res.Cause = c
+	}
+
+	res.SetFileVersion(4)
+
+	return res, nil
+}
+
+func inStringSlice(s string, a []string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+
+	return false
+}
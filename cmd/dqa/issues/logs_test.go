@@ -0,0 +1,153 @@
+package issues
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/tealeg/xlsx"
+)
+
+func checkIssue(t *testing.T, issue *LogIssue) {
+	if issue.Model != "pedsnet" || issue.ModelVersion != "2.2.0" {
+		t.Errorf("expected model pedsnet/2.2.0, got %s/%s", issue.Model, issue.ModelVersion)
+	}
+
+	if issue.Table != "person" || issue.Field != "gender_source_value" || issue.CheckCode != "BA-001" {
+		t.Errorf("unexpected issue %s %s", issue, issue.CheckCode)
+	}
+
+	if issue.CheckAlias != "missing_data" || issue.CheckType != "Missing Data" || issue.Finding != "20% missing" {
+		t.Errorf("unexpected check %s/%s: %s", issue.CheckAlias, issue.CheckType, issue.Finding)
+	}
+
+	if issue.Status != "new" {
+		t.Errorf("expected new status, got %s", issue.Status)
+	}
+}
+
+func TestReadIssuesCSV(t *testing.T) {
+	fn := "testdata/logs/issues.csv"
+
+	issues, errs := readIssues(fn)
+
+	if len(issues) != 2 {
+		t.Fatalf("expected 2 issues, got %d", len(issues))
+	}
+
+	checkIssue(t, issues[0])
+
+	if issues[0].Rank != results.HighRank || issues[0].Line != 2 {
+		t.Errorf("expected high rank on line 2, got %s on line %d", issues[0].Rank, issues[0].Line)
+	}
+
+	// Issues on a table have an empty field.
+	if issues[1].Table != "person" || issues[1].Field != "" || issues[1].Line != 5 {
+		t.Errorf("expected table issue on line 5, got %s.%s on line %d", issues[1].Table, issues[1].Field, issues[1].Line)
+	}
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(errs))
+	}
+
+	exp := []string{
+		fn + ":3: table is required",
+		fn + ":4: invalid prevalence 'lots'",
+	}
+
+	for i, err := range errs {
+		if err.Error() != exp[i] {
+			t.Errorf("expected error `%s`, got `%s`", exp[i], err)
+		}
+	}
+}
+
+func TestReadIssuesFormats(t *testing.T) {
+	for _, fn := range []string{
+		"testdata/logs/issues.tsv",
+		"testdata/logs/issues.json",
+		"testdata/logs/issues.ndjson",
+	} {
+		issues, errs := readIssues(fn)
+
+		if len(issues) != 1 {
+			t.Errorf("%s: expected 1 issue, got %d", fn, len(issues))
+			continue
+		}
+
+		checkIssue(t, issues[0])
+
+		if strings.HasSuffix(fn, ".json") && issues[0].Cause != "ETL: programming error" {
+			t.Errorf("%s: expected cause to be read, got `%s`", fn, issues[0].Cause)
+		}
+
+		if strings.HasSuffix(fn, ".ndjson") {
+			if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), fn+":3: invalid data version") {
+				t.Errorf("%s: expected data version error on line 3, got %v", fn, errs)
+			}
+		} else if len(errs) != 0 {
+			t.Errorf("%s: unexpected errors %v", fn, errs)
+		}
+	}
+}
+
+func TestReadIssuesXLSX(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqa-issues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := xlsx.NewFile()
+
+	sheet, err := f.AddSheet("Issues")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, row := range [][]string{
+		{"DATA_VERSION", "TABLE", "FIELD", "CHECK_CODE", "CHECK_TYPE", "CHECK_ALIAS", "FINDING", "PREVALENCE"},
+		{"pedsnet-2.2.0-CHOP-ETLv2", "person", "gender_source_value", "BA-001", "Missing Data", "missing_data", "20% missing", "medium"},
+	} {
+		r := sheet.AddRow()
+
+		for _, v := range row {
+			r.AddCell().SetString(v)
+		}
+	}
+
+	fn := filepath.Join(dir, "issues.xlsx")
+
+	if err := f.Save(fn); err != nil {
+		t.Fatal(err)
+	}
+
+	issues, errs := readIssues(fn)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	if len(issues) != 1 {
+		t.Fatalf("expected 1 issue, got %d", len(issues))
+	}
+
+	checkIssue(t, issues[0])
+}
+
+func TestReadIssuesMissingColumns(t *testing.T) {
+	_, errs := readIssues("testdata/logs/missing_columns.csv")
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(errs))
+	}
+
+	exp := "testdata/logs/missing_columns.csv: missing required columns: check_type, check_alias, prevalence"
+
+	if errs[0].Error() != exp {
+		t.Errorf("expected error `%s`, got `%s`", exp, errs[0])
+	}
+}
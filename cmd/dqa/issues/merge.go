@@ -0,0 +1,330 @@
+package issues
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
+	"github.com/PEDSnet/tools/cmd/dqa/models"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/internal/datamodels"
+)
+
+// MergeOptions are the options of a merge.
+type MergeOptions struct {
+	// Dir is the directory of the Secondary Report.
+	Dir string
+
+	// Logs are the issue logs or directories of logs to merge.
+	Logs []string
+
+	// DryRun reports the changes without writing the files.
+	DryRun bool
+
+	// CreateMissing creates report files for tables that are defined in the
+	// model revision of the issue.
+	CreateMissing bool
+
+	// Models provides the model revisions used to create missing files.
+	Models *datamodels.Provider
+
+	// Catalog are the options to load the DQA catalog used to resolve
+	// conflicts. It is only loaded if there are conflicts.
+	Catalog catalog.Options
+
+	// Program is an external program that resolves conflicts. It overrides
+	// the native resolvers.
+	Program string
+
+	// Resolvers is the path of the resolver modules of the program.
+	Resolvers string
+}
+
+// merger holds the state of a merge.
+type merger struct {
+	opts  MergeOptions
+	files map[string]*results.File
+	rep   *Report
+
+	// Tables by model revision used to create missing files.
+	modelTables map[string]map[string]struct{}
+}
+
+// Merge merges the issues in the logs into the Secondary Report. Rows of
+// the logs that are invalid are skipped and listed in the report.
+func Merge(opts MergeOptions) (*Report, error) {
+	// Map of results files by filename.
+	// Each filename corresponds to a table name.
+	files, err := results.ReadFromDir(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading files in '%s': %s", opts.Dir, err)
+	}
+
+	if opts.Models == nil {
+		opts.Models = &datamodels.Provider{}
+	}
+
+	m := &merger{
+		opts:  opts,
+		files: files,
+		rep: &Report{
+			DryRun:  opts.DryRun,
+			Entries: []*ReportEntry{},
+			Files:   []*ReportFile{},
+		},
+		modelTables: make(map[string]map[string]struct{}),
+	}
+
+	rep := m.rep
+
+	// Count of issues merged by file name.
+	merged := make(map[string]int)
+	appendMerge := make(map[string][]*results.Result)
+
+	// Files created for tables without a report file.
+	created := make(map[string]bool)
+
+	var conflicts []*Conflict
+
+	// Process all files
+	for _, fn := range opts.Logs {
+		issues, errs := readIssues(fn)
+
+		// Invalid rows are skipped.
+		for _, err := range errs {
+			rep.Errors = append(rep.Errors, err.Error())
+		}
+
+		for _, issue := range issues {
+			lookup := fmt.Sprintf("%s.csv", issue.Table)
+
+			c := &Conflict{
+				Log:       issue.Result,
+				LogFile:   issue.File,
+				CheckCode: issue.CheckCode,
+				Table:     issue.Table,
+				Field:     issue.Field,
+				Lookup:    lookup,
+			}
+
+			report, ok := files[lookup]
+			if !ok {
+				if err := m.createFile(issue.Result); err != nil {
+					rep.add(ActionUnknownTable, issue.File, c, err.Error())
+					continue
+				}
+
+				report = results.NewFile(lookup)
+				files[lookup] = report
+				created[lookup] = true
+			}
+
+			var found bool
+
+			// Scan results for a match. If none is found, add it.
+			for i, r := range report.Results {
+				// Ensure we are comparing the correct result.
+				if r.Model != issue.Model || r.ModelVersion != issue.ModelVersion || r.DataVersion != issue.DataVersion || r.Table != issue.Table {
+					return nil, fmt.Errorf("Issue in '%s' on line %d is for %s, but '%s' is for %s", issue.File, issue.Line, issue.DataVersion, lookup, r.DataVersion)
+				}
+
+				if r.Field == issue.Field && r.CheckCode == issue.CheckCode {
+					if r.IsUnresolved() || r.IsPersistent() {
+						c.Index = i
+						c.Secondary = r
+						conflicts = append(conflicts, c)
+					} else {
+						rep.add(ActionDuplicate, issue.File, c, fmt.Sprintf("Existing issue has status '%s'", r.Status))
+					}
+
+					found = true
+					break
+				}
+			}
+
+			// If the issue was not found, append it to the results to be written.
+			if !found {
+				merged[lookup] += 1
+				appendMerge[lookup] = append(appendMerge[lookup], issue.Result)
+				rep.add(ActionAppended, issue.File, c, "")
+			}
+		}
+	}
+
+	// Resolve conflicts using the thresholds in the DQA catalog.
+	if len(conflicts) > 0 {
+		if err := m.resolve(conflicts, merged); err != nil {
+			return nil, err
+		}
+	}
+
+	// Append new issues.
+	for lookup, issues := range appendMerge {
+		file := files[lookup]
+		file.Results = append(file.Results, issues...)
+	}
+
+	// Sorted for consistent output.
+	var names []string
+
+	for name := range merged {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		rf := &ReportFile{
+			Name:    name,
+			Merged:  merged[name],
+			Created: created[name],
+		}
+
+		rep.Files = append(rep.Files, rf)
+
+		if opts.DryRun {
+			continue
+		}
+
+		if err := results.WriteFile(filepath.Join(opts.Dir, name), files[name]); err != nil {
+			rf.Error = err.Error()
+		}
+	}
+
+	return rep, nil
+}
+
+// resolve resolves the conflicts and updates the merged count by file name.
+func (m *merger) resolve(conflicts []*Conflict, merged map[string]int) error {
+	rep := m.rep
+
+	cat, err := catalog.Load(m.opts.Catalog)
+	if err != nil {
+		return fmt.Errorf("Error loading catalog: %s", err)
+	}
+
+	var queued []*Conflict
+
+	for _, c := range conflicts {
+		if !cat.HasThresholds(c.CheckCode) {
+			rep.add(ActionUnresolved, c.LogFile, c, "No thresholds in the DQA catalog")
+			continue
+		}
+
+		// Set the thresholds.
+		if thres := cat.Threshold(c.CheckCode, c.Table, c.Field); thres != nil {
+			c.UpperThreshold = thres.Upper
+			c.LowerThreshold = thres.Lower
+			c.Threshold = thres
+		}
+
+		queued = append(queued, c)
+	}
+
+	if len(queued) == 0 {
+		return nil
+	}
+
+	var resolvedConflicts []*resolveResult
+
+	// Map output by position. The external program overrides the
+	// native resolvers if set.
+	if m.opts.Program != "" {
+		resolvedConflicts, err = runResolve(m.opts.Program, m.opts.Resolvers, queued)
+	} else {
+		resolvedConflicts = resolveAll(queued)
+	}
+
+	if err != nil {
+		for _, c := range queued {
+			rep.add(ActionUnresolved, c.LogFile, c, err.Error())
+		}
+
+		return nil
+	}
+
+	// Lookup of all resolved issues to compare with updated results
+	// in the existing files.
+	resolvedIndex := make(map[*results.Result]struct{})
+
+	for i, c := range queued {
+		resolved := resolvedConflicts[i]
+
+		if resolved.Error != "" {
+			rep.add(ActionUnresolved, c.LogFile, c, resolved.Error)
+			continue
+		}
+
+		// No change.
+		if len(resolved.Issues) == 0 {
+			rep.add(ActionResolved, c.LogFile, c, "No change")
+			continue
+		}
+
+		// Update the existing issue.
+		file := m.files[c.Lookup]
+
+		// Replace existing issue if it has not already been replaced
+		// otherwise append to the set. This can occur if there are two
+		// issues record for the same field which would be associated with
+		// the same index of the original file.
+		if _, ok := resolvedIndex[file.Results[c.Index]]; !ok {
+			file.Results[c.Index] = resolved.Issues[0]
+			resolvedIndex[resolved.Issues[0]] = struct{}{}
+		} else {
+			file.Results = append(file.Results, resolved.Issues[0])
+		}
+
+		var msg string
+
+		// Append new ones and update the merged count.
+		if len(resolved.Issues) > 1 {
+			file.Results = append(file.Results, resolved.Issues[1:]...)
+			msg = fmt.Sprintf("Appended %d additional issue(s)", len(resolved.Issues)-1)
+		}
+
+		rep.add(ActionResolved, c.LogFile, c, msg)
+
+		merged[c.Lookup] += len(resolved.Issues)
+	}
+
+	return nil
+}
+
+// createFile checks whether a report file can be created for the table of
+// the issue. The table must exist in the model revision of the issue.
+func (m *merger) createFile(issue *results.Result) error {
+	if !m.opts.CreateMissing {
+		return fmt.Errorf("No report file for table %s", issue.Table)
+	}
+
+	key := fmt.Sprintf("%s/%s", issue.Model, issue.ModelVersion)
+
+	tables, ok := m.modelTables[key]
+
+	if !ok {
+		model, err := models.Revision(m.opts.Models, issue.Model, issue.ModelVersion)
+		if err != nil {
+			return fmt.Errorf("Error fetching model revision '%s': %s", key, err)
+		}
+
+		tables = make(map[string]struct{})
+
+		for _, t := range model.Tables.List() {
+			tables[t.Name] = struct{}{}
+		}
+
+		m.modelTables[key] = tables
+	}
+
+	if _, ok := tables[issue.Table]; !ok {
+		return fmt.Errorf("Table %s is not defined in model %s", issue.Table, key)
+	}
+
+	if _, ok := results.ExcludedTables[issue.Table]; ok {
+		return fmt.Errorf("Table %s is excluded from reports", issue.Table)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package issues
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
+	"github.com/PEDSnet/tools/cmd/dqa/internal/testutil"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/internal/datamodels"
+)
+
+func TestMergeCreateMissing(t *testing.T) {
+	dir := testutil.CopyDir(t, "testdata/ETLv2")
+	defer os.RemoveAll(dir)
+
+	rep, err := Merge(MergeOptions{
+		Dir:           dir,
+		Logs:          []string{"testdata/care_site_issues.csv", "testdata/logs/missing_columns.csv"},
+		CreateMissing: true,
+		Models: &datamodels.Provider{
+			File: "testdata/pedsnet-2.2.0.json",
+		},
+		Catalog: catalog.Options{
+			Cache: filepath.Join(dir, "catalog.json"),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rep.Files) != 1 || rep.Files[0].Name != "care_site.csv" || !rep.Files[0].Created {
+		t.Fatalf("expected care_site.csv to be created, got %+v", rep.Files)
+	}
+
+	if len(rep.Errors) != 1 {
+		t.Errorf("expected 1 error for the log with missing columns, got %v", rep.Errors)
+	}
+
+	files, err := results.ReadFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(files["care_site.csv"].Results); n != 1 {
+		t.Errorf("expected 1 issue in care_site.csv, got %d", n)
+	}
+}
+
+func TestMergeVersionMismatch(t *testing.T) {
+	dir := testutil.CopyDir(t, "testdata/ETLv2")
+	defer os.RemoveAll(dir)
+
+	logs, err := ioutil.TempDir("", "dqa-issues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(logs)
+
+	fn := filepath.Join(logs, "issues.csv")
+
+	ioutil.WriteFile(fn, []byte(`data_version,table,field,check_code,check_type,check_alias,finding,prevalence
+pedsnet-2.2.0-CHOP-ETLv3,person,person_id,CA-005,Unexpected change,num_records,5% increase,medium
+`), 0644)
+
+	_, err = Merge(MergeOptions{
+		Dir:  dir,
+		Logs: []string{fn},
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "is for pedsnet-2.2.0-CHOP-ETLv3") {
+		t.Errorf("expected error merging issues of another data version, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package issues
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Actions taken for an issue during a merge.
+const (
+	ActionAppended     = "appended"
+	ActionDuplicate    = "duplicate"
+	ActionResolved     = "resolved"
+	ActionUnresolved   = "unresolved"
+	ActionUnknownTable = "unknown table"
+)
+
+// Order the actions are presented in.
+var reportActions = []string{
+	ActionAppended,
+	ActionDuplicate,
+	ActionResolved,
+	ActionUnresolved,
+	ActionUnknownTable,
+}
+
+// ReportEntry is the outcome of merging a single issue.
+type ReportEntry struct {
+	Action    string `json:"action"`
+	Log       string `json:"log"`
+	Table     string `json:"table"`
+	Field     string `json:"field"`
+	CheckCode string `json:"check_code"`
+	Message   string `json:"message,omitempty"`
+}
+
+// ReportFile is a report file that was changed by the merge.
+type ReportFile struct {
+	Name    string `json:"name"`
+	Merged  int    `json:"merged"`
+	Created bool   `json:"created,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report describes the outcome of a merge.
+type Report struct {
+	DryRun  bool           `json:"dry_run"`
+	Entries []*ReportEntry `json:"entries"`
+	Files   []*ReportFile  `json:"files"`
+
+	// Errors of invalid rows in the logs that were skipped.
+	Errors []string `json:"errors,omitempty"`
+}
+
+func (r *Report) add(action, log string, c *Conflict, msg string) {
+	r.Entries = append(r.Entries, &ReportEntry{
+		Action:    action,
+		Log:       log,
+		Table:     c.Table,
+		Field:     c.Field,
+		CheckCode: c.CheckCode,
+		Message:   msg,
+	})
+}
+
+// Counts returns the number of entries by action.
+func (r *Report) Counts() map[string]int {
+	counts := make(map[string]int, len(reportActions))
+
+	for _, e := range r.Entries {
+		counts[e.Action]++
+	}
+
+	return counts
+}
+
+// WriteText writes the report grouped by action.
+func (r *Report) WriteText(w io.Writer) {
+	counts := r.Counts()
+
+	for _, action := range reportActions {
+		if counts[action] == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "%s (%d):\n", action, counts[action])
+
+		for _, e := range r.Entries {
+			if e.Action != action {
+				continue
+			}
+
+			fmt.Fprintf(w, "* %s/%s for issue code %s (%s)\n", e.Table, e.Field, e.CheckCode, e.Log)
+
+			if e.Message != "" {
+				fmt.Fprintf(w, "    %s\n", e.Message)
+			}
+		}
+
+		fmt.Fprintln(w, "")
+	}
+
+	if len(r.Files) == 0 {
+		fmt.Fprintln(w, "No new issues found.")
+		return
+	}
+
+	for _, f := range r.Files {
+		switch {
+		case f.Error != "":
+			fmt.Fprintf(w, "Error writing %s: %s\n", f.Name, f.Error)
+		case r.DryRun && f.Created:
+			fmt.Fprintf(w, "Would create %s and merge %d issues\n", f.Name, f.Merged)
+		case r.DryRun:
+			fmt.Fprintf(w, "Would merge %d issues into %s\n", f.Merged, f.Name)
+		case f.Created:
+			fmt.Fprintf(w, "Created %s and merged %d issues\n", f.Name, f.Merged)
+		default:
+			fmt.Fprintf(w, "Merged %d issues into %s\n", f.Merged, f.Name)
+		}
+	}
+}
+
+// WriteJSON writes the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,123 @@
+package issues
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+)
+
+// A Resolver resolves a conflict between an issue in a log file and an
+// existing issue in the secondary report. It returns the issues that
+// replace the existing one. The first issue replaces the existing issue and
+// the remaining ones are appended. No issues means no change.
+type Resolver func(c *Conflict) ([]*results.Result, error)
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = make(map[string]Resolver)
+)
+
+// RegisterResolver registers a resolver for a check code. Conflicts for
+// check codes without a registered resolver are resolved using
+// ThresholdResolver.
+func RegisterResolver(code string, r Resolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+
+	resolvers[code] = r
+}
+
+// Resolve resolves the conflict using the resolver registered for its
+// check code.
+func Resolve(c *Conflict) ([]*results.Result, error) {
+	resolversMu.RLock()
+	r, ok := resolvers[c.CheckCode]
+	resolversMu.RUnlock()
+
+	if !ok {
+		r = ThresholdResolver
+	}
+
+	return r(c)
+}
+
+var findingValueRe = regexp.MustCompile(`-?\d+(\.\d+)?`)
+
+// parseFinding parses the numeric value of a finding such as "12% increase"
+// or "-5%". A finding describing a decrease is negative.
+func parseFinding(finding string) (float64, error) {
+	m := findingValueRe.FindString(finding)
+	if m == "" {
+		return 0, fmt.Errorf("No numeric value in finding `%s`", finding)
+	}
+
+	v, err := strconv.ParseFloat(m, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid numeric value in finding `%s`", finding)
+	}
+
+	if v > 0 && strings.Contains(strings.ToLower(finding), "decrease") {
+		v = -v
+	}
+
+	return v, nil
+}
+
+// ThresholdResolver compares the finding of the logged issue with the
+// thresholds of the check. If the finding is within the thresholds, the
+// existing issue persists and is updated with the latest finding and
+// prevalence. Otherwise the logged issue is appended as a new issue and
+// the existing issue is kept as is.
+func ThresholdResolver(c *Conflict) ([]*results.Result, error) {
+	if c.Threshold == nil {
+		return nil, fmt.Errorf("No thresholds defined for %s/%s", c.Table, c.Field)
+	}
+
+	v, err := parseFinding(c.Log.Finding)
+	if err != nil {
+		return nil, err
+	}
+
+	if v < float64(c.Threshold.Lower) || v > float64(c.Threshold.Upper) {
+		return []*results.Result{c.Secondary, c.Log}, nil
+	}
+
+	r := *c.Secondary
+	r.Finding = c.Log.Finding
+	r.Prevalence = c.Log.Prevalence
+
+	return []*results.Result{&r}, nil
+}
+
+// resolveAll resolves the conflicts using the registered resolvers. The
+// output is mapped by position.
+func resolveAll(conflicts []*Conflict) []*resolveResult {
+	out := make([]*resolveResult, len(conflicts))
+
+	for i, c := range conflicts {
+		issues, err := Resolve(c)
+
+		if err != nil {
+			out[i] = &resolveResult{
+				Error: err.Error(),
+			}
+			continue
+		}
+
+		for _, r := range issues {
+			if r != c.Secondary {
+				r.SetFileVersion(c.Log.FileVersion())
+			}
+		}
+
+		out[i] = &resolveResult{
+			Issues: issues,
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,79 @@
+package issues
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+)
+
+func TestThresholdResolver(t *testing.T) {
+	tests := []struct {
+		Finding string
+		Issues  int
+	}{
+		{"5% increase", 1},
+		{"8% decrease", 1},
+		{"-12%", 2},
+		{"15% increase", 2},
+	}
+
+	for _, test := range tests {
+		c := &Conflict{
+			CheckCode: "CA-005",
+			Table:     "person",
+			Field:     "person_id",
+			Log:       &results.Result{Finding: test.Finding, Prevalence: "low"},
+			Secondary: &results.Result{Finding: "12% increase", Status: "persistent", GithubID: "4"},
+			Threshold: &catalog.Threshold{Lower: -10, Upper: 10},
+		}
+
+		issues, err := ThresholdResolver(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(issues) != test.Issues {
+			t.Errorf("%s: expected %d issues, got %d", test.Finding, test.Issues, len(issues))
+			continue
+		}
+
+		if issues[0].GithubID != "4" {
+			t.Errorf("%s: expected existing issue to be kept", test.Finding)
+		}
+
+		if test.Issues == 1 && issues[0].Finding != test.Finding {
+			t.Errorf("%s: expected finding to be updated, got %s", test.Finding, issues[0].Finding)
+		}
+	}
+}
+
+func TestThresholdResolverErrors(t *testing.T) {
+	c := &Conflict{
+		Log:       &results.Result{Finding: "many"},
+		Secondary: &results.Result{},
+	}
+
+	if _, err := ThresholdResolver(c); err == nil {
+		t.Error("expected error without thresholds")
+	}
+
+	c.Threshold = &catalog.Threshold{}
+
+	if _, err := ThresholdResolver(c); err == nil {
+		t.Error("expected error for non-numeric finding")
+	}
+}
+
+func TestRegisterResolver(t *testing.T) {
+	RegisterResolver("ZZ-001", func(c *Conflict) ([]*results.Result, error) {
+		return nil, errors.New("custom")
+	})
+
+	out := resolveAll([]*Conflict{{CheckCode: "ZZ-001"}})
+
+	if out[0].Error != "custom" {
+		t.Errorf("expected registered resolver to be used, got `%s`", out[0].Error)
+	}
+}
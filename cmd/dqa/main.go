@@ -3,12 +3,16 @@ package main
 import (
 	"os"
 
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
 	"github.com/PEDSnet/tools/cmd/dqa/feedback"
 	"github.com/PEDSnet/tools/cmd/dqa/generate"
 	"github.com/PEDSnet/tools/cmd/dqa/issues"
 	"github.com/PEDSnet/tools/cmd/dqa/migrate"
+	"github.com/PEDSnet/tools/cmd/dqa/models"
 	"github.com/PEDSnet/tools/cmd/dqa/query"
 	"github.com/PEDSnet/tools/cmd/dqa/rank"
+	"github.com/PEDSnet/tools/cmd/dqa/serve"
+	"github.com/PEDSnet/tools/cmd/dqa/upgrade"
 	"github.com/PEDSnet/tools/cmd/dqa/validate"
 	"github.com/blang/semver"
 	"github.com/spf13/cobra"
@@ -64,6 +68,10 @@ func main() {
 	mainCmd.AddCommand(query.Cmd)
 	mainCmd.AddCommand(issues.Cmd)
 	mainCmd.AddCommand(migrate.Cmd)
+	mainCmd.AddCommand(catalog.Cmd)
+	mainCmd.AddCommand(models.Cmd)
+	mainCmd.AddCommand(upgrade.Cmd)
+	mainCmd.AddCommand(serve.Cmd)
 
 	mainCmd.Execute()
 }
@@ -4,89 +4,33 @@ import (
 	"bufio"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
 	"github.com/PEDSnet/tools/cmd/dqa/uni"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
-var codeMapping = map[string][]string{
-	"G1-002": {"CA-014", "CA-015"},
-	"G1-003": {"AA-003"},
-	"G2-002": {"CA-010"},
-	"G2-003": {"CA-003", "CA-004"},
-	"G2-004": {"AA-001"},
-	"G2-005": {"CA-007"},
-	"G2-006": {"AA-002"},
-	"G2-007": {"CA-011"},
-	"G2-008": {"CA-008", "CA-009"},
-	"G2-009": {"CA-001"},
-	"G2-010": {"CA-002"},
-	"G2-011": {"AA-004"},
-	"G2-012": {"CA-012"},
-	"G2-013": {"CA-005", "CA-006"},
-	"G2-014": {"CA-013"},
-	"G3-002": {"CA-012"},
-	"G3-003": {"CB-001"},
-	"G3-005": {"CA-013"},
-	"G4-002": {"BA-001"},
-}
-
-var newCodesCatalog = map[string]string{
-	"AA-001": "Value set violation",
-	"AA-002": "Illegal concept identifier",
-	"AA-003": "Inconsistency between PK and source value",
-	"AA-004": "Unexpected fact",
-	"AA-005": "Incorrect concept id vocabulary",
-	"AA-006": "Violate inclusion criteria",
-	"BA-001": "Missing Data",
-	"BA-002": "No matching concepts",
-	"BA-003": "Missing Expected Concept",
-	"BA-004": "Insufficient facts for visits",
-	"CA-001": "Future event",
-	"CA-002": "Past event",
-	"CA-003": "Pre-birth fact",
-	"CA-004": "Post-death fact",
-	"CA-005": "Unexpected change in number of records between data cycles",
-	"CA-006": "Unexpected change in missingness of a field between data cycles",
-	"CA-007": "Identification of entity outliers",
-	"CA-008": "Identifcation of specific dates with high number of facts",
-	"CA-009": "Identifcation of sudden change in distribution of facts",
-	"CA-010": "Total number of records is too low",
-	"CA-011": "Implausible Numerical Values",
-	"CA-012": "Unexpected field distribution",
-	"CA-013": "Unexpected most frequent values",
-	"CA-014": "Inconsistency of null values between source values and concept ids",
-	"CA-015": "Inconsistency of most frequent values between source values and concept ids",
-	"CB-001": "Unexpected fact to patient ratio",
-}
-
-var allNewCodes []string
-
 func init() {
-	for code, _ := range newCodesCatalog {
-		allNewCodes = append(allNewCodes, code)
-	}
+	flags := Cmd.Flags()
+	flags.StringSlice("mapping", nil, "Mapping files applied in order. Defaults to the G code mapping.")
+	viper.BindPFlag("migrate.mapping", flags.Lookup("mapping"))
 
-	// Sort for better presentation.
-	sort.Strings(allNewCodes)
-	for _, a := range codeMapping {
-		sort.Strings(a)
-	}
-}
+	flags.String("decisions", "", "File the choices are recorded in and replayed from.")
+	flags.String("rules", "", "JSON file of rules that choose a code by matching the finding.")
+	flags.Bool("non-interactive", false, "Do not prompt. Rows that cannot be mapped are left unmapped.")
 
-func indexOf(s string, a []string) int {
-	for i, x := range a {
-		if x == s {
-			return i
-		}
-	}
-	return -1
+	viper.BindPFlag("migrate.decisions", flags.Lookup("decisions"))
+	viper.BindPFlag("migrate.rules", flags.Lookup("rules"))
+	viper.BindPFlag("migrate.non-interactive", flags.Lookup("non-interactive"))
+
+	catalog.BindFlags(flags, "migrate")
 }
 
 func readInput(prompt string) string {
@@ -105,7 +49,14 @@ var Cmd = &cobra.Command{
   pedsnet-dqa migrate-codes SecondaryReports/CHOP/ETLv5
 	
 Migrate issue codes in the ranking rules.
-  pedsnet-dqa migrate-codes SecondaryReports/Ranking`,
+  pedsnet-dqa migrate-codes SecondaryReports/Ranking
+
+Record choices and replay them without prompting:
+  pedsnet-dqa migrate-codes --decisions=decisions.csv SecondaryReports/CHOP/ETLv5
+  pedsnet-dqa migrate-codes --decisions=decisions.csv --non-interactive SecondaryReports/CHOP/ETLv5
+
+Apply a chain of mapping files:
+  pedsnet-dqa migrate-codes --mapping=v1.json --mapping=v2.json SecondaryReports/CHOP/ETLv5`,
 
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) == 0 {
@@ -113,6 +64,52 @@ Migrate issue codes in the ranking rules.
 			os.Exit(1)
 		}
 
+		// Descriptions of the new codes are taken from the local or cached catalog.
+		cat, err := catalog.Load(catalog.ConfigOptions("migrate"))
+		if err != nil {
+			cmd.Printf("Error loading catalog: %s\n", err)
+			os.Exit(1)
+		}
+
+		migrations := []*Migration{legacyMigration}
+
+		if paths := viper.GetStringSlice("migrate.mapping"); len(paths) > 0 {
+			migrations = nil
+
+			for _, p := range paths {
+				m, err := ReadMigration(p)
+				if err != nil {
+					cmd.Println(err)
+					os.Exit(1)
+				}
+
+				migrations = append(migrations, m)
+			}
+		}
+
+		rules := defaultRules
+
+		if path := viper.GetString("migrate.rules"); path != "" {
+			if rules, err = ReadRules(path); err != nil {
+				cmd.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		m := &migrator{
+			cat:         cat,
+			migrations:  migrations,
+			rules:       rules,
+			interactive: !viper.GetBool("migrate.non-interactive"),
+		}
+
+		if path := viper.GetString("migrate.decisions"); path != "" {
+			if m.decisions, err = ReadDecisions(path); err != nil {
+				cmd.Println(err)
+				os.Exit(1)
+			}
+		}
+
 		dirs := args
 
 		for _, dir := range dirs {
@@ -121,13 +118,202 @@ Migrate issue codes in the ranking rules.
 					return nil
 				}
 
-				return migrateCodes(path)
+				return m.migrateFile(path)
 			})
 		}
+
+		m.printSummary(cmd.OutOrStdout())
 	},
 }
 
-func migrateCodes(path string) error {
+// printSummary prints the rows with codes that are not in the catalog.
+func (m *migrator) printSummary(w io.Writer) {
+	if len(m.unmapped) == 0 {
+		fmt.Fprintln(w, "# All codes are mapped.")
+		return
+	}
+
+	fmt.Fprintf(w, "# %d row(s) left unmapped:\n", len(m.unmapped))
+
+	for _, d := range m.unmapped {
+		fmt.Fprintf(w, "#    %s: %s/%s %s\n", d.File, d.Table, d.Field, d.NewCode)
+	}
+}
+
+// migrator migrates the codes in files by applying the migrations in order.
+type migrator struct {
+	cat        *catalog.Catalog
+	migrations []*Migration
+
+	// Recorded decisions and rules used before prompting.
+	decisions *Decisions
+	rules     []*Rule
+
+	// If false, rows that cannot be mapped without prompting are left unmapped.
+	interactive bool
+
+	// Rows with codes that are not in the catalog after the migration.
+	unmapped []*Decision
+}
+
+// rowInfo is the row being migrated.
+type rowInfo struct {
+	file    string
+	table   string
+	field   string
+	finding string
+	head    []string
+	row     []string
+}
+
+// migrate applies the migrations to the code of a row. The code is changed
+// by each migration that has a mapping for it, so a code may be migrated
+// across several revisions. If a mapping has multiple candidates, one is
+// chosen. A code that is not mapped and not in the catalog is chosen from
+// all codes in the catalog.
+func (m *migrator) migrate(info *rowInfo, code string) (string, bool) {
+	var matched bool
+
+	for _, mig := range m.migrations {
+		x := mig.Match(code, info.table, info.field)
+		if x == nil {
+			continue
+		}
+
+		matched = true
+
+		// 1:1 mapping
+		if len(x.New) == 1 {
+			code = x.New[0]
+			continue
+		}
+
+		newCode, save := m.choose(info, code, x.New)
+		if save {
+			return code, true
+		}
+
+		// Skipped.
+		if newCode == "" {
+			return code, false
+		}
+
+		code = newCode
+	}
+
+	if !matched && m.cat.Check(code) == nil {
+		newCode, save := m.choose(info, code, m.cat.Codes())
+		if save {
+			return code, true
+		}
+
+		if newCode != "" {
+			code = newCode
+		}
+	}
+
+	return code, false
+}
+
+// choose chooses a code among the candidates using the recorded decision,
+// a rule matching the finding or by prompting the user in that order. The
+// choices made by the user are recorded. An empty string is returned if the
+// code is skipped or cannot be chosen.
+func (m *migrator) choose(info *rowInfo, code string, candidates []string) (string, bool) {
+	if m.decisions != nil {
+		if d, ok := m.decisions.Get(info.file, info.table, info.field, code); ok {
+			return d.NewCode, false
+		}
+	}
+
+	if c := matchRule(m.rules, candidates, info.finding); c != "" {
+		// Show the choice that would otherwise have been prompted.
+		if m.interactive {
+			fmt.Printf("# Code '%s' mapped to '%s' by the finding '%s'.\n", code, c, info.finding)
+		}
+
+		return c, false
+	}
+
+	if !m.interactive {
+		return "", false
+	}
+
+	newCode, save := m.prompt(info.head, info.row, code, candidates)
+	if save {
+		return "", true
+	}
+
+	if m.decisions != nil {
+		err := m.decisions.Record(&Decision{
+			File:    info.file,
+			Table:   info.table,
+			Field:   info.field,
+			OldCode: code,
+			NewCode: newCode,
+		})
+
+		if err != nil {
+			fmt.Printf("! Error recording decision: %s\n", err)
+		}
+	}
+
+	return newCode, false
+}
+
+// prompt prompts the user to choose a code. An empty string is returned if
+// the code is skipped. If the user chooses to save the file, true is returned.
+func (m *migrator) prompt(head, row []string, code string, choices []string) (string, bool) {
+	fmt.Printf("# Code '%s' needs to be mapped.\n", code)
+	fmt.Println("#")
+	fmt.Println("# Row contents:")
+	for i, c := range head {
+		fmt.Printf("#    %s: %s\n", c, row[i])
+	}
+	fmt.Println("#")
+
+	fmt.Println("# The choices are:")
+	for i, c := range choices {
+		fmt.Printf("#    %d) %s    %s\n", i, c, m.cat.Description(c))
+	}
+	fmt.Println("#")
+
+	fmt.Println("# Enter the number for the code and hit enter/return.")
+	fmt.Println("# Alternately manually enter a code to override the choices.")
+	fmt.Println("# You can skip a code by typing 's' or 'skip'.")
+	fmt.Println("# Finally you can type 'save' to save the current file.")
+
+	defer fmt.Println("")
+
+	// Loop until a valid input is received.
+	for {
+		newCode := strings.ToUpper(strings.TrimSpace(readInput("> ")))
+
+		if newCode == "SAVE" {
+			return "", true
+		}
+
+		// Skip it.
+		if newCode == "S" || newCode == "SKIP" {
+			return "", false
+		}
+
+		// Check for text code.
+		if m.cat.Check(newCode) != nil {
+			return newCode, false
+		}
+
+		// Use number to select choice.
+		i, err := strconv.Atoi(newCode)
+		if err == nil && i >= 0 && i < len(choices) {
+			return choices[i], false
+		}
+
+		fmt.Println("Invalid choice. Try again.")
+	}
+}
+
+func (m *migrator) migrateFile(path string) error {
 	f, err := os.Open(path)
 	if err != nil {
 		log.Println("error opening file")
@@ -150,6 +336,9 @@ func migrateCodes(path string) error {
 	head := rows[0]
 	codepos := -1
 	descpos := -1
+	tablepos := -1
+	fieldpos := -1
+	findingpos := -1
 
 	for i, col := range head {
 		switch strings.Replace(strings.ToLower(col), " ", "_", -1) {
@@ -157,6 +346,12 @@ func migrateCodes(path string) error {
 			codepos = i
 		case "check_type", "issue_description":
 			descpos = i
+		case "table":
+			tablepos = i
+		case "field":
+			fieldpos = i
+		case "finding":
+			findingpos = i
 		}
 	}
 
@@ -165,121 +360,72 @@ func migrateCodes(path string) error {
 		return nil
 	}
 
-	var (
-		oldCode string
-		oldDesc string
-	)
-
 	// Replace codes.
 	for _, row := range rows[1:] {
-		oldCode = row[codepos]
-
-		if descpos >= 0 {
-			oldDesc = row[descpos]
-		} else {
-			oldDesc = ""
-		}
+		oldCode := row[codepos]
 
 		// Not an issue.
 		if oldCode == "" {
 			continue
 		}
 
-		// Already mapped, ignore.
-		if indexOf(oldCode, allNewCodes) >= 0 {
-			continue
+		info := &rowInfo{
+			file: path,
+			head: head,
+			row:  row,
 		}
 
-		// Suggested new codes.
-		newCodes := codeMapping[oldCode]
-		if newCodes == nil {
-			newCodes = allNewCodes
+		if tablepos >= 0 {
+			info.table = row[tablepos]
 		}
 
-		// 1:1 mapping
-		if len(newCodes) == 1 {
-			row[codepos] = newCodes[0]
-			if descpos >= 0 {
-				row[descpos] = newCodesCatalog[newCodes[0]]
-			}
-			continue
+		if fieldpos >= 0 {
+			info.field = row[fieldpos]
 		}
 
-		var (
-			newCode string
-			newDesc string
-		)
-
-		fmt.Printf("# Code '%s' needs to be mapped.\n", oldCode)
-		fmt.Println("#")
-		fmt.Println("# Row contents:")
-		for i, c := range head {
-			fmt.Printf("#    %s: %s\n", c, row[i])
+		if findingpos >= 0 {
+			info.finding = row[findingpos]
 		}
-		fmt.Println("#")
 
-		// Unknown mapping, prompt.
-		fmt.Println("# The choices are:")
-		for i, code := range newCodes {
-			fmt.Printf("#    %d) %s    %s\n", i, code, newCodesCatalog[code])
-		}
-		fmt.Println("#")
-
-		fmt.Println("# Enter the number for the code and hit enter/return.")
-		fmt.Println("# Alternately manually enter a code to override the choices.")
-		fmt.Println("# You can skip a code by typing 's' or 'skip'.")
-		fmt.Println("# Finally you can type 'save' to save the current file.")
-
-		// Loop until a valid input is received.
-		for {
-			newCode = strings.ToUpper(strings.TrimSpace(readInput("> ")))
-
-			if newCode == "SAVE" {
-				if err := saveFile(path, rows); err != nil {
-					fmt.Println("! There was a problem saving to the file. If the issue is fixable, you can try saving again.")
-					fmt.Printf("! error: %s\n", err)
-					continue
-				}
+		newCode, save := m.migrate(info, oldCode)
 
-				fmt.Println("Saved current file!\n")
+		if save {
+			if err := saveFile(path, rows); err != nil {
+				fmt.Println("! There was a problem saving to the file.")
+				fmt.Printf("! error: %s\n", err)
 				return nil
 			}
 
-			// Skip it.
-			if newCode == "S" || newCode == "SKIP" {
-				newCode = oldCode
-				newDesc = oldDesc
-				break
-			}
-
-			// Check for text code.
-			if indexOf(newCode, allNewCodes) >= 0 {
-				newDesc = newCodesCatalog[newCode]
-				break
-			}
+			fmt.Println("Saved current file!")
+			fmt.Println("")
+			return nil
+		}
 
-			// Use number to select choice.
-			i, err := strconv.Atoi(newCode)
-			if err == nil && i < len(newCodes) {
-				newCode = newCodes[i]
-				newDesc = newCodesCatalog[newCode]
-				break
-			}
+		if m.cat.Check(newCode) == nil {
+			m.unmapped = append(m.unmapped, &Decision{
+				File:    path,
+				Table:   info.table,
+				Field:   info.field,
+				OldCode: oldCode,
+				NewCode: newCode,
+			})
+		}
 
-			fmt.Println("Invalid choice. Try again.")
+		if newCode == oldCode {
+			continue
 		}
 
 		row[codepos] = newCode
-		if descpos >= 0 {
-			row[descpos] = newDesc
-		}
 
-		fmt.Println("")
+		// Keep the existing description if the catalog does not describe the code.
+		if desc := m.cat.Description(newCode); descpos >= 0 && desc != "" {
+			row[descpos] = desc
+		}
 	}
 
 	if err := saveFile(path, rows); err != nil {
 		fmt.Println("! Error saving file.")
-		fmt.Println("! error: %s", err)
+		fmt.Printf("! error: %s\n", err)
 	}
 
 	return nil
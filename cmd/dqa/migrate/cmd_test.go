@@ -0,0 +1,174 @@
+package migrate
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+)
+
+func TestMigrationMatch(t *testing.T) {
+	m, err := ReadMigration("testdata/v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if x := m.Match("CA-010", "person", "person_id"); x == nil || x.New[0] != "CA-016" {
+		t.Errorf("expected table specific mapping, got %v", x)
+	}
+
+	if x := m.Match("CA-010", "visit_occurrence", "visit_occurrence_id"); x == nil || x.New[0] != "CA-017" {
+		t.Errorf("expected general mapping, got %v", x)
+	}
+
+	if x := m.Match("CA-005", "person", "person_id"); x != nil {
+		t.Errorf("expected no mapping, got %v", x)
+	}
+}
+
+func TestMatchRule(t *testing.T) {
+	candidates := []string{"CA-005", "CA-006"}
+
+	tests := map[string]string{
+		"12% increase in records":       "CA-005",
+		"Increase in missing records":   "CA-006",
+		"Null values in a few rows":     "CA-006",
+		"Unexpected distribution shift": "",
+	}
+
+	for finding, exp := range tests {
+		if c := matchRule(defaultRules, candidates, finding); c != exp {
+			t.Errorf("%s: expected '%s', got '%s'", finding, exp, c)
+		}
+	}
+}
+
+// copyFile copies a file into the directory as person.csv.
+func copyFile(t *testing.T, dir, src string) string {
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	path := filepath.Join(dir, "person.csv")
+
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	io.Copy(out, in)
+
+	return path
+}
+
+func checkCodes(t *testing.T, dir string, exp map[string][2]string) {
+	files, err := results.ReadFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range files["person.csv"].Results {
+		e := exp[r.Field]
+
+		if r.CheckCode != e[0] || r.CheckType != e[1] {
+			t.Errorf("%s: expected %s (%s), got %s (%s)", r.Field, e[0], e[1], r.CheckCode, r.CheckType)
+		}
+	}
+}
+
+func TestMigrateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqa-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := copyFile(t, dir, "testdata/person.csv")
+
+	v2, err := ReadMigration("testdata/v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cat := catalog.Builtin()
+	cat.Checks["CA-016"] = &catalog.Check{Code: "CA-016", Description: "Too few persons"}
+	cat.Checks["CA-017"] = &catalog.Check{Code: "CA-017", Description: "Too few records"}
+
+	m := &migrator{
+		cat:        cat,
+		migrations: []*Migration{legacyMigration, v2},
+	}
+
+	if err := m.migrateFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	checkCodes(t, dir, map[string][2]string{
+		// Chained through both migrations.
+		"person_id":           {"CA-016", "Too few persons"},
+		"gender_source_value": {"BA-001", "Missing Data"},
+		"year_of_birth":       {"", ""},
+	})
+}
+
+func TestMigrateDecisions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqa-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := copyFile(t, dir, "testdata/ambiguous.csv")
+
+	// Outside of the report directory.
+	decisionsPath := dir + "-decisions.csv"
+	defer os.Remove(decisionsPath)
+
+	decisions, err := ReadDecisions(decisionsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decisions.Record(&Decision{
+		File:    path,
+		Table:   "person",
+		Field:   "year_of_birth",
+		OldCode: "G2-003",
+		NewCode: "CA-004",
+	})
+
+	// Replayed from the file.
+	decisions, err = ReadDecisions(decisionsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &migrator{
+		cat:        catalog.Builtin(),
+		migrations: []*Migration{legacyMigration},
+		decisions:  decisions,
+		rules:      defaultRules,
+	}
+
+	if err := m.migrateFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	checkCodes(t, dir, map[string][2]string{
+		// Matched by the finding.
+		"person_id":     {"CA-005", "Unexpected change in number of records between data cycles"},
+		"year_of_birth": {"CA-004", "Post-death fact"},
+		"birth_date":    {"G2-008", "Dates"},
+	})
+
+	if len(m.unmapped) != 1 || m.unmapped[0].Field != "birth_date" {
+		t.Errorf("expected birth_date to be unmapped, got %v", m.unmapped)
+	}
+}
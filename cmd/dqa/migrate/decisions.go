@@ -0,0 +1,214 @@
+package migrate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/PEDSnet/tools/cmd/dqa/uni"
+)
+
+var decisionsHeader = []string{
+	"file",
+	"table",
+	"field",
+	"old_code",
+	"new_code",
+}
+
+// Decision is the code chosen for an old code in a row of a file. An empty
+// new code means the row was skipped.
+type Decision struct {
+	File    string
+	Table   string
+	Field   string
+	OldCode string
+	NewCode string
+}
+
+func (d *Decision) key() [4]string {
+	return [4]string{filepath.ToSlash(d.File), d.Table, d.Field, d.OldCode}
+}
+
+// Decisions are the recorded decisions. Decisions are written to the file as
+// they are made so they are not lost if the migration is interrupted.
+type Decisions struct {
+	path  string
+	items map[[4]string]*Decision
+	order [][4]string
+}
+
+// ReadDecisions reads the decisions file. If the file does not exist, it is
+// created when the first decision is recorded.
+func ReadDecisions(path string) (*Decisions, error) {
+	d := &Decisions{
+		path:  path,
+		items: make(map[[4]string]*Decision),
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(uni.New(f))
+
+	if _, err := cr.Read(); err == io.EOF {
+		return d, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Error reading decisions file: %s", err)
+		}
+
+		if len(row) != len(decisionsHeader) {
+			return nil, fmt.Errorf("Error reading decisions file: expected %d columns, got %d", len(decisionsHeader), len(row))
+		}
+
+		d.add(&Decision{
+			File:    row[0],
+			Table:   row[1],
+			Field:   row[2],
+			OldCode: row[3],
+			NewCode: row[4],
+		})
+	}
+
+	return d, nil
+}
+
+func (d *Decisions) add(x *Decision) {
+	k := x.key()
+
+	if _, ok := d.items[k]; !ok {
+		d.order = append(d.order, k)
+	}
+
+	d.items[k] = x
+}
+
+// Get returns the decision for the old code in a row of a file.
+func (d *Decisions) Get(file, table, field, code string) (*Decision, bool) {
+	x, ok := d.items[(&Decision{File: file, Table: table, Field: field, OldCode: code}).key()]
+	return x, ok
+}
+
+// Record records a decision and writes the decisions file.
+func (d *Decisions) Record(x *Decision) error {
+	d.add(x)
+
+	if d.path == "" {
+		return nil
+	}
+
+	f, err := os.Create(d.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write(decisionsHeader)
+
+	for _, k := range d.order {
+		x := d.items[k]
+		w.Write([]string{filepath.ToSlash(x.File), x.Table, x.Field, x.OldCode, x.NewCode})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// Rule chooses a code among the candidates if the finding of the row
+// matches the pattern.
+type Rule struct {
+	Code    string `json:"code"`
+	Finding string `json:"finding"`
+
+	re *regexp.Regexp
+}
+
+// defaultRules distinguish the candidates of the ambiguous G code mappings
+// based on the finding. Rules are tried in order, so missing records are
+// matched before a change in the number of records.
+var defaultRules = []*Rule{
+	{Code: "CA-006", Finding: `(?i)missing|null`},
+	{Code: "CA-005", Finding: `(?i)record|row`},
+	{Code: "CA-003", Finding: `(?i)birth`},
+	{Code: "CA-004", Finding: `(?i)death`},
+	{Code: "CA-014", Finding: `(?i)null|missing`},
+	{Code: "CA-015", Finding: `(?i)frequent`},
+	{Code: "CA-008", Finding: `(?i)date`},
+	{Code: "CA-009", Finding: `(?i)distribution|sudden`},
+}
+
+func compileRules(rules []*Rule) error {
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Finding)
+		if err != nil {
+			return fmt.Errorf("Invalid finding pattern for %s: %s", r.Code, err)
+		}
+
+		r.re = re
+	}
+
+	return nil
+}
+
+func init() {
+	if err := compileRules(defaultRules); err != nil {
+		panic(err)
+	}
+}
+
+// ReadRules reads a JSON array of rules.
+func ReadRules(path string) ([]*Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []*Rule
+
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return nil, fmt.Errorf("Error decoding rules file '%s': %s", path, err)
+	}
+
+	if err := compileRules(rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// matchRule returns the code of the first rule for one of the candidates
+// that matches the finding.
+func matchRule(rules []*Rule, candidates []string, finding string) string {
+	if finding == "" {
+		return ""
+	}
+
+	for _, r := range rules {
+		for _, c := range candidates {
+			if r.Code == c && r.re.MatchString(finding) {
+				return c
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,112 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Mapping maps an old code to the candidate new codes. If the table or
+// field are set, the mapping only applies to rows with the same values.
+type Mapping struct {
+	Old   string   `json:"old"`
+	New   []string `json:"new"`
+	Table string   `json:"table,omitempty"`
+	Field string   `json:"field,omitempty"`
+}
+
+func (m *Mapping) matches(code, table, field string) bool {
+	if m.Old != code {
+		return false
+	}
+
+	if m.Table != "" && m.Table != table {
+		return false
+	}
+
+	if m.Field != "" && m.Field != field {
+		return false
+	}
+
+	return true
+}
+
+// Migration is a versioned set of mappings from one revision of the check
+// codes to the next.
+type Migration struct {
+	Version     string     `json:"version"`
+	Description string     `json:"description,omitempty"`
+	Mappings    []*Mapping `json:"mappings"`
+}
+
+// Match returns the first mapping that applies to the code in a row with
+// the table and field. Mappings with predicates should therefore be listed
+// before the general mapping of the same code.
+func (m *Migration) Match(code, table, field string) *Mapping {
+	for _, x := range m.Mappings {
+		if x.matches(code, table, field) {
+			return x
+		}
+	}
+
+	return nil
+}
+
+// ReadMigration reads a migration from a JSON mapping file.
+func ReadMigration(path string) (*Migration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var m Migration
+
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return nil, fmt.Errorf("Error decoding mapping file '%s': %s", path, err)
+	}
+
+	if m.Version == "" {
+		return nil, fmt.Errorf("Mapping file '%s' has no version", path)
+	}
+
+	for i, x := range m.Mappings {
+		if x.Old == "" || len(x.New) == 0 {
+			return nil, fmt.Errorf("Mapping %d in '%s' requires an old code and at least one new code", i, path)
+		}
+
+		// Sort for better presentation.
+		sort.Strings(x.New)
+	}
+
+	return &m, nil
+}
+
+// legacyMigration maps the G codes to the codes of the first DQA catalog.
+// It is used if no mapping files are supplied.
+var legacyMigration = &Migration{
+	Version:     "1",
+	Description: "G codes to the DQA catalog codes",
+	Mappings: []*Mapping{
+		{Old: "G1-002", New: []string{"CA-014", "CA-015"}},
+		{Old: "G1-003", New: []string{"AA-003"}},
+		{Old: "G2-002", New: []string{"CA-010"}},
+		{Old: "G2-003", New: []string{"CA-003", "CA-004"}},
+		{Old: "G2-004", New: []string{"AA-001"}},
+		{Old: "G2-005", New: []string{"CA-007"}},
+		{Old: "G2-006", New: []string{"AA-002"}},
+		{Old: "G2-007", New: []string{"CA-011"}},
+		{Old: "G2-008", New: []string{"CA-008", "CA-009"}},
+		{Old: "G2-009", New: []string{"CA-001"}},
+		{Old: "G2-010", New: []string{"CA-002"}},
+		{Old: "G2-011", New: []string{"AA-004"}},
+		{Old: "G2-012", New: []string{"CA-012"}},
+		{Old: "G2-013", New: []string{"CA-005", "CA-006"}},
+		{Old: "G2-014", New: []string{"CA-013"}},
+		{Old: "G3-002", New: []string{"CA-012"}},
+		{Old: "G3-003", New: []string{"CB-001"}},
+		{Old: "G3-005", New: []string{"CA-013"}},
+		{Old: "G4-002", New: []string{"BA-001"}},
+	},
+}
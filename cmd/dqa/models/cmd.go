@@ -0,0 +1,141 @@
+package models
+
+import (
+	"os"
+
+	"github.com/PEDSnet/tools/cmd/internal/datamodels"
+	dms "github.com/chop-dbhi/data-models-service/client"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var Cmd = &cobra.Command{
+	Use: "models",
+
+	Short: "Manages the cache of data model revisions.",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var PullCmd = &cobra.Command{
+	Use: "pull <model>/<version>...",
+
+	Short: "Fetches model revisions and writes them to the cache.",
+
+	Long: `Fetches model revisions from the data models service and writes them to
+the cache. Commands that use the data models service fall back to the cache
+when the service cannot be reached.`,
+
+	Example: `Cache the PEDSnet v2.3.0 model before going offline:
+  pedsnet-dqa models pull pedsnet/2.3.0`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		p := &datamodels.Provider{
+			Service:  viper.GetString("models.pull.url"),
+			CacheDir: viper.GetString("models.pull.model-cache"),
+		}
+
+		for _, arg := range args {
+			rev, err := datamodels.ParseRevision(arg)
+			if err != nil {
+				cmd.Println(err)
+				os.Exit(1)
+			}
+
+			if _, err := p.Pull(rev.Name, rev.Version); err != nil {
+				cmd.Printf("Error pulling model '%s': %s\n", rev, err)
+				os.Exit(1)
+			}
+
+			cmd.Printf("Pulled %s\n", rev)
+		}
+	},
+}
+
+var ListCmd = &cobra.Command{
+	Use: "list",
+
+	Short: "Lists the cached model revisions.",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		p := &datamodels.Provider{
+			CacheDir: viper.GetString("models.list.model-cache"),
+		}
+
+		revs, err := p.Cached()
+		if err != nil {
+			cmd.Printf("Error reading cache: %s\n", err)
+			os.Exit(1)
+		}
+
+		tw := tablewriter.NewWriter(cmd.OutOrStdout())
+		tw.SetHeader([]string{"Model", "Version"})
+
+		for _, r := range revs {
+			tw.Append([]string{r.Name, r.Version})
+		}
+
+		tw.Render()
+	},
+}
+
+// BindFlags adds the flags to locate model revisions offline to a command
+// and binds them under the key prefix. The command is expected to define
+// the url flag of the service itself.
+func BindFlags(flags *pflag.FlagSet, prefix string) {
+	flags.String("model-file", "", "Local JSON file of the model revision. Takes precedence over the service.")
+	flags.String("model-cache", datamodels.DefaultCacheDir(), "Directory of cached model revisions.")
+	flags.Bool("offline", false, "Only read model revisions from the cache.")
+
+	viper.BindPFlag(prefix+".model-file", flags.Lookup("model-file"))
+	viper.BindPFlag(prefix+".model-cache", flags.Lookup("model-cache"))
+	viper.BindPFlag(prefix+".offline", flags.Lookup("offline"))
+}
+
+// ConfigProvider returns the provider configured by the flags bound by
+// BindFlags and the url flag of the command.
+func ConfigProvider(prefix string) *datamodels.Provider {
+	return &datamodels.Provider{
+		File:     viper.GetString(prefix + ".model-file"),
+		Service:  viper.GetString(prefix + ".url"),
+		CacheDir: viper.GetString(prefix + ".model-cache"),
+		Offline:  viper.GetBool(prefix + ".offline"),
+	}
+}
+
+// Revision gets a model revision from the provider.
+func Revision(p *datamodels.Provider, name, version string) (*dms.Model, error) {
+	var m dms.Model
+
+	if err := p.Decode(name, version, &m); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func init() {
+	pflags := PullCmd.Flags()
+	pflags.String("url", dms.DefaultServiceURL, "Data models service URL.")
+	pflags.String("model-cache", datamodels.DefaultCacheDir(), "Directory of cached model revisions.")
+
+	viper.BindPFlag("models.pull.url", pflags.Lookup("url"))
+	viper.BindPFlag("models.pull.model-cache", pflags.Lookup("model-cache"))
+
+	lflags := ListCmd.Flags()
+	lflags.String("model-cache", datamodels.DefaultCacheDir(), "Directory of cached model revisions.")
+
+	viper.BindPFlag("models.list.model-cache", lflags.Lookup("model-cache"))
+
+	Cmd.AddCommand(PullCmd)
+	Cmd.AddCommand(ListCmd)
+}
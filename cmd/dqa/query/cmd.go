@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
 	"github.com/PEDSnet/tools/cmd/dqa/results"
 	"github.com/spf13/cobra"
 )
@@ -33,6 +34,10 @@ Use - to read from stdin:
 Read from a file:
 
   $ pedsnet-dqa query - ./ETLv1 ./ETLv2 ./ETLv3 ./ETLv4 < query.sql
+
+Join with the DQA catalog:
+
+  $ pedsnet-dqa query "select r.field, r.finding, t.threshold_low, t.threshold_high from results r join thresholds t on r.check_code = t.check_code and r.\"table\" = t.\"table\" and r.field = t.field" ./ETLv4
 `,
 
 	Run: func(cmd *cobra.Command, args []string) {
@@ -62,6 +67,18 @@ Read from a file:
 			cmd.Printf("Error initializing database: %s\n", err)
 			os.Exit(1)
 		}
+		defer db.Close()
+
+		cat, err := catalog.Load(catalog.ConfigOptions("query"))
+		if err != nil {
+			cmd.Printf("Error loading catalog: %s\n", err)
+			os.Exit(1)
+		}
+
+		if err = db.LoadCatalog(cat); err != nil {
+			cmd.Printf("Error loading catalog into the database: %s\n", err)
+			os.Exit(1)
+		}
 
 		for _, dir := range args[1:] {
 			reports, err := results.ReadFromDir(dir)
@@ -97,5 +114,12 @@ func printHeader(w io.Writer) {
 
 	fmt.Fprintf(w, "The table is called `%s`\n", TableName)
 	fmt.Fprintf(w, "The available columns are: %s\n", strings.Join(cols, ", "))
-	fmt.Fprintln(w, "---\n")
+	fmt.Fprintf(w, "The DQA catalog is in the tables `%s` (check_code, check_alias, check_type) and `%s` (check_code, table, field, threshold_low, threshold_high)\n", ChecksTableName, ThresholdsTableName)
+	fmt.Fprintln(w, "---")
+	fmt.Fprintln(w, "")
+}
+
+func init() {
+	flags := Cmd.Flags()
+	catalog.BindFlags(flags, "query")
 }
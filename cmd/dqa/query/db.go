@@ -7,13 +7,20 @@ import (
 	"io"
 	"strings"
 
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
 	"github.com/PEDSnet/tools/cmd/dqa/results"
 	"github.com/olekukonko/tablewriter"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-const TableName = "results"
+const (
+	TableName = "results"
+
+	// Tables of the DQA catalog.
+	ChecksTableName     = "checks"
+	ThresholdsTableName = "thresholds"
+)
 
 var columnNames = []string{
 	"model",
@@ -59,6 +66,17 @@ func Open() (*DB, error) {
 		return nil, err
 	}
 
+	stmts := []string{
+		fmt.Sprintf(`CREATE TABLE %s ("check_code" TEXT, "check_alias" TEXT, "check_type" TEXT)`, ChecksTableName),
+		fmt.Sprintf(`CREATE TABLE %s ("check_code" TEXT, "table" TEXT, "field" TEXT, "threshold_low" INTEGER, "threshold_high" INTEGER)`, ThresholdsTableName),
+	}
+
+	for _, stmt := range stmts {
+		if _, err = db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+
 	return &DB{db}, nil
 }
 
@@ -110,6 +128,44 @@ func (db *DB) Load(header []string, results []*results.Result) error {
 	return nil
 }
 
+// LoadCatalog loads the checks and thresholds of the catalog.
+func (db *DB) LoadCatalog(c *catalog.Catalog) error {
+	checkStmt := fmt.Sprintf("INSERT INTO %s VALUES (?, ?, ?)", ChecksTableName)
+	thresStmt := fmt.Sprintf("INSERT INTO %s VALUES (?, ?, ?, ?, ?)", ThresholdsTableName)
+
+	for _, code := range c.Codes() {
+		ch := c.Check(code)
+
+		if _, err := db.db.Exec(checkStmt, ch.Code, ch.Alias, ch.Description); err != nil {
+			return err
+		}
+
+		for _, t := range ch.Thresholds {
+			if _, err := db.db.Exec(thresStmt, ch.Code, t.Table, t.Field, t.Lower, t.Upper); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close closes the database and frees its memory.
+func (db *DB) Close() error {
+	return db.db.Close()
+}
+
+// ReadOnly prevents statements from changing the database. It must be
+// called after the results and catalog are loaded.
+func (db *DB) ReadOnly() error {
+	// Each connection to an in-memory database is a separate database,
+	// so a single connection is used.
+	db.db.SetMaxOpenConns(1)
+
+	_, err := db.db.Exec("PRAGMA query_only = ON")
+	return err
+}
+
 func (db *DB) Query(w Writer, stmt string, args ...interface{}) error {
 	rows, err := db.db.Query(stmt, args...)
 	if err != nil {
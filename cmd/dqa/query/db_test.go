@@ -2,10 +2,10 @@ package query
 
 import (
 	"bytes"
-	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
 	"github.com/PEDSnet/tools/cmd/dqa/results"
 )
 
@@ -14,42 +14,102 @@ func TestDB(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer db.Close()
 
-	results := []*results.Result{
-		{
-			Model:        "pedsnet",
-			ModelVersion: "2.2.0",
-			DataVersion:  "pedsnet-2.2.0-site-v1",
-			DQAVersion:   "0",
-			Table:        "person",
-			Field:        "person_id",
-			Goal:         "Feasibility",
-			CheckCode:    "",
-			CheckType:    "",
-			Finding:      "",
-			Prevalence:   "",
-			Rank:         0,
-			SiteResponse: "",
-			Cause:        "",
-			Status:       "",
-			Reviewer:     "",
-		},
+	r := results.NewResult()
+	r.Model = "pedsnet"
+	r.ModelVersion = "2.2.0"
+	r.DataVersion = "pedsnet-2.2.0-site-v1"
+	r.DQAVersion = "0"
+	r.Table = "person"
+	r.Field = "person_id"
+
+	f := results.NewFile("person.csv")
+
+	if err := db.Load(f.Header(), []*results.Result{r}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	w := NewCSVWriter(buf)
+	if err := db.Query(w, `select model, model_version, data_version, dqa_version, "table", field from results`); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "model,model_version,data_version,dqa_version,table,field\npedsnet,2.2.0,pedsnet-2.2.0-site-v1,0,person,person_id"
+	act := buf.String()
+
+	if strings.TrimSpace(act) != exp {
+		t.Errorf("Expected output %s, got %s", exp, act)
 	}
+}
+
+func TestDBCatalog(t *testing.T) {
+	db, err := Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
 
-	if err := db.Load(results); err != nil {
-		t.Error(err)
+	cat := catalog.Builtin()
+	cat.Check("CA-005").Thresholds = []*catalog.Threshold{
+		{Table: "person", Field: "person_id", Lower: -10, Upper: 10},
+	}
+
+	if err := db.LoadCatalog(cat); err != nil {
+		t.Fatal(err)
 	}
 
 	buf := bytes.NewBuffer(nil)
 	w := NewCSVWriter(buf)
-	if err := db.Query(w, "select * from results"); err != nil {
-		t.Error(err)
+
+	stmt := `select c.check_type, t.threshold_low from checks c join thresholds t on c.check_code = t.check_code`
+
+	if err := db.Query(w, stmt); err != nil {
+		t.Fatal(err)
 	}
 
-	exp := fmt.Sprintf("%s\n%s", strings.Join(ColumnNames, ","), strings.Join(results[0].Row(), ","))
+	exp := "check_type,threshold_low\nUnexpected change in number of records between data cycles,-10"
 	act := buf.String()
 
-	if strings.TrimSpace(act) != strings.TrimSpace(exp) {
-		t.Errorf("Expected output %s, got ", exp, act)
+	if strings.TrimSpace(act) != exp {
+		t.Errorf("Expected output %s, got %s", exp, act)
+	}
+}
+
+func TestDBReadOnly(t *testing.T) {
+	db, err := Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	r := results.NewResult()
+	r.Table = "person"
+
+	f := results.NewFile("person.csv")
+
+	if err := db.Load(f.Header(), []*results.Result{r}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.ReadOnly(); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewCSVWriter(bytes.NewBuffer(nil))
+
+	if err := db.Query(w, `delete from results`); err == nil {
+		t.Error("expected an error for a write statement")
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	if err := db.Query(NewCSVWriter(buf), `select count(*) from results`); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(buf.String()) != "count(*)\n1" {
+		t.Errorf("expected the results to be kept, got %s", buf.String())
 	}
 }
@@ -4,9 +4,10 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"sort"
 
+	"github.com/PEDSnet/tools/cmd/dqa/gh"
+	"github.com/PEDSnet/tools/cmd/dqa/models"
 	"github.com/PEDSnet/tools/cmd/dqa/results"
 	"github.com/PEDSnet/tools/cmd/dqa/rules"
 	dms "github.com/chop-dbhi/data-models-service/client"
@@ -32,7 +33,6 @@ var Cmd = &cobra.Command{
 
 		dryRun := viper.GetBool("rankissues.dryrun")
 		token := viper.GetString("rankissues.token")
-		url := viper.GetString("rankissues.url")
 
 		if token == "" {
 			cmd.Printf("Token required. Use the --token option.")
@@ -47,135 +47,112 @@ var Cmd = &cobra.Command{
 		}
 
 		// Get the data model name and version to validate against.
-		// TODO: this assumes all files being ranked are using the
-		// same data model.
-		var modelName, modelVersion string
-		for _, f := range files {
-			for _, r := range f.Results {
-				modelName = r.Model
-				modelVersion = r.ModelVersion
-				break
-			}
+		modelName, modelVersion, err := DetectModel(files)
+		if err != nil {
+			cmd.Println(err)
+			os.Exit(1)
 		}
 
 		cmd.Printf("Ranking against model '%s/%s'\n", modelName, modelVersion)
 
 		// Fetch the model for validating the rules.
-		// TODO: this is a different workflow?
-		client, err := dms.New(url)
+		model, err := models.Revision(models.ConfigProvider("rankissues"), modelName, modelVersion)
 		if err != nil {
-			cmd.Printf("Could not connect to service %s: %s\n", url, err)
+			cmd.Printf("Error fetching model: %s\n", err)
 			os.Exit(1)
 		}
 
-		model, err := client.ModelRevision(modelName, modelVersion)
+		gc, err := gh.NewClient(gh.Config{
+			Token:   token,
+			BaseURL: viper.GetString("rankissues.github-url"),
+			Log:     os.Stderr,
+		})
 		if err != nil {
-			cmd.Printf("Error fetching model: %s\n", err)
+			cmd.Println(err)
 			os.Exit(1)
 		}
 
-		rules, err := rules.Fetch(token, model)
+		rules, err := rules.Fetch(gc, model)
 		if err != nil {
 			cmd.Println("There was a problem with the rules.")
 			cmd.Println(err)
 			os.Exit(1)
 		}
 
-		bold := color.New(color.Bold, color.FgGreen).SprintFunc()
-
-		var matches rankMatches
-
-		for name, file := range files {
-			fileChanged := false
-
-			for _, r := range file.Results {
-				changedText := "No"
-				persistentText := "No"
-
-				if r.IsPersistent() {
-					persistentText = "Yes"
-				}
-
-				if rule, ok := rules.Run(r); ok {
-					oldRankText := r.Rank.String()
-					newRankText := rule.Rank.String()
-
-					if r.Rank != rule.Rank {
-						changedText = bold("Yes")
-						r.Rank = rule.Rank
-						fileChanged = true
-					}
-
-					matches = append(matches, []string{
-						rule.Type,
-						r.Table,
-						r.Field,
-						r.Goal,
-						r.CheckCode,
-						r.Prevalence,
-						newRankText,
-						oldRankText,
-						changedText,
-						persistentText,
-					})
-				}
-			}
-
-			if fileChanged && !dryRun {
-				path := filepath.Join(args[0], name)
-
-				// Open the save file for writing.
-				f, err := os.Create(path)
-				if err != nil {
-					cmd.Printf("Error opening file: %s", err)
-					os.Exit(1)
-				}
-				defer f.Close()
-
-				rw := results.NewWriter(f)
-
-				if err := rw.WriteAll(file.Results); err != nil {
-					cmd.Printf("Error writing to file: %s", err)
-					os.Exit(1)
-				}
+		changes, err := Apply(files, rules)
+		if err != nil {
+			cmd.Println(err)
+			os.Exit(1)
+		}
 
-				if err := rw.Flush(); err != nil {
-					cmd.Printf("error flushing file: %s", err)
-					os.Exit(1)
-				}
+		if !dryRun {
+			if err := WriteChanged(args[0], files, changes); err != nil {
+				cmd.Println(err)
+				os.Exit(1)
 			}
 		}
 
-		outputSummary(os.Stdout, matches)
+		outputSummary(cmd.OutOrStdout(), changes)
 	},
 }
 
-func outputSummary(w io.Writer, matches rankMatches) {
+func outputSummary(w io.Writer, changes Changes) {
 	// If there are matches, print them out in a table.
-	if len(matches) > 0 {
-		tw := tablewriter.NewWriter(w)
-
-		tw.SetHeader([]string{
-			"type",
-			"table",
-			"field",
-			"goal",
-			"check code",
-			"prevalence",
-			"new rank",
-			"old rank",
-			"changed",
-			"persistent",
-		})
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "All ranks are up-to-date.")
+		return
+	}
 
-		sort.Sort(matches)
+	bold := color.New(color.Bold, color.FgGreen).SprintFunc()
 
-		tw.AppendBulk([][]string(matches))
+	matches := make(rankMatches, len(changes))
 
-		tw.Render()
-	} else {
-		fmt.Fprintln(w, "All ranks are up-to-date.")
+	for i, c := range changes {
+		changedText := "No"
+		persistentText := "No"
+
+		if c.Changed() {
+			changedText = bold("Yes")
+		}
+
+		if c.Result.IsPersistent() {
+			persistentText = "Yes"
+		}
+
+		matches[i] = []string{
+			c.Rule.Type,
+			c.Result.Table,
+			c.Result.Field,
+			c.Result.Goal,
+			c.Result.CheckCode,
+			c.Result.Prevalence,
+			c.Rule.Rank.String(),
+			c.OldRank.String(),
+			changedText,
+			persistentText,
+		}
 	}
+
+	tw := tablewriter.NewWriter(w)
+
+	tw.SetHeader([]string{
+		"type",
+		"table",
+		"field",
+		"goal",
+		"check code",
+		"prevalence",
+		"new rank",
+		"old rank",
+		"changed",
+		"persistent",
+	})
+
+	sort.Sort(matches)
+
+	tw.AppendBulk([][]string(matches))
+
+	tw.Render()
 }
 
 type rankMatches [][]string
@@ -216,8 +193,12 @@ func init() {
 	flags.Bool("dryrun", false, "Outputs a summary of what rank matches without saving the files.")
 	flags.String("token", "", "GitHub token to fetch the rules.")
 	flags.String("url", dms.DefaultServiceURL, "Data models service URL.")
+	flags.String("github-url", "", "Base URL of the GitHub API.")
 
 	viper.BindPFlag("rankissues.dryrun", flags.Lookup("dryrun"))
 	viper.BindPFlag("rankissues.token", flags.Lookup("token"))
 	viper.BindPFlag("rankissues.url", flags.Lookup("url"))
+	viper.BindPFlag("rankissues.github-url", flags.Lookup("github-url"))
+
+	models.BindFlags(flags, "rankissues")
 }
@@ -0,0 +1,121 @@
+package rank
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/dqa/rules"
+)
+
+// Change is a result matched by a ranking rule.
+type Change struct {
+	File    string
+	Result  *results.Result
+	Rule    *rules.Rule
+	OldRank results.Rank
+}
+
+// Changed returns true if the rank of the result was changed by the rule.
+func (c *Change) Changed() bool {
+	return c.OldRank != c.Rule.Rank
+}
+
+// Changes are the results matched by ranking rules.
+type Changes []*Change
+
+// Files returns the sorted names of the files with changed ranks.
+func (c Changes) Files() []string {
+	seen := make(map[string]struct{})
+
+	var names []string
+
+	for _, x := range c {
+		if _, ok := seen[x.File]; ok || !x.Changed() {
+			continue
+		}
+
+		seen[x.File] = struct{}{}
+		names = append(names, x.File)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// DetectModel returns the model and version of the results. An error is
+// returned if the results are for more than one model revision.
+func DetectModel(files map[string]*results.File) (string, string, error) {
+	var name, version string
+
+	for fn, f := range files {
+		for _, r := range f.Results {
+			if name == "" {
+				name = r.Model
+				version = r.ModelVersion
+				continue
+			}
+
+			if r.Model != name || r.ModelVersion != version {
+				return "", "", fmt.Errorf("Results in '%s' are for model '%s/%s', expected '%s/%s'", fn, r.Model, r.ModelVersion, name, version)
+			}
+		}
+	}
+
+	if name == "" {
+		return "", "", fmt.Errorf("No results to detect the model from")
+	}
+
+	return name, version, nil
+}
+
+// Apply assigns the rank of the first matching rule to each result. The
+// files are changed in place.
+func Apply(files map[string]*results.File, rs rules.Rules) (Changes, error) {
+	if len(rs) == 0 {
+		return nil, fmt.Errorf("No rules to apply")
+	}
+
+	var names []string
+
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var changes Changes
+
+	for _, name := range names {
+		for _, r := range files[name].Results {
+			rule, ok := rs.Run(r)
+			if !ok {
+				continue
+			}
+
+			changes = append(changes, &Change{
+				File:    name,
+				Result:  r,
+				Rule:    rule,
+				OldRank: r.Rank,
+			})
+
+			r.Rank = rule.Rank
+		}
+	}
+
+	return changes, nil
+}
+
+// WriteChanged writes the files with changed ranks to the directory.
+func WriteChanged(dir string, files map[string]*results.File, changes Changes) error {
+	for _, name := range changes.Files() {
+		if err := results.WriteFile(filepath.Join(dir, name), files[name]); err != nil {
+			return fmt.Errorf("Error writing '%s': %s", name, err)
+		}
+	}
+
+	return nil
+}
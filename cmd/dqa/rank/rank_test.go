@@ -0,0 +1,147 @@
+package rank
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/dqa/rules"
+)
+
+func newResult(table, field, prevalence string, rank results.Rank) *results.Result {
+	r := results.NewResult()
+	r.Model = "pedsnet"
+	r.ModelVersion = "2.2.0"
+	r.Table = table
+	r.Field = field
+	r.CheckCode = "BA-001"
+	r.Prevalence = prevalence
+	r.Rank = rank
+	return r
+}
+
+func testFiles() map[string]*results.File {
+	person := results.NewFile("person.csv")
+	person.Results = results.Results{
+		newResult("person", "year_of_birth", "high", results.LowRank),
+		newResult("person", "gender_concept_id", "low", results.LowRank),
+	}
+
+	visit := results.NewFile("visit_occurrence.csv")
+	visit.Results = results.Results{
+		newResult("visit_occurrence", "visit_start_date", "high", results.HighRank),
+	}
+
+	return map[string]*results.File{
+		"person.csv":           person,
+		"visit_occurrence.csv": visit,
+	}
+}
+
+func testRules() rules.Rules {
+	any := &rules.Condition{
+		Name: "any",
+		Test: func(r *results.Result) bool { return true },
+	}
+
+	return rules.Rules{
+		{Type: "Test", Table: "person", Condition: any, CheckCode: "ba-001", Prevalence: "high", Rank: results.HighRank},
+		{Type: "Test", Table: "visit_occurrence", Condition: any, CheckCode: "ba-001", Prevalence: "high", Rank: results.HighRank},
+	}
+}
+
+func TestApply(t *testing.T) {
+	files := testFiles()
+
+	changes, err := Apply(files, testRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 matches, got %d", len(changes))
+	}
+
+	if r := files["person.csv"].Results[0]; r.Rank != results.HighRank {
+		t.Errorf("expected high rank, got %s", r.Rank)
+	}
+
+	if r := files["person.csv"].Results[1]; r.Rank != results.LowRank {
+		t.Errorf("expected unmatched result to keep its rank, got %s", r.Rank)
+	}
+
+	// The visit result already had the rank of the rule.
+	names := changes.Files()
+
+	if len(names) != 1 || names[0] != "person.csv" {
+		t.Errorf("expected only person.csv to change, got %v", names)
+	}
+
+	if _, err := Apply(files, nil); err == nil {
+		t.Error("expected an error without rules")
+	}
+}
+
+func TestDetectModel(t *testing.T) {
+	files := testFiles()
+
+	name, version, err := DetectModel(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "pedsnet" || version != "2.2.0" {
+		t.Errorf("expected pedsnet/2.2.0, got %s/%s", name, version)
+	}
+
+	files["visit_occurrence.csv"].Results[0].ModelVersion = "2.3.0"
+
+	if _, _, err := DetectModel(files); err == nil {
+		t.Error("expected an error for mixed model versions")
+	}
+}
+
+func TestWriteChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := testFiles()
+
+	changes, err := Apply(files, testRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteChanged(dir, files, changes); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := results.ReadFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := written["visit_occurrence.csv"]; ok {
+		t.Error("expected unchanged file to not be written")
+	}
+
+	f, ok := written["person.csv"]
+	if !ok {
+		t.Fatal("expected person.csv to be written")
+	}
+
+	for _, r := range f.Results {
+		if r.Field == "year_of_birth" && r.Rank != results.HighRank {
+			t.Errorf("expected written rank to be high, got %s", r.Rank)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "person.csv")); err != nil {
+		t.Error(err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/PEDSnet/tools/cmd/dqa/uni"
@@ -176,6 +177,44 @@ func NewReader(r io.Reader) (*Reader, error) {
 	}, nil
 }
 
+// WriteFile sorts the results of a file and writes them to the path. The
+// results are written to a temporary file that replaces the file once
+// complete, so the file is never left partially written.
+func WriteFile(path string, f *File) error {
+	sort.Sort(f.Results)
+
+	out, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	// Removes the temporary file if it was not renamed.
+	defer os.Remove(out.Name())
+
+	w := NewWriter(out)
+
+	if err := w.WriteAll(f.Results); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Chmod(0644); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(out.Name(), path)
+}
+
 // Writer writes results to a file.
 type Writer struct {
 	csv  *csv.Writer
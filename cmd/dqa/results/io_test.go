@@ -2,6 +2,9 @@ package results
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -58,3 +61,46 @@ func TestWriter(t *testing.T) {
 		t.Errorf("Input does not match output:\n%s", output)
 	}
 }
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqa-results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := NewResult()
+	r.Table = "person"
+	r.Field = "person_id"
+
+	f := NewFile("person.csv")
+	f.Results = Results{r}
+
+	path := filepath.Join(dir, f.Name)
+
+	// Written twice so the existing file is replaced.
+	for i := 0; i < 2; i++ {
+		if err := WriteFile(path, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The temporary file is renamed.
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fis) != 1 || fis[0].Name() != "person.csv" {
+		t.Fatalf("expected only person.csv, got %d files", len(fis))
+	}
+
+	files, err := ReadFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rs := files["person.csv"].Results; len(rs) != 1 || rs[0].Field != "person_id" {
+		t.Errorf("expected the result to be written, got %v", rs)
+	}
+}
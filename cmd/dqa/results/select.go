@@ -0,0 +1,144 @@
+package results
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// Splits the conditions of a selection expression.
+	andRe = regexp.MustCompile(`(?i)\s+and\s+`)
+
+	// Matches a condition of the form `<column> <op> <value>`.
+	condRe = regexp.MustCompile(`(?i)^([a-z_]+)\s*(!=|=|not\s+in\b|in\b)\s*(.*)$`)
+)
+
+// selectorColumns are the columns a selection expression can refer to.
+var selectorColumns = map[string]func(r *Result) string{
+	"table":       func(r *Result) string { return r.Table },
+	"field":       func(r *Result) string { return r.Field },
+	"check_code":  func(r *Result) string { return r.CheckCode },
+	"check_alias": func(r *Result) string { return r.CheckAlias },
+	"prevalence":  func(r *Result) string { return r.Prevalence },
+	"rank":        func(r *Result) string { return r.Rank.String() },
+	"cause":       func(r *Result) string { return r.Cause },
+	"status":      func(r *Result) string { return r.Status },
+	"method":      func(r *Result) string { return r.Method },
+}
+
+type condition struct {
+	column string
+	negate bool
+	values []string
+}
+
+func (c *condition) match(r *Result) bool {
+	v := strings.ToLower(strings.TrimSpace(selectorColumns[c.column](r)))
+
+	for _, x := range c.values {
+		if v == x {
+			return !c.negate
+		}
+	}
+
+	return c.negate
+}
+
+// Selector selects results by the values of their columns.
+type Selector struct {
+	expr  string
+	conds []*condition
+}
+
+func (s *Selector) String() string {
+	return s.expr
+}
+
+// Match returns true if the result matches all conditions.
+func (s *Selector) Match(r *Result) bool {
+	for _, c := range s.conds {
+		if !c.match(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
+
+// ParseSelector parses a selection expression. The expression is a set of
+// conditions joined by `and` of the form:
+//
+//	<column> = <value>
+//	<column> != <value>
+//	<column> in (<value>, ...)
+//	<column> not in (<value>, ...)
+//
+// Values are compared case-insensitively and may be quoted. An empty
+// expression selects all results.
+func ParseSelector(expr string) (*Selector, error) {
+	s := &Selector{
+		expr: expr,
+	}
+
+	if strings.TrimSpace(expr) == "" {
+		return s, nil
+	}
+
+	for _, part := range andRe.Split(strings.TrimSpace(expr), -1) {
+		m := condRe.FindStringSubmatch(strings.TrimSpace(part))
+
+		if m == nil {
+			return nil, fmt.Errorf("Invalid condition `%s`", part)
+		}
+
+		col := strings.ToLower(m[1])
+
+		if _, ok := selectorColumns[col]; !ok {
+			var cols []string
+
+			for c := range selectorColumns {
+				cols = append(cols, c)
+			}
+
+			sort.Strings(cols)
+
+			return nil, fmt.Errorf("Unknown column `%s`. Choices are: %s", m[1], strings.Join(cols, ", "))
+		}
+
+		op := strings.Join(strings.Fields(strings.ToLower(m[2])), " ")
+		val := strings.TrimSpace(m[3])
+
+		c := &condition{
+			column: col,
+			negate: op == "!=" || op == "not in",
+		}
+
+		if op == "in" || op == "not in" {
+			if !strings.HasPrefix(val, "(") || !strings.HasSuffix(val, ")") {
+				return nil, fmt.Errorf("Invalid condition `%s`: expected a list of values in parentheses", part)
+			}
+
+			for _, x := range strings.Split(val[1:len(val)-1], ",") {
+				c.values = append(c.values, strings.ToLower(unquote(x)))
+			}
+		} else {
+			c.values = []string{strings.ToLower(unquote(val))}
+		}
+
+		s.conds = append(s.conds, c)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,52 @@
+package results
+
+import "testing"
+
+func TestSelector(t *testing.T) {
+	r := &Result{
+		Table:     "person",
+		Field:     "person_id",
+		CheckCode: "CA-005",
+		Rank:      HighRank,
+		Cause:     "ETL: programming error",
+		Status:    "under review",
+	}
+
+	tests := []struct {
+		expr  string
+		match bool
+	}{
+		{"", true},
+		{"status in (persistent, 'under review')", true},
+		{"status = persistent", false},
+		{"Status = 'Under Review' and rank = high", true},
+		{"rank != High", false},
+		{"cause not in (Non-issue, 'i2b2 transform') and check_code = CA-005", true},
+		{"check_code in (CA-006)", false},
+		{"cause = ''", false},
+	}
+
+	for _, test := range tests {
+		s, err := ParseSelector(test.expr)
+		if err != nil {
+			t.Errorf("%s: %s", test.expr, err)
+			continue
+		}
+
+		if s.Match(r) != test.match {
+			t.Errorf("%s: expected match to be %t", test.expr, test.match)
+		}
+	}
+}
+
+func TestSelectorErrors(t *testing.T) {
+	for _, expr := range []string{
+		"status",
+		"owner = me",
+		"status in persistent",
+	} {
+		if _, err := ParseSelector(expr); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+}
@@ -1,16 +1,20 @@
 package rules
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"strings"
 
 	"github.com/PEDSnet/tools/cmd/dqa/results"
 	dms "github.com/chop-dbhi/data-models-service/client"
+	"github.com/google/go-github/github"
 )
 
-const repoURL = "https://api.github.com/repos/PEDSnet/Data-Quality-Results/contents/%s"
+const (
+	repoOwner = "PEDSnet"
+	repoName  = "Data-Quality-Results"
+)
 
 var rulePaths = map[string]string{
 	"Admin":       "SecondaryReports/Ranking/RuleSet1_Admin.csv",
@@ -18,40 +22,24 @@ var rulePaths = map[string]string{
 	"Fact":        "SecondaryReports/Ranking/RuleSet3_Fact.csv",
 }
 
-// fetchRules fetches the raw contents of a rules file through the GitHub API.
-func fetch(kind, path string, token string, model *dms.Model) (Rules, error) {
-	// TODO: replace with github client library.
-	url := fmt.Sprintf(repoURL, path)
-	req, err := http.NewRequest("GET", url, nil)
+// fetch fetches the raw contents of a rules file through the GitHub API.
+func fetch(client *github.Client, kind, path string, model *dms.Model) (Rules, error) {
+	url := fmt.Sprintf("repos/%s/%s/contents/%s", repoOwner, repoName, path)
+	req, err := client.NewRequest("GET", url, nil)
 
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Accept", "application/vnd.github.v3.raw")
-	req.Header.Set("Authorization", "token "+token)
-
-	resp, err := http.DefaultClient.Do(req)
 
-	if err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		var msg string
-
-		if body, err := ioutil.ReadAll(resp.Body); err != nil {
-			msg = "Problem decoding error messages."
-		} else {
-			msg = string(body)
-		}
+	var buf bytes.Buffer
 
-		return nil, fmt.Errorf("Error fetching `%s` rule file\n%s: %s", kind, resp.Status, msg)
+	if _, err := client.Do(context.Background(), req, &buf); err != nil {
+		return nil, fmt.Errorf("Error fetching `%s` rule file\n%s", kind, err)
 	}
 
-	parser, err := NewParser(resp.Body, model, kind)
+	parser, err := NewParser(&buf, model, kind)
 	if err != nil {
 		return nil, err
 	}
@@ -65,11 +53,11 @@ func fetch(kind, path string, token string, model *dms.Model) (Rules, error) {
 }
 
 // Fetch retrieves all rule files that are hosted on GitHub.
-func Fetch(token string, model *dms.Model) (Rules, error) {
+func Fetch(client *github.Client, model *dms.Model) (Rules, error) {
 	var allrules Rules
 
 	for kind, path := range rulePaths {
-		rules, err := fetch(kind, path, token, model)
+		rules, err := fetch(client, kind, path, model)
 		if err != nil {
 			return nil, err
 		}
@@ -2,28 +2,29 @@ package rules
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"strings"
 	"testing"
 
+	"github.com/PEDSnet/tools/cmd/dqa/gh"
+	"github.com/PEDSnet/tools/cmd/dqa/gh/ghtest"
 	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/internal/datamodels"
 	dms "github.com/chop-dbhi/data-models-service/client"
 )
 
 var model *dms.Model
 
 func init() {
-	url := dms.DefaultServiceURL
-
-	client, err := dms.New(url)
-	if err != nil {
-		log.Fatalf("Could not connect to service %s: %s", url, err)
+	// Tables and fields of the model referenced by the rules.
+	p := &datamodels.Provider{
+		File: "testdata/pedsnet-2.2.0.json",
 	}
 
-	model, err = client.ModelRevision("pedsnet", "2.2.0")
-	if err != nil {
-		log.Fatalf("Error fetching model: %s", err)
+	model = new(dms.Model)
+
+	if err := p.Decode("pedsnet", "2.2.0", model); err != nil {
+		panic(err)
 	}
 }
 
@@ -250,7 +251,14 @@ func TestFetch(t *testing.T) {
 		t.Skip()
 	}
 
-	rules, err := Fetch(token, model)
+	client, err := gh.NewClient(gh.Config{
+		Token: token,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := Fetch(client, model)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -259,3 +267,41 @@ func TestFetch(t *testing.T) {
 		t.Errorf("No rules parsed")
 	}
 }
+
+func TestFetchRecorded(t *testing.T) {
+	srv := ghtest.NewServer("testdata/github")
+	defer srv.Close()
+
+	client, err := gh.NewClient(gh.Config{
+		BaseURL: srv.BaseURL(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := Fetch(client, model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A rule is created for each table and prevalence in a line.
+	if len(rules) != 7 {
+		t.Fatalf("expected 7 rules, got %d", len(rules))
+	}
+
+	res := &results.Result{
+		Table:      "person",
+		Field:      "gender_source_value",
+		CheckCode:  "BA-001",
+		Prevalence: "high",
+	}
+
+	rule, ok := rules.Run(res)
+	if !ok {
+		t.Fatal("expected a rule to match")
+	}
+
+	if rule.Type != "Demographic" || rule.Rank != results.MediumRank {
+		t.Errorf("expected Demographic rule with Medium rank, got %s with %s", rule.Type, rule.Rank)
+	}
+}
@@ -0,0 +1,102 @@
+package serve
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+)
+
+// report is the cached files of a report directory.
+type report struct {
+	stamp string
+	files map[string]*results.File
+}
+
+// Cache caches the files of report directories. A directory is read again
+// when one of its files is added, removed or modified.
+type Cache struct {
+	mu      sync.Mutex
+	reports map[string]*report
+}
+
+// NewCache returns an empty cache.
+func NewCache() *Cache {
+	return &Cache{
+		reports: make(map[string]*report),
+	}
+}
+
+// stamp returns a value that changes when a CSV file in the directory
+// is added, removed or modified.
+func stamp(dir string) (string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var parts []string
+
+	for _, fi := range fis {
+		if fi.IsDir() || filepath.Ext(fi.Name()) != ".csv" {
+			continue
+		}
+
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", fi.Name(), fi.Size(), fi.ModTime().UnixNano()))
+	}
+
+	sort.Strings(parts)
+
+	return fmt.Sprint(parts), nil
+}
+
+// Files returns the files in the directory by name. The files are shared
+// between callers and must not be modified.
+func (c *Cache) Files(dir string) (map[string]*results.File, error) {
+	s, err := stamp(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if r, ok := c.reports[dir]; ok && r.stamp == s {
+		return r.files, nil
+	}
+
+	files, err := results.ReadFromDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c.reports[dir] = &report{
+		stamp: s,
+		files: files,
+	}
+
+	return files, nil
+}
+
+// copyFiles returns a copy of the files and their results that can be
+// modified.
+func copyFiles(files map[string]*results.File) map[string]*results.File {
+	cp := make(map[string]*results.File, len(files))
+
+	for name, f := range files {
+		c := *f
+		c.Results = make(results.Results, len(f.Results))
+
+		for i, r := range f.Results {
+			x := *r
+			c.Results[i] = &x
+		}
+
+		cp[name] = &c
+	}
+
+	return cp
+}
@@ -0,0 +1,133 @@
+package serve
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
+	"github.com/PEDSnet/tools/cmd/dqa/gh"
+	"github.com/PEDSnet/tools/cmd/dqa/models"
+	"github.com/PEDSnet/tools/cmd/dqa/rules"
+	dms "github.com/chop-dbhi/data-models-service/client"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var Cmd = &cobra.Command{
+	Use: "serve <path>",
+
+	Short: "Serves a tree of Secondary Reports over a JSON HTTP API.",
+
+	Long: `Serves the Secondary Reports in <path>, organized as <site>/<cycle>/<table>.csv.
+Files are cached and read again when they change.
+
+  GET  /sites                                 Sites and their cycles.
+  GET  /sites/<site>                          Cycles of a site.
+  GET  /sites/<site>/<cycle>                  Tables of a report.
+  GET  /sites/<site>/<cycle>/tables/<table>   Results of a table.
+  POST /sites/<site>/<cycle>/validate         Validates a report.
+  POST /sites/<site>/<cycle>/rank             Ranks a report without saving.
+  POST /query                                 Runs a read-only SQL query.
+
+The body of a query is {"sql": "...", "reports": ["<site>/<cycle>", ...]}.
+All reports are loaded if none are specified. Ranking requires a GitHub
+token to fetch the rules.`,
+
+	Example: `
+  pedsnet-dqa serve --addr=localhost:8080 SecondaryReports
+
+  curl localhost:8080/sites/CHOP/ETLv4/tables/person`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		addr := viper.GetString("serve.addr")
+		token := viper.GetString("serve.token")
+
+		cat, err := catalog.Load(catalog.ConfigOptions("serve"))
+		if err != nil {
+			cmd.Printf("Error loading catalog: %s\n", err)
+			os.Exit(1)
+		}
+
+		s := NewServer(args[0], cat)
+
+		if token != "" {
+			gc, err := gh.NewClient(gh.Config{
+				Token:   token,
+				BaseURL: viper.GetString("serve.github-url"),
+				Log:     os.Stderr,
+			})
+			if err != nil {
+				cmd.Println(err)
+				os.Exit(1)
+			}
+
+			s.Rules = fetchRules(func(name, version string) (rules.Rules, error) {
+				model, err := models.Revision(models.ConfigProvider("serve"), name, version)
+				if err != nil {
+					return nil, err
+				}
+
+				return rules.Fetch(gc, model)
+			})
+		}
+
+		log.Printf("Serving '%s' on %s", args[0], addr)
+
+		if err := http.ListenAndServe(addr, s); err != nil {
+			cmd.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// fetchRules caches the rules of each model revision.
+func fetchRules(fetch RulesFunc) RulesFunc {
+	var mu sync.Mutex
+
+	cache := make(map[string]rules.Rules)
+
+	return func(name, version string) (rules.Rules, error) {
+		key := fmt.Sprintf("%s/%s", name, version)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if rs, ok := cache[key]; ok {
+			return rs, nil
+		}
+
+		rs, err := fetch(name, version)
+		if err != nil {
+			return nil, err
+		}
+
+		cache[key] = rs
+
+		return rs, nil
+	}
+}
+
+func init() {
+	flags := Cmd.Flags()
+
+	flags.String("addr", "localhost:8080", "Address to listen on.")
+	flags.String("token", "", "GitHub token used to fetch the ranking rules.")
+	flags.String("github-url", "", "Base URL of the GitHub API.")
+	flags.String("url", dms.DefaultServiceURL, "Data models service URL.")
+
+	viper.BindPFlag("serve.addr", flags.Lookup("addr"))
+	viper.BindPFlag("serve.token", flags.Lookup("token"))
+	viper.BindPFlag("serve.github-url", flags.Lookup("github-url"))
+	viper.BindPFlag("serve.url", flags.Lookup("url"))
+
+	catalog.BindFlags(flags, "serve")
+	models.BindFlags(flags, "serve")
+}
@@ -0,0 +1,567 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
+	"github.com/PEDSnet/tools/cmd/dqa/query"
+	"github.com/PEDSnet/tools/cmd/dqa/rank"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/dqa/rules"
+	"github.com/PEDSnet/tools/cmd/dqa/validate"
+)
+
+// RulesFunc returns the ranking rules for a model revision.
+type RulesFunc func(model, version string) (rules.Rules, error)
+
+// Server serves a tree of Secondary Reports organized by site and cycle:
+//
+//	<root>/<site>/<cycle>/<table>.csv
+//
+// The routes are:
+//
+//	GET  /sites                                 Sites and their cycles.
+//	GET  /sites/<site>                          Cycles of a site.
+//	GET  /sites/<site>/<cycle>                  Tables of a report.
+//	GET  /sites/<site>/<cycle>/tables/<table>   Results of a table.
+//	POST /sites/<site>/<cycle>/validate         Validates a report.
+//	POST /sites/<site>/<cycle>/rank             Ranks a report without saving.
+//	POST /query                                 Runs a read-only SQL query.
+type Server struct {
+	Root    string
+	Catalog *catalog.Catalog
+
+	// Rules returns the ranking rules. Ranking is disabled if it is nil.
+	Rules RulesFunc
+
+	cache *Cache
+}
+
+// NewServer returns a server for the reports in the root directory.
+func NewServer(root string, cat *catalog.Catalog) *Server {
+	return &Server{
+		Root:    root,
+		Catalog: cat,
+		cache:   NewCache(),
+	}
+}
+
+// Site is a site and its cycles.
+type Site struct {
+	Name   string   `json:"name"`
+	Cycles []string `json:"cycles"`
+}
+
+// Table is a summary of a file in a report.
+type Table struct {
+	Name    string `json:"name"`
+	File    string `json:"file"`
+	Results int    `json:"results"`
+	Issues  int    `json:"issues"`
+}
+
+// Report is a summary of the report of a site for a cycle.
+type Report struct {
+	Site   string   `json:"site"`
+	Cycle  string   `json:"cycle"`
+	Tables []*Table `json:"tables"`
+}
+
+// Validation is the result of validating a report.
+type Validation struct {
+	Site   string                     `json:"site"`
+	Cycle  string                     `json:"cycle"`
+	Files  int                        `json:"files"`
+	Valid  bool                       `json:"valid"`
+	Errors map[string]validate.Errors `json:"errors"`
+}
+
+// RankChange is a result matched by a ranking rule.
+type RankChange struct {
+	File       string `json:"file"`
+	Table      string `json:"table"`
+	Field      string `json:"field"`
+	CheckCode  string `json:"check_code"`
+	Prevalence string `json:"prevalence"`
+	Rule       string `json:"rule"`
+	OldRank    string `json:"old_rank"`
+	NewRank    string `json:"new_rank"`
+	Changed    bool   `json:"changed"`
+}
+
+// Ranking is the result of ranking a report.
+type Ranking struct {
+	Site    string        `json:"site"`
+	Cycle   string        `json:"cycle"`
+	Model   string        `json:"model"`
+	Version string        `json:"version"`
+	Changes []*RankChange `json:"changes"`
+}
+
+// QueryRequest is the body of a query. Reports are paths of the form
+// <site>/<cycle>. All reports are loaded if none are specified.
+type QueryRequest struct {
+	SQL     string   `json:"sql"`
+	Reports []string `json:"reports"`
+}
+
+// QueryResult is the result of a query.
+type QueryResult struct {
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+func (q *QueryResult) WriteHeader(cols []string) error {
+	q.Columns = cols
+	return nil
+}
+
+func (q *QueryResult) WriteRow(row []string) error {
+	// The row is reused by the caller.
+	q.Rows = append(q.Rows, append([]string(nil), row...))
+	return nil
+}
+
+func (q *QueryResult) Flush() error {
+	return nil
+}
+
+// httpError is an error with the status of the response.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func errorf(status int, format string, args ...interface{}) error {
+	return &httpError{
+		status: status,
+		msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	if e, ok := err.(*httpError); ok {
+		status = e.status
+	}
+
+	writeJSON(w, status, map[string]string{
+		"message": err.Error(),
+	})
+}
+
+// validName returns true if the name is a single path element.
+func validName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\`)
+}
+
+// subdirs returns the sorted names of the directories in dir.
+func subdirs(dir string) ([]string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	for _, fi := range fis {
+		if fi.IsDir() && validName(fi.Name()) {
+			names = append(names, fi.Name())
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// dir returns the directory of the path elements if it exists.
+func (s *Server) dir(elems ...string) (string, error) {
+	for _, e := range elems {
+		if !validName(e) {
+			return "", errorf(http.StatusBadRequest, "Invalid name '%s'", e)
+		}
+	}
+
+	dir := filepath.Join(append([]string{s.Root}, elems...)...)
+
+	fi, err := os.Stat(dir)
+	if err != nil || !fi.IsDir() {
+		return "", errorf(http.StatusNotFound, "'%s' not found", strings.Join(elems, "/"))
+	}
+
+	return dir, nil
+}
+
+// files returns the cached files of the report of a site for a cycle.
+func (s *Server) files(site, cycle string) (map[string]*results.File, error) {
+	dir, err := s.dir(site, cycle)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := s.cache.Files(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading files in '%s/%s': %s", site, cycle, err)
+	}
+
+	return files, nil
+}
+
+// Sites returns the sites and their cycles.
+func (s *Server) Sites() ([]*Site, error) {
+	names, err := subdirs(s.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	sites := []*Site{}
+
+	for _, name := range names {
+		site, err := s.Site(name)
+		if err != nil {
+			return nil, err
+		}
+
+		sites = append(sites, site)
+	}
+
+	return sites, nil
+}
+
+// Site returns a site and its cycles.
+func (s *Server) Site(name string) (*Site, error) {
+	dir, err := s.dir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cycles, err := subdirs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if cycles == nil {
+		cycles = []string{}
+	}
+
+	return &Site{
+		Name:   name,
+		Cycles: cycles,
+	}, nil
+}
+
+// Report returns a summary of the tables of a report.
+func (s *Server) Report(site, cycle string) (*Report, error) {
+	files, err := s.files(site, cycle)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := &Report{
+		Site:   site,
+		Cycle:  cycle,
+		Tables: []*Table{},
+	}
+
+	for name, f := range files {
+		t := &Table{
+			Name:    strings.TrimSuffix(name, filepath.Ext(name)),
+			File:    name,
+			Results: len(f.Results),
+		}
+
+		for _, r := range f.Results {
+			if r.IsIssue() || r.IsPersistent() {
+				t.Issues++
+			}
+		}
+
+		rep.Tables = append(rep.Tables, t)
+	}
+
+	sort.Slice(rep.Tables, func(i, j int) bool {
+		return rep.Tables[i].Name < rep.Tables[j].Name
+	})
+
+	return rep, nil
+}
+
+// Results returns the results of a table in a report.
+func (s *Server) Results(site, cycle, table string) (results.Results, error) {
+	files, err := s.files(site, cycle)
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := files[fmt.Sprintf("%s.csv", table)]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "No results for table '%s' in '%s/%s'", table, site, cycle)
+	}
+
+	if f.Results == nil {
+		return results.Results{}, nil
+	}
+
+	return f.Results, nil
+}
+
+// Validate validates a report.
+func (s *Server) Validate(site, cycle string) (*Validation, error) {
+	files, err := s.files(site, cycle)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := validate.Files(files, s.Catalog)
+
+	return &Validation{
+		Site:   site,
+		Cycle:  cycle,
+		Files:  len(files),
+		Valid:  len(errs) == 0,
+		Errors: errs,
+	}, nil
+}
+
+// Rank ranks a copy of a report. The files are not changed.
+func (s *Server) Rank(site, cycle string) (*Ranking, error) {
+	if s.Rules == nil {
+		return nil, errorf(http.StatusNotImplemented, "Ranking is not enabled. Start the server with a GitHub token.")
+	}
+
+	files, err := s.files(site, cycle)
+	if err != nil {
+		return nil, err
+	}
+
+	files = copyFiles(files)
+
+	name, version, err := rank.DetectModel(files)
+	if err != nil {
+		return nil, errorf(http.StatusUnprocessableEntity, "%s", err)
+	}
+
+	rs, err := s.Rules(name, version)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching rules: %s", err)
+	}
+
+	changes, err := rank.Apply(files, rs)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Ranking{
+		Site:    site,
+		Cycle:   cycle,
+		Model:   name,
+		Version: version,
+		Changes: []*RankChange{},
+	}
+
+	for _, c := range changes {
+		out.Changes = append(out.Changes, &RankChange{
+			File:       c.File,
+			Table:      c.Result.Table,
+			Field:      c.Result.Field,
+			CheckCode:  c.Result.CheckCode,
+			Prevalence: c.Result.Prevalence,
+			Rule:       c.Rule.Type,
+			OldRank:    c.OldRank.String(),
+			NewRank:    c.Rule.Rank.String(),
+			Changed:    c.Changed(),
+		})
+	}
+
+	return out, nil
+}
+
+// isReadOnly returns true if the statement is a single query.
+func isReadOnly(stmt string) bool {
+	stmt = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+
+	if strings.Contains(stmt, ";") {
+		return false
+	}
+
+	toks := strings.Fields(stmt)
+
+	if len(toks) == 0 {
+		return false
+	}
+
+	switch strings.ToLower(toks[0]) {
+	case "select", "with":
+		return true
+	}
+
+	return false
+}
+
+// Query runs a read-only SQL statement against the results of the reports
+// and the catalog.
+func (s *Server) Query(req *QueryRequest) (*QueryResult, error) {
+	if !isReadOnly(req.SQL) {
+		return nil, errorf(http.StatusBadRequest, "Only a single SELECT statement is allowed")
+	}
+
+	reports := req.Reports
+
+	// Load all reports.
+	if len(reports) == 0 {
+		sites, err := s.Sites()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, site := range sites {
+			for _, cycle := range site.Cycles {
+				reports = append(reports, site.Name+"/"+cycle)
+			}
+		}
+	}
+
+	db, err := query.Open()
+	if err != nil {
+		return nil, fmt.Errorf("Error initializing database: %s", err)
+	}
+	defer db.Close()
+
+	if s.Catalog != nil {
+		if err := db.LoadCatalog(s.Catalog); err != nil {
+			return nil, fmt.Errorf("Error loading catalog into the database: %s", err)
+		}
+	}
+
+	for _, path := range reports {
+		toks := strings.Split(path, "/")
+
+		if len(toks) != 2 {
+			return nil, errorf(http.StatusBadRequest, "Invalid report '%s'. The format is <site>/<cycle>", path)
+		}
+
+		files, err := s.files(toks[0], toks[1])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			if err := db.Load(f.Header(), f.Results); err != nil {
+				return nil, fmt.Errorf("Error loading results into the database: %s", err)
+			}
+		}
+	}
+
+	if err := db.ReadOnly(); err != nil {
+		return nil, err
+	}
+
+	res := &QueryResult{
+		Rows: [][]string{},
+	}
+
+	if err := db.Query(res, req.SQL); err != nil {
+		return nil, errorf(http.StatusBadRequest, "Query error: %s", err)
+	}
+
+	return res, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var toks []string
+
+	if p := strings.Trim(r.URL.Path, "/"); p != "" {
+		toks = strings.Split(p, "/")
+	}
+
+	var (
+		handle func() (interface{}, error)
+		method = http.MethodGet
+	)
+
+	switch {
+	case len(toks) == 1 && toks[0] == "query":
+		method = http.MethodPost
+		handle = func() (interface{}, error) {
+			var req QueryRequest
+
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				return nil, errorf(http.StatusBadRequest, "Invalid query request: %s", err)
+			}
+
+			return s.Query(&req)
+		}
+
+	case len(toks) == 0 || toks[0] != "sites":
+
+	case len(toks) == 1:
+		handle = func() (interface{}, error) {
+			return s.Sites()
+		}
+
+	case len(toks) == 2:
+		handle = func() (interface{}, error) {
+			return s.Site(toks[1])
+		}
+
+	case len(toks) == 3:
+		handle = func() (interface{}, error) {
+			return s.Report(toks[1], toks[2])
+		}
+
+	case len(toks) == 4 && toks[3] == "validate":
+		method = http.MethodPost
+		handle = func() (interface{}, error) {
+			return s.Validate(toks[1], toks[2])
+		}
+
+	case len(toks) == 4 && toks[3] == "rank":
+		method = http.MethodPost
+		handle = func() (interface{}, error) {
+			return s.Rank(toks[1], toks[2])
+		}
+
+	case len(toks) == 5 && toks[3] == "tables":
+		handle = func() (interface{}, error) {
+			return s.Results(toks[1], toks[2], toks[4])
+		}
+	}
+
+	if handle == nil {
+		writeError(w, errorf(http.StatusNotFound, "Not found"))
+		return
+	}
+
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, errorf(http.StatusMethodNotAllowed, "Method %s not allowed", r.Method))
+		return
+	}
+
+	v, err := handle()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, v)
+}
@@ -0,0 +1,232 @@
+package serve
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/dqa/rules"
+)
+
+func newResult(field, checkCode, prevalence string) *results.Result {
+	r := results.NewResult()
+	r.Model = "pedsnet"
+	r.ModelVersion = "2.2.0"
+	r.DataVersion = "pedsnet-2.2.0-CHOP-ETLv4"
+	r.Table = "person"
+	r.Field = field
+	r.CheckCode = checkCode
+	r.Prevalence = prevalence
+	r.Rank = results.LowRank
+	return r
+}
+
+func writeReport(t *testing.T, dir string, rs ...*results.Result) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f := results.NewFile("person.csv")
+	f.Results = rs
+
+	if err := results.WriteFile(filepath.Join(dir, "person.csv"), f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, string) {
+	root, err := ioutil.TempDir("", "dqa-serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeReport(t, filepath.Join(root, "CHOP", "ETLv4"),
+		newResult("year_of_birth", "CA-005", "high"),
+		newResult("gender_concept_id", "ZZ-999", "low"),
+	)
+
+	if err := os.MkdirAll(filepath.Join(root, "Boston"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown check codes are only reported for a fetched catalog.
+	cat := catalog.Builtin()
+	cat.Source = catalog.SourceDir
+
+	s := NewServer(root, cat)
+
+	any := &rules.Condition{
+		Name: "any",
+		Test: func(r *results.Result) bool { return true },
+	}
+
+	s.Rules = func(model, version string) (rules.Rules, error) {
+		return rules.Rules{
+			{Type: "Test", Table: "person", Condition: any, CheckCode: "ca-005", Prevalence: "high", Rank: results.HighRank},
+		}, nil
+	}
+
+	return httptest.NewServer(s), root
+}
+
+func do(t *testing.T, method, url string, body interface{}, status int, v interface{}) {
+	var buf bytes.Buffer
+
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+
+	req, err := http.NewRequest(method, url, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != status {
+		b, _ := ioutil.ReadAll(resp.Body)
+		t.Fatalf("%s %s: expected status %d, got %d: %s", method, url, status, resp.StatusCode, b)
+	}
+
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestServerSites(t *testing.T) {
+	srv, root := newTestServer(t)
+	defer srv.Close()
+	defer os.RemoveAll(root)
+
+	var sites []*Site
+	do(t, "GET", srv.URL+"/sites", nil, http.StatusOK, &sites)
+
+	if len(sites) != 2 || sites[0].Name != "Boston" || sites[1].Name != "CHOP" {
+		t.Fatalf("unexpected sites: %v", sites)
+	}
+
+	if len(sites[1].Cycles) != 1 || sites[1].Cycles[0] != "ETLv4" {
+		t.Errorf("unexpected cycles: %v", sites[1].Cycles)
+	}
+
+	var rep Report
+	do(t, "GET", srv.URL+"/sites/CHOP/ETLv4", nil, http.StatusOK, &rep)
+
+	if len(rep.Tables) != 1 || rep.Tables[0].Name != "person" || rep.Tables[0].Results != 2 {
+		t.Errorf("unexpected tables: %v", rep.Tables)
+	}
+
+	do(t, "GET", srv.URL+"/sites/CHOP/ETLv5", nil, http.StatusNotFound, nil)
+	do(t, "GET", srv.URL+"/sites/CHOP/..", nil, http.StatusBadRequest, nil)
+	do(t, "GET", srv.URL+"/sites/CHOP/ETLv4/tables/death", nil, http.StatusNotFound, nil)
+	do(t, "GET", srv.URL+"/sites/CHOP/ETLv4/validate", nil, http.StatusMethodNotAllowed, nil)
+}
+
+func TestServerResultsCache(t *testing.T) {
+	srv, root := newTestServer(t)
+	defer srv.Close()
+	defer os.RemoveAll(root)
+
+	var rs []*results.Result
+	do(t, "GET", srv.URL+"/sites/CHOP/ETLv4/tables/person", nil, http.StatusOK, &rs)
+
+	if len(rs) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(rs))
+	}
+
+	// Change the file. The modification time is set explicitly since the
+	// resolution of the file system may be too coarse.
+	dir := filepath.Join(root, "CHOP", "ETLv4")
+	writeReport(t, dir, newResult("year_of_birth", "CA-005", "high"))
+
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "person.csv"), later, later)
+
+	do(t, "GET", srv.URL+"/sites/CHOP/ETLv4/tables/person", nil, http.StatusOK, &rs)
+
+	if len(rs) != 1 {
+		t.Errorf("expected the cache to be invalidated, got %d results", len(rs))
+	}
+}
+
+func TestServerValidateRank(t *testing.T) {
+	srv, root := newTestServer(t)
+	defer srv.Close()
+	defer os.RemoveAll(root)
+
+	var v Validation
+	do(t, "POST", srv.URL+"/sites/CHOP/ETLv4/validate", nil, http.StatusOK, &v)
+
+	if v.Valid || v.Files != 1 || len(v.Errors["person.csv"]) != 1 {
+		t.Errorf("expected one line with errors, got %v", v.Errors)
+	}
+
+	var rk Ranking
+	do(t, "POST", srv.URL+"/sites/CHOP/ETLv4/rank", nil, http.StatusOK, &rk)
+
+	if len(rk.Changes) != 1 || !rk.Changes[0].Changed || rk.Changes[0].NewRank != "High" {
+		t.Fatalf("unexpected changes: %v", rk.Changes)
+	}
+
+	// Ranking is a dry run.
+	var rs []*results.Result
+	do(t, "GET", srv.URL+"/sites/CHOP/ETLv4/tables/person", nil, http.StatusOK, &rs)
+
+	for _, r := range rs {
+		if r.Rank != results.LowRank {
+			t.Errorf("expected the rank to be unchanged, got %s", r.Rank)
+		}
+	}
+}
+
+func TestServerQuery(t *testing.T) {
+	srv, root := newTestServer(t)
+	defer srv.Close()
+	defer os.RemoveAll(root)
+
+	var res QueryResult
+
+	req := &QueryRequest{
+		SQL: `select field from results where check_code = 'CA-005'`,
+	}
+
+	do(t, "POST", srv.URL+"/query", req, http.StatusOK, &res)
+
+	if len(res.Columns) != 1 || len(res.Rows) != 1 || res.Rows[0][0] != "year_of_birth" {
+		t.Errorf("unexpected result: %v", res)
+	}
+
+	req = &QueryRequest{
+		SQL:     `select count(*) from results`,
+		Reports: []string{"CHOP/ETLv4"},
+	}
+
+	do(t, "POST", srv.URL+"/query", req, http.StatusOK, &res)
+
+	if res.Rows[0][0] != "2" {
+		t.Errorf("expected 2 results, got %v", res.Rows)
+	}
+
+	for _, stmt := range []string{
+		`delete from results`,
+		`select 1; delete from results`,
+	} {
+		do(t, "POST", srv.URL+"/query", &QueryRequest{SQL: stmt}, http.StatusBadRequest, nil)
+	}
+
+	do(t, "POST", srv.URL+"/query", &QueryRequest{SQL: "select 1", Reports: []string{"CHOP"}}, http.StatusBadRequest, nil)
+}
@@ -0,0 +1,204 @@
+package upgrade
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/PEDSnet/tools/cmd/dqa/models"
+	"github.com/PEDSnet/tools/cmd/dqa/rank"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/internal/datamodels"
+	dms "github.com/chop-dbhi/data-models-service/client"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var Cmd = &cobra.Command{
+	Use: "upgrade-model <path>",
+
+	Short: "Upgrades a Secondary Report to another version of the data model.",
+
+	Long: `Compares the model revision of the Secondary Report with the target
+revision and upgrades the files in place:
+
+- Known table and field renames in the --renames file are applied.
+- The model version and data version of all results are rewritten.
+- Files are created for tables added in the target revision.
+- Results on tables or fields that are not in the target revision are
+  flagged for review. Their status is set to "under review" unless the
+  issue is closed.`,
+
+	Example: `Upgrade a report from PEDSnet v2.2.0 to v3.0.0:
+  pedsnet-dqa upgrade-model --to=3.0.0 --renames=renames.json SecondaryReports/CHOP/ETLv5`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		dir := args[0]
+
+		modelName := viper.GetString("upgrade.model")
+		fromVersion := viper.GetString("upgrade.from")
+		toVersion := viper.GetString("upgrade.to")
+		dryRun := viper.GetBool("upgrade.dry-run")
+
+		if toVersion == "" {
+			cmd.Println("Target model version required. Specify using the --to option.")
+			os.Exit(1)
+		}
+
+		files, err := results.ReadFromDir(dir)
+		if err != nil {
+			cmd.Println(err)
+			os.Exit(1)
+		}
+
+		// Detect the current revision from the results. All results must
+		// be for the same revision.
+		if fromVersion == "" {
+			name, version, err := rank.DetectModel(files)
+			if err != nil {
+				cmd.Println(err)
+				cmd.Println("Could not detect the model version of the report. Specify using the --from option.")
+				os.Exit(1)
+			}
+
+			if modelName == "" {
+				modelName = name
+			}
+
+			fromVersion = version
+		}
+
+		if modelName == "" {
+			modelName = "pedsnet"
+		}
+
+		var renames *Renames
+
+		if fn := viper.GetString("upgrade.renames"); fn != "" {
+			if renames, err = ReadRenames(fn); err != nil {
+				cmd.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		p := &datamodels.Provider{
+			Service:  viper.GetString("upgrade.url"),
+			CacheDir: viper.GetString("upgrade.model-cache"),
+			Offline:  viper.GetBool("upgrade.offline"),
+		}
+
+		from, err := models.Revision(p, modelName, fromVersion)
+		if err != nil {
+			cmd.Printf("Error fetching model revision '%s/%s': %s\n", modelName, fromVersion, err)
+			os.Exit(1)
+		}
+
+		to, err := models.Revision(p, modelName, toVersion)
+		if err != nil {
+			cmd.Printf("Error fetching model revision '%s/%s': %s\n", modelName, toVersion, err)
+			os.Exit(1)
+		}
+
+		u := &Upgrade{
+			From:    from,
+			To:      to,
+			Renames: renames,
+		}
+
+		upgraded, rep := u.Apply(files)
+
+		if !dryRun {
+			if err := writeFiles(dir, upgraded, rep); err != nil {
+				cmd.Printf("Error writing files: %s\n", err)
+				os.Exit(1)
+			}
+		}
+
+		printReport(cmd, rep, from, to)
+	},
+}
+
+func writeFiles(dir string, files map[string]*results.File, rep *Report) error {
+	var names []string
+
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := results.WriteFile(filepath.Join(dir, name), files[name]); err != nil {
+			return err
+		}
+	}
+
+	// Remove the files that were renamed.
+	for _, r := range rep.Renamed {
+		if _, ok := files[r.Old]; ok {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, r.Old)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printReport(cmd *cobra.Command, rep *Report, from, to *dms.Model) {
+	cmd.Printf("Upgraded from '%s/%s' to '%s/%s'\n", from.Name, from.Version, to.Name, to.Version)
+	cmd.Printf("%d results updated\n", rep.Updated)
+
+	for _, r := range rep.Renamed {
+		cmd.Printf("Renamed %s to %s\n", r.Old, r.New)
+	}
+
+	for _, name := range rep.Created {
+		cmd.Printf("Created %s\n", name)
+	}
+
+	if len(rep.Flagged) == 0 {
+		return
+	}
+
+	cmd.Printf("\n%d results flagged for review:\n", len(rep.Flagged))
+
+	tw := tablewriter.NewWriter(cmd.OutOrStdout())
+	tw.SetHeader([]string{"File", "Table", "Field", "Check Code", "Reason"})
+
+	for _, f := range rep.Flagged {
+		tw.Append([]string{f.File, f.Result.Table, f.Result.Field, f.Result.CheckCode, f.Reason})
+	}
+
+	tw.Render()
+}
+
+func init() {
+	flags := Cmd.Flags()
+
+	flags.String("model", "", "The model of the report. Defaults to the model of the results.")
+	flags.String("from", "", "The current model version of the report. Defaults to the version of the results.")
+	flags.String("to", "", "The model version to upgrade to.")
+	flags.String("renames", "", "JSON file of the table and field renames between the revisions.")
+	flags.Bool("dry-run", false, "Report the changes without writing the files.")
+	flags.String("url", dms.DefaultServiceURL, "Data models service URL.")
+	flags.String("model-cache", datamodels.DefaultCacheDir(), "Directory of cached model revisions.")
+	flags.Bool("offline", false, "Only read model revisions from the cache.")
+
+	viper.BindPFlag("upgrade.model", flags.Lookup("model"))
+	viper.BindPFlag("upgrade.from", flags.Lookup("from"))
+	viper.BindPFlag("upgrade.to", flags.Lookup("to"))
+	viper.BindPFlag("upgrade.renames", flags.Lookup("renames"))
+	viper.BindPFlag("upgrade.dry-run", flags.Lookup("dry-run"))
+	viper.BindPFlag("upgrade.url", flags.Lookup("url"))
+	viper.BindPFlag("upgrade.model-cache", flags.Lookup("model-cache"))
+	viper.BindPFlag("upgrade.offline", flags.Lookup("offline"))
+}
@@ -0,0 +1,186 @@
+package upgrade
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/internal/testutil"
+	"github.com/PEDSnet/tools/cmd/dqa/models"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/internal/datamodels"
+)
+
+func TestUpgradeApply(t *testing.T) {
+	p := &datamodels.Provider{
+		CacheDir: "testdata/models",
+		Offline:  true,
+	}
+
+	from, err := models.Revision(p, "pedsnet", "2.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	to, err := models.Revision(p, "pedsnet", "3.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renames, err := ReadRenames("testdata/renames.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := results.ReadFromDir("testdata/ETLv5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := &Upgrade{
+		From:    from,
+		To:      to,
+		Renames: renames,
+	}
+
+	out, rep := u.Apply(files)
+
+	if rep.Updated != 5 {
+		t.Errorf("expected 5 results updated, got %d", rep.Updated)
+	}
+
+	if len(rep.Created) != 1 || rep.Created[0] != "adt_occurrence.csv" {
+		t.Errorf("expected adt_occurrence.csv to be created, got %v", rep.Created)
+	}
+
+	if len(rep.Renamed) != 1 || rep.Renamed[0].New != "payer_plan_period.csv" {
+		t.Errorf("expected visit_payer.csv to be renamed, got %v", rep.Renamed)
+	}
+
+	flagged := make(map[string]string)
+
+	for _, f := range rep.Flagged {
+		flagged[f.Result.Table+"."+f.Result.Field] = f.Reason
+
+		if f.Result.Status != FlaggedStatus {
+			t.Errorf("expected flagged result to be %s, got %s", FlaggedStatus, f.Result.Status)
+		}
+	}
+
+	if len(flagged) != 2 || flagged["person.pn_gestational_age"] == "" || flagged["death.death_date"] == "" {
+		t.Errorf("expected removed field and table to be flagged, got %v", flagged)
+	}
+
+	r := out["payer_plan_period.csv"].Results[0]
+
+	if r.Table != "payer_plan_period" || r.Field != "plan_source_value" {
+		t.Errorf("expected renamed table and field, got %s.%s", r.Table, r.Field)
+	}
+
+	if r.ModelVersion != "3.0.0" || r.DataVersion != "pedsnet-3.0.0-CHOP-ETLv5" {
+		t.Errorf("expected version 3.0.0, got %s (%s)", r.ModelVersion, r.DataVersion)
+	}
+}
+
+func TestUpgradeFields(t *testing.T) {
+	p := &datamodels.Provider{
+		CacheDir: "testdata/models",
+		Offline:  true,
+	}
+
+	from, err := models.Revision(p, "pedsnet", "2.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	to, err := models.Revision(p, "pedsnet", "3.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renames, err := ReadRenames("testdata/renames.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := results.NewResult()
+	r.Table = "person"
+	r.Field = "time_of_birth, pn_gestational_age"
+	r.CheckCode = "BA-001"
+
+	// Closed issues are flagged, but keep their status.
+	closed := results.NewResult()
+	closed.Table = "person"
+	closed.Field = "pn_gestational_age"
+	closed.CheckCode = "CA-005"
+	closed.Status = "withdrawn"
+
+	f := results.NewFile("person.csv")
+	f.Results = results.Results{r, closed}
+
+	u := &Upgrade{
+		From:    from,
+		To:      to,
+		Renames: renames,
+	}
+
+	_, rep := u.Apply(map[string]*results.File{"person.csv": f})
+
+	if r.Field != "birth_datetime,pn_gestational_age" {
+		t.Errorf("expected each field to be renamed, got %s", r.Field)
+	}
+
+	if len(rep.Flagged) != 2 || rep.Flagged[0].Reason != "field person.pn_gestational_age is not in pedsnet/3.0.0" {
+		t.Errorf("expected the removed field to be flagged, got %v", rep.Flagged)
+	}
+
+	if r.Status != FlaggedStatus {
+		t.Errorf("expected %s, got %s", FlaggedStatus, r.Status)
+	}
+
+	if closed.Status != "withdrawn" {
+		t.Errorf("expected closed issue to keep its status, got %s", closed.Status)
+	}
+}
+
+func TestUpgradeCmd(t *testing.T) {
+	dir := testutil.CopyDir(t, "testdata/ETLv5")
+	defer os.RemoveAll(dir)
+
+	Cmd.SetArgs([]string{"--offline", "--model-cache=testdata/models", "--to=3.0.0", "--renames=testdata/renames.json", dir})
+	Cmd.SetOutput(ioutil.Discard)
+
+	if err := Cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := results.ReadFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"person.csv", "payer_plan_period.csv", "death.csv", "adt_occurrence.csv"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("expected %s to exist", name)
+		}
+	}
+
+	if _, ok := files["visit_payer.csv"]; ok {
+		t.Error("expected visit_payer.csv to be removed")
+	}
+
+	for _, r := range files["person.csv"].Results {
+		if r.Field == "time_of_birth" {
+			t.Error("expected time_of_birth to be renamed")
+		}
+
+		if r.ModelVersion != "3.0.0" || r.DataVersion != "pedsnet-3.0.0-CHOP-ETLv5" {
+			t.Errorf("expected version 3.0.0, got %s (%s)", r.ModelVersion, r.DataVersion)
+		}
+
+		// Flagged results are saved for review.
+		if r.Field == "pn_gestational_age" && r.Status != FlaggedStatus {
+			t.Errorf("expected pn_gestational_age to be %s, got %s", FlaggedStatus, r.Status)
+		}
+	}
+}
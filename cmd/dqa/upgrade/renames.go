@@ -0,0 +1,83 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Rename renames a table or a field of a table between model revisions.
+type Rename struct {
+	Table string `json:"table,omitempty"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Renames are the known table and field renames between two model
+// revisions. The table of a field rename is the table name in the old
+// revision.
+type Renames struct {
+	Tables []*Rename `json:"tables"`
+	Fields []*Rename `json:"fields"`
+}
+
+// Table returns the new name of a table or an empty string if it was not
+// renamed.
+func (r *Renames) Table(table string) string {
+	if r == nil {
+		return ""
+	}
+
+	for _, x := range r.Tables {
+		if x.Old == table {
+			return x.New
+		}
+	}
+
+	return ""
+}
+
+// Field returns the new name of a single field of a table or an empty string
+// if it was not renamed.
+func (r *Renames) Field(table, field string) string {
+	if r == nil {
+		return ""
+	}
+
+	for _, x := range r.Fields {
+		if x.Table == table && x.Old == field {
+			return x.New
+		}
+	}
+
+	return ""
+}
+
+// ReadRenames reads the renames from a JSON mapping file.
+func ReadRenames(path string) (*Renames, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r Renames
+
+	if err := json.NewDecoder(f).Decode(&r); err != nil {
+		return nil, fmt.Errorf("Error decoding renames file '%s': %s", path, err)
+	}
+
+	for i, x := range r.Tables {
+		if x.Old == "" || x.New == "" {
+			return nil, fmt.Errorf("Table rename %d in '%s' requires an old and new name", i, path)
+		}
+	}
+
+	for i, x := range r.Fields {
+		if x.Table == "" || x.Old == "" || x.New == "" {
+			return nil, fmt.Errorf("Field rename %d in '%s' requires a table and an old and new name", i, path)
+		}
+	}
+
+	return &r, nil
+}
@@ -0,0 +1,210 @@
+package upgrade
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+	dms "github.com/chop-dbhi/data-models-service/client"
+)
+
+// FlaggedStatus is the status of results that are flagged by an upgrade.
+const FlaggedStatus = "under review"
+
+// closedStatuses are the statuses of issues that are no longer open. Flagged
+// results with these statuses keep them so they are not carried forward.
+var closedStatuses = map[string]struct{}{
+	"withdrawn": {},
+	"resolved":  {},
+}
+
+// isClosed returns true if the status of the result is closed.
+func isClosed(r *results.Result) bool {
+	_, ok := closedStatuses[strings.ToLower(r.Status)]
+	return ok
+}
+
+// Flag is a result on a table or field that is not in the new model revision.
+type Flag struct {
+	File   string
+	Result *results.Result
+	Reason string
+}
+
+// FileRename is a report file that is renamed with its table.
+type FileRename struct {
+	Old string
+	New string
+}
+
+// Report describes the changes of an upgrade.
+type Report struct {
+	Created []string
+	Renamed []*FileRename
+	Updated int
+	Flagged []*Flag
+}
+
+// Upgrade upgrades the results of a Secondary Report from one model
+// revision to another.
+type Upgrade struct {
+	From    *dms.Model
+	To      *dms.Model
+	Renames *Renames
+}
+
+// dataVersion replaces the model and version of a data version of the form
+// <model>-<version>-<site>-<etl>.
+func dataVersion(v string, m *dms.Model) string {
+	toks := strings.SplitN(v, "-", 3)
+
+	if len(toks) != 3 {
+		return v
+	}
+
+	return fmt.Sprintf("%s-%s-%s", m.Name, m.Version, toks[2])
+}
+
+// check returns the reason the table and fields of a result are not in the
+// new model revision.
+func (u *Upgrade) check(r *results.Result) string {
+	t := u.To.Tables.Get(r.Table)
+
+	if t == nil {
+		return fmt.Sprintf("table %s is not in %s/%s", r.Table, u.To.Name, u.To.Version)
+	}
+
+	if r.Field == "" {
+		return ""
+	}
+
+	var missing []string
+
+	for _, f := range r.Fields() {
+		if t.Fields.Get(f) == nil {
+			missing = append(missing, fmt.Sprintf("%s.%s", r.Table, f))
+		}
+	}
+
+	switch len(missing) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("field %s is not in %s/%s", missing[0], u.To.Name, u.To.Version)
+	}
+
+	return fmt.Sprintf("fields %s are not in %s/%s", strings.Join(missing, ", "), u.To.Name, u.To.Version)
+}
+
+// renameFields renames each field of a result. The field is kept as is if
+// none of the fields are renamed.
+func (u *Upgrade) renameFields(r *results.Result) {
+	if r.Field == "" {
+		return
+	}
+
+	fields := r.Fields()
+	renamed := false
+
+	for i, f := range fields {
+		if x := u.Renames.Field(r.Table, f); x != "" {
+			fields[i] = x
+			renamed = true
+		}
+	}
+
+	if renamed {
+		r.Field = strings.Join(fields, ",")
+	}
+}
+
+// Apply upgrades the files by name. It returns the upgraded files by name
+// which include files created for new tables.
+func (u *Upgrade) Apply(files map[string]*results.File) (map[string]*results.File, *Report) {
+	out := make(map[string]*results.File, len(files))
+	rep := &Report{}
+
+	var names []string
+
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := files[name]
+		target := name
+
+		for _, r := range f.Results {
+			// Field renames refer to the old table name.
+			u.renameFields(r)
+
+			if t := u.Renames.Table(r.Table); t != "" {
+				r.Table = t
+			}
+
+			r.Model = u.To.Name
+			r.ModelVersion = u.To.Version
+			r.DataVersion = dataVersion(r.DataVersion, u.To)
+
+			rep.Updated++
+
+			if reason := u.check(r); reason != "" {
+				// The status is saved with the file so the flag is not
+				// lost once the report is printed.
+				if !isClosed(r) {
+					r.Status = FlaggedStatus
+				}
+
+				rep.Flagged = append(rep.Flagged, &Flag{
+					File:   name,
+					Result: r,
+					Reason: reason,
+				})
+			}
+		}
+
+		// Files are renamed with the table they are named after.
+		if t := u.Renames.Table(strings.TrimSuffix(name, ".csv")); t != "" {
+			target = fmt.Sprintf("%s.csv", t)
+		}
+
+		if target != name {
+			rep.Renamed = append(rep.Renamed, &FileRename{
+				Old: name,
+				New: target,
+			})
+		}
+
+		if o, ok := out[target]; ok {
+			o.Results = append(o.Results, f.Results...)
+		} else {
+			f.Name = target
+			out[target] = f
+		}
+	}
+
+	// Create files for tables added in the new revision.
+	for _, t := range u.To.Tables.List() {
+		if _, ok := results.ExcludedTables[t.Name]; ok {
+			continue
+		}
+
+		if u.From.Tables.Get(t.Name) != nil {
+			continue
+		}
+
+		name := fmt.Sprintf("%s.csv", t.Name)
+
+		if _, ok := out[name]; ok {
+			continue
+		}
+
+		out[name] = results.NewFile(name)
+		rep.Created = append(rep.Created, name)
+	}
+
+	return out, rep
+}
@@ -4,7 +4,7 @@ import (
 	"os"
 	"strings"
 
-	"github.com/PEDSnet/tools/cmd/dqa/results"
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
 	"github.com/spf13/cobra"
 )
 
@@ -22,7 +22,16 @@ var Cmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		var errs map[int][]string
+		// Check codes are validated against the local or cached catalog.
+		cat, err := catalog.Load(catalog.ConfigOptions("validate"))
+		if err != nil {
+			cmd.Printf("Error loading catalog: %s\n", err)
+			os.Exit(1)
+		}
+
+		if cat.Source == catalog.SourceBuiltin {
+			cmd.Println("No catalog has been fetched. Unknown check codes will not be reported.")
+		}
 
 		for _, dir := range args {
 			stat, err := os.Stat(dir)
@@ -38,41 +47,40 @@ var Cmd = &cobra.Command{
 
 			cmd.Printf("Inspecting directory '%s'\n", dir)
 
-			files, err := results.ReadFromDir(dir)
-
+			rep, err := Dir(dir, cat)
 			if err != nil {
-				cmd.Printf("Error reading files: %s\n", err)
+				cmd.Println(err)
 				continue
 			}
 
-			if len(files) == 0 {
+			if rep.Files == 0 {
 				cmd.Printf("No files to validate.")
 				continue
 			}
 
 			cmd.Println("Validating files...")
 
-			hasErrors := false
-
-			for name, file := range files {
-				errs = file.Validate()
-
-				if len(errs) > 0 {
-					hasErrors = true
+			if rep.Valid() {
+				cmd.Println("* Everything looks good!")
+				continue
+			}
 
-					cmd.Printf("* Errors found in '%s':\n", name)
+			for _, name := range rep.Names() {
+				errs := rep.Errors[name]
 
-					for line, msgs := range errs {
-						cmd.Printf("    Line %d: %s\n", line, strings.Join(msgs, ", "))
-					}
+				cmd.Printf("* Errors found in '%s':\n", name)
 
-					cmd.Println("")
+				for _, line := range errs.Lines() {
+					cmd.Printf("    Line %d: %s\n", line, strings.Join(errs[line], ", "))
 				}
-			}
 
-			if !hasErrors {
-				cmd.Println("* Everything looks good!")
+				cmd.Println("")
 			}
 		}
 	},
 }
+
+func init() {
+	flags := Cmd.Flags()
+	catalog.BindFlags(flags, "validate")
+}
@@ -0,0 +1,54 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+)
+
+func TestValidateChecks(t *testing.T) {
+	cat := catalog.Builtin()
+	cat.Source = catalog.SourceDir
+	cat.Check("CA-005").Alias = "num_records"
+
+	f := results.NewFile("person.csv")
+	f.Results = results.Results{
+		{CheckCode: "CA-005", CheckAlias: "num_records"},
+		{CheckCode: "CA-005", CheckAlias: "record_count"},
+		{CheckCode: "ZZ-999"},
+		{CheckCode: "G2-013"},
+	}
+
+	errs := validateChecks(f, cat)
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 lines with errors, got %d", len(errs))
+	}
+
+	if errs[1][0] != "check alias = 'record_count'" {
+		t.Errorf("unexpected error: %s", errs[1][0])
+	}
+
+	if errs[2][0] != "check code = 'ZZ-999'" {
+		t.Errorf("unexpected error: %s", errs[2][0])
+	}
+}
+
+func TestValidateChecksBuiltin(t *testing.T) {
+	cat := catalog.Builtin()
+	cat.Check("CA-005").Alias = "num_records"
+
+	f := results.NewFile("person.csv")
+	f.Results = results.Results{
+		{CheckCode: "CA-005", CheckAlias: "record_count"},
+		{CheckCode: "ZZ-999"},
+	}
+
+	errs := validateChecks(f, cat)
+
+	// Unknown codes are not reported against the builtin catalog.
+	if len(errs) != 1 || len(errs[0]) != 1 {
+		t.Fatalf("expected the alias error only, got %v", errs)
+	}
+}
@@ -0,0 +1,125 @@
+package validate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+)
+
+// Errors are the validation errors of a file by line index.
+type Errors map[int][]string
+
+// Lines returns the sorted line indexes with errors.
+func (e Errors) Lines() []int {
+	var lines []int
+
+	for line := range e {
+		lines = append(lines, line)
+	}
+
+	sort.Ints(lines)
+
+	return lines
+}
+
+// Report is the result of validating a directory.
+type Report struct {
+	Dir string
+
+	// Files is the number of files validated.
+	Files int
+
+	// Errors by file name. Only files with errors are included.
+	Errors map[string]Errors
+}
+
+// Valid returns true if no errors were found.
+func (r *Report) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// Names returns the sorted names of the files with errors.
+func (r *Report) Names() []string {
+	var names []string
+
+	for name := range r.Errors {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// File validates the values of the results in the file and their check
+// codes against the catalog.
+func File(f *results.File, cat *catalog.Catalog) Errors {
+	errs := Errors(f.Validate())
+
+	for line, msgs := range validateChecks(f, cat) {
+		errs[line] = append(errs[line], msgs...)
+	}
+
+	return errs
+}
+
+// Files validates the files by name. Only files with errors are included
+// in the returned map.
+func Files(files map[string]*results.File, cat *catalog.Catalog) map[string]Errors {
+	errs := make(map[string]Errors)
+
+	for name, file := range files {
+		if fe := File(file, cat); len(fe) > 0 {
+			errs[name] = fe
+		}
+	}
+
+	return errs
+}
+
+// Dir validates all files in the directory.
+func Dir(dir string, cat *catalog.Catalog) (*Report, error) {
+	files, err := results.ReadFromDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading files: %s", err)
+	}
+
+	return &Report{
+		Dir:    dir,
+		Files:  len(files),
+		Errors: Files(files, cat),
+	}, nil
+}
+
+// validateChecks validates the check codes and aliases of the results against
+// the catalog. Codes in the legacy format are ignored. The builtin catalog is
+// not complete, so unknown codes are only reported for a fetched catalog.
+func validateChecks(f *results.File, cat *catalog.Catalog) map[int][]string {
+	errs := make(map[int][]string)
+	known := cat.Source != catalog.SourceBuiltin
+
+	for i, res := range f.Results {
+		if !catalog.CodeRe.MatchString(res.CheckCode) {
+			continue
+		}
+
+		check := cat.Check(res.CheckCode)
+
+		if check == nil {
+			if !known {
+				continue
+			}
+
+			errs[i] = append(errs[i], fmt.Sprintf("check code = '%s'", res.CheckCode))
+			continue
+		}
+
+		if res.CheckAlias != "" && check.Alias != "" && res.CheckAlias != check.Alias {
+			errs[i] = append(errs[i], fmt.Sprintf("check alias = '%s'", res.CheckAlias))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,74 @@
+package validate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PEDSnet/tools/cmd/dqa/catalog"
+	"github.com/PEDSnet/tools/cmd/dqa/results"
+)
+
+func TestDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	valid := results.NewResult()
+	valid.ModelVersion = "2.2.0"
+	valid.Table = "person"
+	valid.CheckCode = "CA-005"
+
+	invalid := results.NewResult()
+	invalid.ModelVersion = "2.2.0"
+	invalid.Table = "person"
+	invalid.CheckCode = "ZZ-999"
+	invalid.Prevalence = "most"
+
+	person := results.NewFile("person.csv")
+	person.Results = results.Results{valid, invalid}
+
+	other := results.NewFile("death.csv")
+	other.Results = results.Results{valid}
+
+	for _, f := range []*results.File{person, other} {
+		if err := results.WriteFile(filepath.Join(dir, f.Name), f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cat := catalog.Builtin()
+	cat.Source = catalog.SourceDir
+
+	rep, err := Dir(dir, cat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rep.Files != 2 {
+		t.Errorf("expected 2 files, got %d", rep.Files)
+	}
+
+	if rep.Valid() {
+		t.Fatal("expected errors")
+	}
+
+	if names := rep.Names(); len(names) != 1 || names[0] != "person.csv" {
+		t.Fatalf("expected errors in person.csv only, got %v", names)
+	}
+
+	errs := rep.Errors["person.csv"]
+
+	if lines := errs.Lines(); len(lines) != 1 {
+		t.Fatalf("expected 1 line with errors, got %v", lines)
+	}
+
+	for _, line := range errs.Lines() {
+		if len(errs[line]) != 2 {
+			t.Errorf("expected prevalence and check code errors, got %v", errs[line])
+		}
+	}
+}
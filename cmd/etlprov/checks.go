@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Codes of the structural checks of the step graph.
+const (
+	OrphanStepCheck        = "orphan-step"
+	UnreachableStepCheck   = "unreachable-step"
+	StepWithoutToolCheck   = "step-without-tool"
+	StepWithoutSourceCheck = "step-without-source"
+	StepWithoutPersonCheck = "step-without-person"
+	DisjointEntityCheck    = "entity-in-disjoint-chains"
+)
+
+// GraphCheck is the result of a structural check of the step graph.
+type GraphCheck struct {
+	Code string
+
+	// Description of a single error and multiple errors, e.g. "step is not
+	// connected to other steps".
+	Singular string
+	Plural   string
+
+	Errors []error
+}
+
+// Summary returns the number of errors with the description.
+func (c *GraphCheck) Summary() string {
+	if len(c.Errors) == 1 {
+		return fmt.Sprintf("1 %s", c.Singular)
+	}
+
+	return fmt.Sprintf("%d %s", len(c.Errors), c.Plural)
+}
+
+// chainRoot returns the first step of the chain of previous steps.
+func chainRoot(s *Step) *Step {
+	for s.PreviousStep != nil {
+		s = s.PreviousStep
+	}
+
+	return s
+}
+
+// attributed returns the set of steps attributed to the tools, sources or
+// people.
+func attributed(steps ...[]*Step) map[*Step]struct{} {
+	m := make(map[*Step]struct{})
+
+	for _, ss := range steps {
+		for _, s := range ss {
+			m[s] = struct{}{}
+		}
+	}
+
+	return m
+}
+
+// CheckGraph checks the structure of the steps and returns the checks
+// with errors:
+//
+//   - Orphan steps are not connected to other steps by a previous step.
+//   - Unreachable steps have no source attributed to them or their previous steps.
+//   - Steps without a tool, source or person attributed to them.
+//   - Entities produced by steps in chains that are not connected. Steps that
+//     apply to all entities are ignored.
+func (p *Parser) CheckGraph() []*GraphCheck {
+	steps := p.Steps()
+
+	var tools, sources, people [][]*Step
+
+	for _, t := range p.Tools() {
+		tools = append(tools, t.Steps)
+	}
+
+	for _, s := range p.Sources() {
+		sources = append(sources, s.Steps)
+	}
+
+	for _, x := range p.People() {
+		people = append(people, x.Steps)
+	}
+
+	withTool := attributed(tools...)
+	withSource := attributed(sources...)
+	withPerson := attributed(people...)
+
+	// Steps that are the previous step of another step.
+	hasNext := make(map[*Step]struct{})
+
+	for _, s := range steps {
+		if s.PreviousStep != nil {
+			hasNext[s.PreviousStep] = struct{}{}
+		}
+	}
+
+	orphans := &GraphCheck{
+		Code:     OrphanStepCheck,
+		Singular: "step is not connected to other steps",
+		Plural:   "steps are not connected to other steps",
+	}
+
+	unreachable := &GraphCheck{
+		Code:     UnreachableStepCheck,
+		Singular: "step has no path to a source",
+		Plural:   "steps have no path to a source",
+	}
+
+	noTool := &GraphCheck{
+		Code:     StepWithoutToolCheck,
+		Singular: "step does not have a tool",
+		Plural:   "steps do not have a tool",
+	}
+
+	noSource := &GraphCheck{
+		Code:     StepWithoutSourceCheck,
+		Singular: "step does not have a source",
+		Plural:   "steps do not have a source",
+	}
+
+	noPerson := &GraphCheck{
+		Code:     StepWithoutPersonCheck,
+		Singular: "step does not have a person",
+		Plural:   "steps do not have a person",
+	}
+
+	disjoint := &GraphCheck{
+		Code:     DisjointEntityCheck,
+		Singular: "entity is produced by disjoint chains of steps",
+		Plural:   "entities are produced by disjoint chains of steps",
+	}
+
+	// Roots of the chains producing each entity.
+	roots := make(map[*Entity]map[*Step]struct{})
+
+	for _, s := range steps {
+		_, next := hasNext[s]
+
+		if len(steps) > 1 && s.PreviousStep == nil && !next {
+			orphans.Errors = append(orphans.Errors, fmt.Errorf("Step %d", s.ID))
+		}
+
+		reachable := false
+
+		for x := s; x != nil; x = x.PreviousStep {
+			if _, ok := withSource[x]; ok {
+				reachable = true
+				break
+			}
+		}
+
+		if !reachable {
+			unreachable.Errors = append(unreachable.Errors, fmt.Errorf("Step %d", s.ID))
+		}
+
+		if _, ok := withTool[s]; !ok {
+			noTool.Errors = append(noTool.Errors, fmt.Errorf("Step %d", s.ID))
+		}
+
+		if _, ok := withSource[s]; !ok {
+			noSource.Errors = append(noSource.Errors, fmt.Errorf("Step %d", s.ID))
+		}
+
+		if _, ok := withPerson[s]; !ok {
+			noPerson.Errors = append(noPerson.Errors, fmt.Errorf("Step %d", s.ID))
+		}
+
+		if s.All {
+			continue
+		}
+
+		root := chainRoot(s)
+
+		for _, e := range s.Entities {
+			if _, ok := roots[e]; !ok {
+				roots[e] = make(map[*Step]struct{})
+			}
+
+			roots[e][root] = struct{}{}
+		}
+	}
+
+	for _, e := range p.Entities() {
+		if len(roots[e]) < 2 {
+			continue
+		}
+
+		var ids []int
+
+		for r := range roots[e] {
+			ids = append(ids, r.ID)
+		}
+
+		sort.Ints(ids)
+
+		toks := make([]string, len(ids))
+
+		for i, id := range ids {
+			toks[i] = fmt.Sprint(id)
+		}
+
+		disjoint.Errors = append(disjoint.Errors, fmt.Errorf("%s (chains starting at steps %s)", e.Name, strings.Join(toks, ", ")))
+	}
+
+	var checks []*GraphCheck
+
+	for _, c := range []*GraphCheck{orphans, unreachable, noTool, noSource, noPerson, disjoint} {
+		if len(c.Errors) > 0 {
+			checks = append(checks, c)
+		}
+	}
+
+	return checks
+}
+
+// Validate runs the checks across the parsed files. Required entities of the
+// model that are missing and transmitted entities without steps are errors.
+// The structural checks of the step graph are warnings.
+func (p *Parser) Validate(ignores []string, graph bool) []*ValidationError {
+	var errs []*ValidationError
+
+	names := p.MissingEntities(true, ignores)
+	sort.Strings(names)
+
+	for _, n := range names {
+		e := newError("entity", MissingEntityCode, "Entity '%s' is missing", n)
+		e.File = EntitiesFile
+		errs = append(errs, e)
+	}
+
+	entities := sortedEntities(p.EntitiesWithoutSteps())
+
+	for _, x := range entities {
+		e := newError("", EntityWithoutStepsCode, "Entity '%s' does not have steps", x.Name)
+		e.File = StepsFile
+		errs = append(errs, e)
+	}
+
+	if !graph {
+		return errs
+	}
+
+	for _, c := range p.CheckGraph() {
+		for _, err := range c.Errors {
+			errs = append(errs, &ValidationError{
+				File:     StepsFile,
+				Severity: SeverityWarning,
+				Code:     c.Code,
+				Message:  fmt.Sprintf("%s: %s", err, c.Singular),
+			})
+		}
+	}
+
+	return errs
+}
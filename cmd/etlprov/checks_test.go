@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+)
+
+func checkErrors(checks []*GraphCheck, code string) []string {
+	for _, c := range checks {
+		if c.Code != code {
+			continue
+		}
+
+		var msgs []string
+
+		for _, err := range c.Errors {
+			msgs = append(msgs, err.Error())
+		}
+
+		return msgs
+	}
+
+	return nil
+}
+
+func TestCheckGraph(t *testing.T) {
+	p := NewParser(model)
+
+	person := &Entity{Name: "person"}
+	death := &Entity{Name: "death"}
+
+	p.entities[person.Name] = person
+	p.entities[death.Name] = death
+
+	// Chain 1 -> 2, chain 3 -> 4 and an orphan 5.
+	s1 := &Step{ID: 1, Entities: []*Entity{person}}
+	s2 := &Step{ID: 2, Entities: []*Entity{death}, PreviousStep: s1}
+	s3 := &Step{ID: 3, Entities: []*Entity{person}}
+	s4 := &Step{ID: 4, Entities: []*Entity{death}, PreviousStep: s3}
+	s5 := &Step{ID: 5, Entities: []*Entity{person, death}, All: true}
+
+	for _, s := range []*Step{s1, s2, s3, s4, s5} {
+		p.steps[s.ID] = s
+	}
+
+	p.sources["EHR"] = &Source{Name: "EHR", Steps: []*Step{s1}}
+	p.tools["SQL"] = &Tool{Name: "SQL", Steps: []*Step{s1, s2, s3, s4}}
+	p.people["Jane"] = &Person{Name: "Jane", Steps: []*Step{s1, s2, s3, s4, s5}}
+
+	checks := p.CheckGraph()
+
+	tests := map[string][]string{
+		OrphanStepCheck:        {"Step 5"},
+		UnreachableStepCheck:   {"Step 3", "Step 4", "Step 5"},
+		StepWithoutToolCheck:   {"Step 5"},
+		StepWithoutSourceCheck: {"Step 2", "Step 3", "Step 4", "Step 5"},
+		StepWithoutPersonCheck: nil,
+		DisjointEntityCheck: {
+			"death (chains starting at steps 1, 3)",
+			"person (chains starting at steps 1, 3)",
+		},
+	}
+
+	for code, exp := range tests {
+		act := checkErrors(checks, code)
+
+		if len(act) != len(exp) {
+			t.Errorf("%s: expected %v, got %v", code, exp, act)
+			continue
+		}
+
+		for i := range exp {
+			if act[i] != exp[i] {
+				t.Errorf("%s: expected %v, got %v", code, exp, act)
+				break
+			}
+		}
+	}
+}
+
+func TestParseEntityString(t *testing.T) {
+	p := NewParser(model)
+
+	for _, n := range []string{"person.person_id", "person.gender_concept_id", "care_site.care_site_id"} {
+		p.entities[n] = &Entity{Name: n}
+	}
+
+	entities, err := p.parseEntityString("person.person_id,care_site.care_site_id")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entities) != 2 {
+		t.Errorf("expected 2 entities, got %d", len(entities))
+	}
+
+	if appliesToAll("person.person_id,care_site.care_site_id") {
+		t.Error("expected list to not apply to all entities")
+	}
+
+	for _, s := range []string{"", "all", "ALL"} {
+		entities, err := p.parseEntityString(s)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entities) != 3 || !appliesToAll(s) {
+			t.Errorf("expected %q to apply to all entities", s)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Codes of the checks of the entities against the site's data.
+const (
+	AbsentFieldCode      = "absent-field"
+	EmptyFieldCode       = "empty-field"
+	UnavailableFieldCode = "unavailable-field-present"
+)
+
+// SiteData is the set of columns in the data transmitted by a site by table.
+// Each column is true if it has at least one value. If Values is false,
+// only the presence of the columns is known, e.g. from a schema dump.
+type SiteData struct {
+	Tables map[string]map[string]bool
+	Values bool
+}
+
+func newSiteData(values bool) *SiteData {
+	return &SiteData{
+		Tables: make(map[string]map[string]bool),
+		Values: values,
+	}
+}
+
+func (d *SiteData) add(table, column string) {
+	table = strings.ToLower(table)
+	column = strings.ToLower(column)
+
+	if _, ok := d.Tables[table]; !ok {
+		d.Tables[table] = make(map[string]bool)
+	}
+
+	if _, ok := d.Tables[table][column]; !ok {
+		d.Tables[table][column] = false
+	}
+}
+
+// readDataFile reads the header of a CSV data file and the rows until each
+// column has a value.
+func readDataFile(r io.Reader) (map[string]bool, error) {
+	cr := csv.NewReader(&ureader{r: r})
+
+	cr.LazyQuotes = true
+	cr.FieldsPerRecord = -1
+
+	head, err := cr.Read()
+
+	if err != nil {
+		return nil, err
+	}
+
+	trimSpace(head)
+
+	columns := make(map[string]bool, len(head))
+
+	for _, h := range head {
+		columns[strings.ToLower(h)] = false
+	}
+
+	empty := len(columns)
+
+	for empty > 0 {
+		row, err := cr.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for i, v := range row {
+			if i >= len(head) || strings.TrimSpace(v) == "" {
+				continue
+			}
+
+			h := strings.ToLower(head[i])
+
+			if !columns[h] {
+				columns[h] = true
+				empty--
+			}
+		}
+	}
+
+	return columns, nil
+}
+
+// ReadDataDir reads the CSV data files in a directory. Each file is named
+// after the table, e.g. person.csv.
+func ReadDataDir(dir string) (*SiteData, error) {
+	infos, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d := newSiteData(true)
+
+	for _, fi := range infos {
+		if fi.IsDir() || strings.ToLower(filepath.Ext(fi.Name())) != ".csv" {
+			continue
+		}
+
+		name := filepath.Join(dir, fi.Name())
+		f, err := os.Open(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		columns, err := readDataFile(f)
+		f.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("Error reading '%s': %s", name, err)
+		}
+
+		table := strings.ToLower(strings.TrimSuffix(fi.Name(), filepath.Ext(fi.Name())))
+		d.Tables[table] = columns
+	}
+
+	return d, nil
+}
+
+// Regexes for the statements of a SQL schema dump.
+var (
+	createTableStmt = regexp.MustCompile(`(?i)^\s*create\s+(?:unlogged\s+)?table\s+(?:if\s+not\s+exists\s+)?([\w."]+)\s*\(`)
+	tableConstraint = regexp.MustCompile(`(?i)^(constraint|primary|foreign|unique|check|exclude|like)\b`)
+)
+
+// unquoteIdent returns the unqualified name of a possibly quoted identifier.
+func unquoteIdent(s string) string {
+	toks := strings.Split(s, ".")
+	return strings.Trim(toks[len(toks)-1], `"`)
+}
+
+// readSQLSchema reads the columns of the CREATE TABLE statements of a SQL
+// schema dump, such as the output of pg_dump --schema-only. Each column is
+// expected on its own line.
+func readSQLSchema(r io.Reader) (*SiteData, error) {
+	d := newSiteData(false)
+
+	var table string
+
+	sc := bufio.NewScanner(r)
+
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+
+		if table == "" {
+			if m := createTableStmt.FindStringSubmatch(line); m != nil {
+				table = unquoteIdent(m[1])
+				d.Tables[strings.ToLower(table)] = make(map[string]bool)
+			}
+
+			continue
+		}
+
+		if strings.HasPrefix(line, ")") {
+			table = ""
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "--") || tableConstraint.MatchString(line) {
+			continue
+		}
+
+		d.add(table, unquoteIdent(strings.Fields(line)[0]))
+	}
+
+	return d, sc.Err()
+}
+
+// readCSVSchema reads a CSV export of information_schema.columns. The
+// header must have the table_name and column_name columns.
+func readCSVSchema(r io.Reader) (*SiteData, error) {
+	d := newSiteData(false)
+
+	cr := csv.NewReader(&ureader{r: r})
+	cr.FieldsPerRecord = -1
+
+	head, err := cr.Read()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ti, ci := -1, -1
+
+	for i, h := range trimSpace(head) {
+		switch strings.ToLower(h) {
+		case "table_name":
+			ti = i
+		case "column_name":
+			ci = i
+		}
+	}
+
+	if ti < 0 || ci < 0 {
+		return nil, fmt.Errorf("Schema requires the 'table_name' and 'column_name' columns")
+	}
+
+	for {
+		row, err := cr.Read()
+
+		if err == io.EOF {
+			return d, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ti >= len(row) || ci >= len(row) {
+			continue
+		}
+
+		d.add(strings.TrimSpace(row[ti]), strings.TrimSpace(row[ci]))
+	}
+}
+
+// ReadSchema reads the tables and columns of a database schema dump. Files
+// ending in .sql are read as a SQL dump and others as a CSV export of
+// information_schema.columns.
+func ReadSchema(name string) (*SiteData, error) {
+	f, err := os.Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(name)) == ".sql" {
+		return readSQLSchema(f)
+	}
+
+	return readCSVSchema(f)
+}
+
+// CheckData checks the availability of the fields declared in the entities
+// against the site's data. Fields declared available and transmitting that
+// are absent or have no values are errors. Fields that are present, or have
+// values if known, but are declared unavailable are warnings. A table that
+// is not in the data is reported once if any of its entities is declared
+// available and transmitting.
+func (p *Parser) CheckData(d *SiteData) []*ValidationError {
+	var errs []*ValidationError
+
+	add := func(severity, code, format string, args ...interface{}) {
+		e := newError("availability", code, format, args...)
+		e.File = EntitiesFile
+		e.Severity = severity
+		errs = append(errs, e)
+	}
+
+	absent := make(map[string]struct{})
+
+	for _, e := range p.Entities() {
+		toks := strings.SplitN(strings.ToLower(e.Name), EntityDelim, 2)
+		declared := e.Availability == "available" && e.Transmitting
+
+		columns, ok := d.Tables[toks[0]]
+
+		if !ok {
+			if _, seen := absent[toks[0]]; !seen && declared {
+				absent[toks[0]] = struct{}{}
+				add(SeverityError, AbsentFieldCode, "Table '%s' is declared available but is absent from the data", toks[0])
+			}
+
+			continue
+		}
+
+		// Tables are only checked for presence.
+		if len(toks) == 1 {
+			continue
+		}
+
+		values, present := columns[toks[1]]
+
+		switch {
+		case declared && !present:
+			add(SeverityError, AbsentFieldCode, "Field '%s' is declared available but is absent from the data", e.Name)
+
+		case declared && d.Values && !values:
+			add(SeverityError, EmptyFieldCode, "Field '%s' is declared available but has no values in the data", e.Name)
+
+		case e.Availability == "unavailable" && present && (!d.Values || values):
+			add(SeverityWarning, UnavailableFieldCode, "Field '%s' is declared unavailable but is present in the data", e.Name)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadSQLSchema(t *testing.T) {
+	dump := `
+CREATE TABLE public.person (
+    person_id integer NOT NULL,
+    "gender_concept_id" integer,
+    CONSTRAINT person_pkey PRIMARY KEY (person_id)
+);
+
+CREATE TABLE IF NOT EXISTS death (
+    person_id integer
+);
+`
+
+	d, err := readSQLSchema(strings.NewReader(dump))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Tables) != 2 {
+		t.Fatalf("expected 2 tables, got %d", len(d.Tables))
+	}
+
+	if len(d.Tables["person"]) != 2 {
+		t.Errorf("expected 2 columns for person, got %v", d.Tables["person"])
+	}
+
+	if _, ok := d.Tables["person"]["gender_concept_id"]; !ok {
+		t.Error("expected quoted column to be read")
+	}
+}
+
+func TestCheckData(t *testing.T) {
+	p := NewParser(model)
+
+	p.entities["person"] = &Entity{Name: "person", Availability: "available", Transmitting: true}
+	p.entities["person.person_id"] = &Entity{Name: "person.person_id", Availability: "available", Transmitting: true}
+	p.entities["person.gender_concept_id"] = &Entity{Name: "person.gender_concept_id", Availability: "available", Transmitting: true}
+	p.entities["person.year_of_birth"] = &Entity{Name: "person.year_of_birth", Availability: "available", Transmitting: true}
+	p.entities["person.race_concept_id"] = &Entity{Name: "person.race_concept_id", Availability: "unavailable"}
+	p.entities["death.person_id"] = &Entity{Name: "death.person_id", Availability: "available", Transmitting: true}
+
+	data := "person_id,gender_concept_id,race_concept_id\n1,,8527\n2,,\n"
+
+	columns, err := readDataFile(strings.NewReader(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newSiteData(true)
+	d.Tables["person"] = columns
+
+	codes := make(map[string]string)
+
+	for _, e := range p.CheckData(d) {
+		codes[e.Message] = e.Code
+	}
+
+	expected := map[string]string{
+		"Field 'person.gender_concept_id' is declared available but has no values in the data": EmptyFieldCode,
+		"Field 'person.year_of_birth' is declared available but is absent from the data":       AbsentFieldCode,
+		"Field 'person.race_concept_id' is declared unavailable but is present in the data":    UnavailableFieldCode,
+		"Table 'death' is declared available but is absent from the data":                      AbsentFieldCode,
+	}
+
+	if len(codes) != len(expected) {
+		t.Errorf("expected %d errors, got %v", len(expected), codes)
+	}
+
+	for m, c := range expected {
+		if codes[m] != c {
+			t.Errorf("expected %s for %q", c, m)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of changes between two submissions.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// FieldChange is a change to an attribute of an item. Attributes that are
+// sets, such as the entities of a step, list the added and removed members
+// instead of the old and new value.
+type FieldChange struct {
+	Field   string   `json:"field"`
+	Old     string   `json:"old,omitempty"`
+	New     string   `json:"new,omitempty"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Change is an added, removed or changed entity, step, tool, source or person.
+type Change struct {
+	Kind   string         `json:"kind"`
+	Type   string         `json:"type"`
+	Name   string         `json:"name"`
+	Fields []*FieldChange `json:"fields,omitempty"`
+}
+
+// Diff is the set of changes between two submissions.
+type Diff struct {
+	Changes []*Change `json:"changes"`
+}
+
+// differ accumulates the changes of an item.
+type differ struct {
+	fields []*FieldChange
+}
+
+func (d *differ) value(field, from, to string) {
+	if from != to {
+		d.fields = append(d.fields, &FieldChange{
+			Field: field,
+			Old:   from,
+			New:   to,
+		})
+	}
+}
+
+func (d *differ) set(field string, from, to []string) {
+	om := make(map[string]struct{}, len(from))
+	nm := make(map[string]struct{}, len(to))
+
+	for _, x := range from {
+		om[x] = struct{}{}
+	}
+
+	for _, x := range to {
+		nm[x] = struct{}{}
+	}
+
+	c := &FieldChange{Field: field}
+
+	for _, x := range to {
+		if _, ok := om[x]; !ok {
+			c.Added = append(c.Added, x)
+		}
+	}
+
+	for _, x := range from {
+		if _, ok := nm[x]; !ok {
+			c.Removed = append(c.Removed, x)
+		}
+	}
+
+	if len(c.Added) > 0 || len(c.Removed) > 0 {
+		sort.Strings(c.Added)
+		sort.Strings(c.Removed)
+		d.fields = append(d.fields, c)
+	}
+}
+
+// stepEntities returns the names of the entities of the step. Steps that
+// apply to all entities are represented by "all" so a change in the set of
+// entities in the model is not reported for the step.
+func stepEntities(s *Step) []string {
+	if s.All {
+		return []string{"all"}
+	}
+
+	names := make([]string, len(s.Entities))
+
+	for i, e := range s.Entities {
+		names[i] = e.Name
+	}
+
+	return names
+}
+
+func stepIDs(steps []*Step) []string {
+	ids := make([]string, len(steps))
+
+	for i, s := range sortedSteps(steps) {
+		ids[i] = strconv.Itoa(s.ID)
+	}
+
+	return ids
+}
+
+func previousStep(s *Step) string {
+	if s.PreviousStep == nil {
+		return ""
+	}
+
+	return strconv.Itoa(s.PreviousStep.ID)
+}
+
+// add appends a change for the item. Items are keyed by name with the
+// diff function called for items present in both submissions.
+func (d *Diff) add(typ string, from, to []string, diff func(name string, d *differ)) {
+	om := make(map[string]struct{}, len(from))
+	nm := make(map[string]struct{}, len(to))
+
+	for _, x := range from {
+		om[x] = struct{}{}
+	}
+
+	for _, x := range to {
+		nm[x] = struct{}{}
+	}
+
+	for _, name := range from {
+		if _, ok := nm[name]; !ok {
+			d.Changes = append(d.Changes, &Change{Kind: Removed, Type: typ, Name: name})
+			continue
+		}
+
+		var df differ
+		diff(name, &df)
+
+		if len(df.fields) > 0 {
+			d.Changes = append(d.Changes, &Change{Kind: Changed, Type: typ, Name: name, Fields: df.fields})
+		}
+	}
+
+	for _, name := range to {
+		if _, ok := om[name]; !ok {
+			d.Changes = append(d.Changes, &Change{Kind: Added, Type: typ, Name: name})
+		}
+	}
+}
+
+// DiffParsers compares the provenance parsed by two parsers. Entities, tools,
+// sources and people are matched by name and steps by ID.
+func DiffParsers(from, to *Parser) *Diff {
+	d := &Diff{
+		Changes: []*Change{},
+	}
+
+	var on, nn []string
+
+	for _, e := range from.Entities() {
+		on = append(on, e.Name)
+	}
+
+	for _, e := range to.Entities() {
+		nn = append(nn, e.Name)
+	}
+
+	d.add(EntityNode, on, nn, func(name string, df *differ) {
+		o, n := from.entities[name], to.entities[name]
+
+		df.value("availability", o.Availability, n.Availability)
+		df.value("transmitting", yesNo(o.Transmitting), yesNo(n.Transmitting))
+		df.value("comment", o.Comment, n.Comment)
+	})
+
+	on, nn = stepIDs(from.Steps()), stepIDs(to.Steps())
+
+	d.add(StepNode, on, nn, func(name string, df *differ) {
+		id, _ := strconv.Atoi(name)
+		o, n := from.steps[id], to.steps[id]
+
+		df.value("description", o.Description, n.Description)
+		df.set("entities", stepEntities(o), stepEntities(n))
+		df.value("previous_step", previousStep(o), previousStep(n))
+	})
+
+	on, nn = nil, nil
+
+	for _, t := range from.Tools() {
+		on = append(on, t.Name)
+	}
+
+	for _, t := range to.Tools() {
+		nn = append(nn, t.Name)
+	}
+
+	d.add(ToolNode, on, nn, func(name string, df *differ) {
+		df.value("version", from.tools[name].Version, to.tools[name].Version)
+	})
+
+	on, nn = nil, nil
+
+	for _, s := range from.Sources() {
+		on = append(on, s.Name)
+	}
+
+	for _, s := range to.Sources() {
+		nn = append(nn, s.Name)
+	}
+
+	d.add(SourceNode, on, nn, func(name string, df *differ) {
+		df.value("version", from.sources[name].Version, to.sources[name].Version)
+	})
+
+	on, nn = nil, nil
+
+	for _, x := range from.People() {
+		on = append(on, x.Name)
+	}
+
+	for _, x := range to.People() {
+		nn = append(nn, x.Name)
+	}
+
+	d.add(PersonNode, on, nn, func(name string, df *differ) {
+		o, n := from.people[name], to.people[name]
+
+		df.value("email", o.Email, n.Email)
+		df.value("role", o.Role, n.Role)
+		df.set("steps", stepIDs(o.Steps), stepIDs(n.Steps))
+	})
+
+	return d
+}
+
+// WriteJSON writes the changes as JSON.
+func (d *Diff) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// Markers of the kinds of changes in text.
+var diffMarkers = map[string]string{
+	Added:   "+",
+	Removed: "-",
+	Changed: "~",
+}
+
+// WriteText writes one line per added or removed item and an indented line
+// for each changed attribute.
+func (d *Diff) WriteText(w io.Writer) error {
+	if len(d.Changes) == 0 {
+		_, err := fmt.Fprintln(w, "No changes")
+		return err
+	}
+
+	for _, c := range d.Changes {
+		fmt.Fprintf(w, "%s %s %s\n", diffMarkers[c.Kind], c.Type, c.Name)
+
+		for _, f := range c.Fields {
+			if f.Added != nil || f.Removed != nil {
+				if len(f.Added) > 0 {
+					fmt.Fprintf(w, "    %s: + %s\n", f.Field, strings.Join(f.Added, ", "))
+				}
+
+				if len(f.Removed) > 0 {
+					fmt.Fprintf(w, "    %s: - %s\n", f.Field, strings.Join(f.Removed, ", "))
+				}
+
+				continue
+			}
+
+			fmt.Fprintf(w, "    %s: %q -> %q\n", f.Field, f.Old, f.New)
+		}
+	}
+
+	return nil
+}
+
+// diffWriters are the output formats of the diff.
+var diffWriters = map[string]func(d *Diff, w io.Writer) error{
+	"text": (*Diff).WriteText,
+	"json": (*Diff).WriteJSON,
+}
+
+func runDiff(args []string) {
+	var (
+		opts   modelOptions
+		format string
+	)
+
+	fs := flag.NewFlagSet("pedsnet-etlprov diff", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: pedsnet-etlprov diff [options] <old> <new>\n\n")
+		fmt.Fprintf(os.Stderr, "Compares the provenance in <old> with <new>.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+
+	opts.register(fs)
+	fs.StringVar(&format, "format", "text", "Output format: text or json.")
+
+	fs.Parse(args)
+
+	write, ok := diffWriters[format]
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown format '%s'\n", format)
+		os.Exit(1)
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	oldDir, newDir := fs.Arg(0), fs.Arg(1)
+
+	if err := checkPath(oldDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// The model is fetched once and shared by both parsers.
+	from := newParser(&opts, newDir)
+	to := NewParser(from.Model)
+
+	// Errors are printed, but the diff is computed with what was parsed.
+	readPath(from, oldDir, 10)
+	readPath(to, newDir, 10)
+
+	if err := write(DiffParsers(from, to), os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Problem writing diff\n> %s\n", err)
+		os.Exit(1)
+	}
+}
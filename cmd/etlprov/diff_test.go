@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func findChange(d *Diff, kind, typ, name string) *Change {
+	for _, c := range d.Changes {
+		if c.Kind == kind && c.Type == typ && c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func TestDiffParsers(t *testing.T) {
+	from := parseTestData(t)
+	to := parseTestData(t)
+
+	if d := DiffParsers(from, to); len(d.Changes) != 0 {
+		t.Fatalf("expected no changes, got %d", len(d.Changes))
+	}
+
+	to.entities["person.person_id"].Availability = "unknown"
+	to.entities["person.person_id"].Transmitting = false
+	delete(to.steps, 2)
+	to.steps[1].Entities = to.steps[1].Entities[1:]
+	to.steps[1000] = &Step{ID: 1000, Description: "New step", PreviousStep: to.steps[1]}
+
+	for _, x := range to.Tools() {
+		x.Version = "99"
+		break
+	}
+
+	d := DiffParsers(from, to)
+
+	c := findChange(d, Changed, EntityNode, "person.person_id")
+
+	if c == nil {
+		t.Fatal("expected entity change")
+	}
+
+	if len(c.Fields) != 2 || c.Fields[0].Field != "availability" || c.Fields[1].Field != "transmitting" {
+		t.Errorf("unexpected entity fields %+v", c.Fields)
+	}
+
+	if findChange(d, Removed, StepNode, "2") == nil {
+		t.Error("expected step 2 to be removed")
+	}
+
+	if findChange(d, Added, StepNode, "1000") == nil {
+		t.Error("expected step 1000 to be added")
+	}
+
+	if c := findChange(d, Changed, StepNode, "1"); c == nil || len(c.Fields[0].Removed) != 1 {
+		t.Error("expected an entity to be removed from step 1")
+	}
+
+	if findChange(d, Changed, ToolNode, to.Tools()[0].Name) == nil {
+		t.Error("expected tool version change")
+	}
+
+	var buf bytes.Buffer
+
+	if err := d.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "~ entity person.person_id\n    availability: \"available\" -> \"unknown\"") {
+		t.Errorf("unexpected text output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+
+	if err := d.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var out Diff
+
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out.Changes) != len(d.Changes) {
+		t.Errorf("expected %d changes, got %d", len(d.Changes), len(out.Changes))
+	}
+}
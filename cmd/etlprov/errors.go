@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+)
+
+// Severities of validation errors. Only errors fail the validation.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Codes of the validation errors found while parsing the files.
+const (
+	UnreadableFileCode     = "unreadable-file"
+	MissingFileCode        = "missing-file"
+	RequiredValueCode      = "required-value"
+	InvalidValueCode       = "invalid-value"
+	DuplicateCode          = "duplicate"
+	UnknownEntityCode      = "unknown-entity"
+	UndefinedEntityCode    = "undefined-entity"
+	UndefinedStepCode      = "undefined-step"
+	MissingEntityCode      = "missing-entity"
+	EntityWithoutStepsCode = "entity-without-steps"
+)
+
+// ValidationError is an error or warning found in a provenance file. Line
+// is the line of the record in the file and Column is the name of the
+// column in the header. Both are zero if not specific to a record or column.
+type ValidationError struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   string `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	var loc []string
+
+	if e.Line > 0 {
+		loc = append(loc, fmt.Sprintf("line %d", e.Line))
+	}
+
+	if e.Column != "" {
+		loc = append(loc, fmt.Sprintf("column '%s'", e.Column))
+	}
+
+	if len(loc) == 0 {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s (%s)", e.Message, strings.Join(loc, ", "))
+}
+
+// newError returns an error for the column of a record.
+func newError(column, code, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{
+		Column:   column,
+		Severity: SeverityError,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// withColumn sets the column of the error if it is not already set.
+func withColumn(err error, column string) error {
+	if err == nil {
+		return nil
+	}
+
+	e := asValidationError(err)
+
+	if e.Column == "" {
+		e.Column = column
+	}
+
+	return e
+}
+
+// locate sets the file and line of the error. The line is kept if zero.
+func locate(err error, file string, line int) *ValidationError {
+	e := asValidationError(err)
+
+	e.File = file
+
+	if line > 0 {
+		e.Line = line
+	}
+
+	return e
+}
+
+// asValidationError returns the error as a validation error. Errors that
+// are not validation errors are treated as invalid values, except for CSV
+// parse errors which make the file unreadable.
+func asValidationError(err error) *ValidationError {
+	switch x := err.(type) {
+	case *ValidationError:
+		return x
+
+	case *csv.ParseError:
+		return &ValidationError{
+			Line:     x.Line,
+			Severity: SeverityError,
+			Code:     UnreadableFileCode,
+			Message:  x.Err.Error(),
+		}
+	}
+
+	return &ValidationError{
+		Severity: SeverityError,
+		Code:     InvalidValueCode,
+		Message:  err.Error(),
+	}
+}
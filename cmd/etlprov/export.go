@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// graphWriters are the export formats.
+var graphWriters = map[string]func(g *Graph, w io.Writer) error{
+	"dot":     (*Graph).WriteDOT,
+	"graphml": (*Graph).WriteGraphML,
+	"json":    (*Graph).WriteJSON,
+}
+
+func runExport(args []string) {
+	var (
+		opts     modelOptions
+		format   string
+		output   string
+		entities bool
+	)
+
+	fs := flag.NewFlagSet("pedsnet-etlprov export", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: pedsnet-etlprov export [options] <dir|file>\n\n")
+		fmt.Fprintf(os.Stderr, "Exports the provenance in <dir|file> as a graph.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+
+	opts.register(fs)
+	fs.StringVar(&format, "format", "dot", "Output format: dot, graphml or json.")
+	fs.StringVar(&output, "o", "", "File to write the graph to. Defaults to stdout.")
+	fs.BoolVar(&entities, "entities", true, "Include the entities produced by the steps.")
+
+	fs.Parse(args)
+
+	write, ok := graphWriters[format]
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown format '%s'\n", format)
+		os.Exit(1)
+	}
+
+	dir := "."
+
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	p := newParser(&opts, dir)
+
+	// Errors are printed, but the graph is exported with what was parsed.
+	readPath(p, dir, 10)
+
+	g := BuildGraph(p, entities)
+
+	var w io.Writer = os.Stdout
+
+	if output != "" {
+		f, err := os.Create(output)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		defer f.Close()
+		w = f
+	}
+
+	if err := write(g, w); err != nil {
+		fmt.Fprintf(os.Stderr, "Problem writing graph\n> %s\n", err)
+		os.Exit(1)
+	}
+}
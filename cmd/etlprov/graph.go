@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of nodes in the provenance graph.
+const (
+	StepNode   = "step"
+	EntityNode = "entity"
+	ToolNode   = "tool"
+	SourceNode = "source"
+	PersonNode = "person"
+)
+
+// Kinds of edges in the provenance graph. Edges follow the flow of data
+// from sources through the steps to the entities.
+const (
+	// Previous step to step.
+	PrecedesEdge = "precedes"
+
+	// Step to the entity it produces.
+	ProducesEdge = "produces"
+
+	// Source to the step it is used by.
+	FeedsEdge = "feeds"
+
+	// Tool to the step it is used by.
+	RunsEdge = "runs"
+
+	// Person to the step they are attributed to.
+	PerformsEdge = "performs"
+)
+
+// Node is a step, entity, tool, source or person.
+type Node struct {
+	ID    string            `json:"id"`
+	Kind  string            `json:"kind"`
+	Label string            `json:"label"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+// Edge is a directed edge between two nodes.
+type Edge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   string `json:"kind"`
+}
+
+// Graph is the provenance of an ETL as a directed graph.
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+}
+
+func nodeID(kind, name string) string {
+	return fmt.Sprintf("%s:%s", kind, name)
+}
+
+func stepID(s *Step) string {
+	return nodeID(StepNode, strconv.Itoa(s.ID))
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
+
+// sortedEntities returns a sorted copy of the entities.
+func sortedEntities(entities []*Entity) []*Entity {
+	a := make([]*Entity, len(entities))
+	copy(a, entities)
+
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].Name < a[j].Name
+	})
+
+	return a
+}
+
+// sortedSteps returns a copy of the steps sorted by ID.
+func sortedSteps(steps []*Step) []*Step {
+	a := make([]*Step, len(steps))
+	copy(a, steps)
+
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].ID < a[j].ID
+	})
+
+	return a
+}
+
+// BuildGraph builds the graph of the parsed provenance. If entities is false,
+// entity nodes and the edges to them are omitted.
+func BuildGraph(p *Parser, entities bool) *Graph {
+	g := &Graph{
+		Nodes: []*Node{},
+		Edges: []*Edge{},
+	}
+
+	for _, s := range p.Steps() {
+		attrs := map[string]string{
+			"description": s.Description,
+		}
+
+		if s.Time != "" {
+			attrs["time"] = s.Time
+		}
+
+		g.Nodes = append(g.Nodes, &Node{
+			ID:    stepID(s),
+			Kind:  StepNode,
+			Label: fmt.Sprintf("Step %d", s.ID),
+			Attrs: attrs,
+		})
+
+		if s.PreviousStep != nil {
+			g.Edges = append(g.Edges, &Edge{
+				Source: stepID(s.PreviousStep),
+				Target: stepID(s),
+				Kind:   PrecedesEdge,
+			})
+		}
+
+		if !entities {
+			continue
+		}
+
+		for _, e := range sortedEntities(s.Entities) {
+			g.Edges = append(g.Edges, &Edge{
+				Source: stepID(s),
+				Target: nodeID(EntityNode, e.Name),
+				Kind:   ProducesEdge,
+			})
+		}
+	}
+
+	if entities {
+		for _, e := range p.Entities() {
+			attrs := map[string]string{
+				"availability": e.Availability,
+				"transmitting": yesNo(e.Transmitting),
+			}
+
+			if e.Comment != "" {
+				attrs["comment"] = e.Comment
+			}
+
+			g.Nodes = append(g.Nodes, &Node{
+				ID:    nodeID(EntityNode, e.Name),
+				Kind:  EntityNode,
+				Label: e.Name,
+				Attrs: attrs,
+			})
+		}
+	}
+
+	for _, t := range p.Tools() {
+		g.add(ToolNode, t.Name, RunsEdge, t.Steps, map[string]string{
+			"usage":   t.Usage,
+			"version": t.Version,
+		})
+	}
+
+	for _, s := range p.Sources() {
+		g.add(SourceNode, s.Name, FeedsEdge, s.Steps, map[string]string{
+			"usage":   s.Usage,
+			"version": s.Version,
+		})
+	}
+
+	for _, x := range p.People() {
+		g.add(PersonNode, x.Name, PerformsEdge, x.Steps, map[string]string{
+			"email": x.Email,
+			"role":  x.Role,
+		})
+	}
+
+	return g
+}
+
+// add adds a node attached to the steps.
+func (g *Graph) add(kind, name, edge string, steps []*Step, attrs map[string]string) {
+	for k, v := range attrs {
+		if v == "" {
+			delete(attrs, k)
+		}
+	}
+
+	id := nodeID(kind, name)
+
+	g.Nodes = append(g.Nodes, &Node{
+		ID:    id,
+		Kind:  kind,
+		Label: name,
+		Attrs: attrs,
+	})
+
+	for _, s := range sortedSteps(steps) {
+		g.Edges = append(g.Edges, &Edge{
+			Source: id,
+			Target: stepID(s),
+			Kind:   edge,
+		})
+	}
+}
+
+// WriteJSON writes the graph as JSON.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// Shapes of the nodes in DOT.
+var dotShapes = map[string]string{
+	StepNode:   "box",
+	EntityNode: "ellipse",
+	ToolNode:   "component",
+	SourceNode: "cylinder",
+	PersonNode: "house",
+}
+
+// dotQuote quotes a string for DOT.
+func dotQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	return `"` + s + `"`
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	fmt.Fprintln(w, "digraph provenance {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+
+	for _, n := range g.Nodes {
+		attrs := []string{
+			fmt.Sprintf("label=%s", dotQuote(n.Label)),
+			fmt.Sprintf("shape=%s", dotShapes[n.Kind]),
+		}
+
+		if d, ok := n.Attrs["description"]; ok {
+			attrs = append(attrs, fmt.Sprintf("tooltip=%s", dotQuote(d)))
+		}
+
+		fmt.Fprintf(w, "  %s [%s];\n", dotQuote(n.ID), strings.Join(attrs, ", "))
+	}
+
+	for _, e := range g.Edges {
+		style := "solid"
+
+		if e.Kind != PrecedesEdge && e.Kind != ProducesEdge {
+			style = "dashed"
+		}
+
+		fmt.Fprintf(w, "  %s -> %s [label=%s, style=%s];\n", dotQuote(e.Source), dotQuote(e.Target), dotQuote(e.Kind), style)
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+type graphmlKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphmlNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphmlData `xml:"data"`
+}
+
+type graphmlGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphmlNode `xml:"node"`
+	Edges       []graphmlEdge `xml:"edge"`
+}
+
+type graphmlDoc struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphmlKey `xml:"key"`
+	Graph   graphmlGraph `xml:"graph"`
+}
+
+// WriteGraphML writes the graph as GraphML. The kind, label and attributes
+// of the nodes are declared as keys.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	names := map[string]struct{}{}
+
+	for _, n := range g.Nodes {
+		for k := range n.Attrs {
+			names[k] = struct{}{}
+		}
+	}
+
+	attrs := []string{}
+
+	for k := range names {
+		attrs = append(attrs, k)
+	}
+
+	sort.Strings(attrs)
+
+	doc := graphmlDoc{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphmlKey{
+			{ID: "kind", For: "node", Name: "kind", Type: "string"},
+			{ID: "label", For: "node", Name: "label", Type: "string"},
+			{ID: "edge_kind", For: "edge", Name: "kind", Type: "string"},
+		},
+		Graph: graphmlGraph{
+			ID:          "provenance",
+			EdgeDefault: "directed",
+		},
+	}
+
+	for _, k := range attrs {
+		doc.Keys = append(doc.Keys, graphmlKey{ID: k, For: "node", Name: k, Type: "string"})
+	}
+
+	for _, n := range g.Nodes {
+		gn := graphmlNode{
+			ID: n.ID,
+			Data: []graphmlData{
+				{Key: "kind", Value: n.Kind},
+				{Key: "label", Value: n.Label},
+			},
+		}
+
+		for _, k := range attrs {
+			if v, ok := n.Attrs[k]; ok {
+				gn.Data = append(gn.Data, graphmlData{Key: k, Value: v})
+			}
+		}
+
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gn)
+	}
+
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphmlEdge{
+			Source: e.Source,
+			Target: e.Target,
+			Data: []graphmlData{
+				{Key: "edge_kind", Value: e.Kind},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
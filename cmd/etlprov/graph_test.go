@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func parseTestData(t *testing.T) *Parser {
+	p := NewParser(model)
+
+	m := make(errorMap)
+
+	if err := p.ReadDir("./test_data", m.Handle); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func hasEdge(g *Graph, source, target, kind string) bool {
+	for _, e := range g.Edges {
+		if e.Source == source && e.Target == target && e.Kind == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestBuildGraph(t *testing.T) {
+	p := parseTestData(t)
+
+	g := BuildGraph(p, true)
+
+	n := len(p.Steps()) + len(p.Entities()) + len(p.Tools()) + len(p.Sources()) + len(p.People())
+
+	if len(g.Nodes) != n {
+		t.Errorf("expected %d nodes, got %d", n, len(g.Nodes))
+	}
+
+	if !hasEdge(g, "step:1", "step:2", PrecedesEdge) {
+		t.Error("expected step 1 to precede step 2")
+	}
+
+	if !hasEdge(g, "step:1", "entity:person", ProducesEdge) {
+		t.Error("expected step 1 to produce person")
+	}
+
+	if !hasEdge(g, "source:EPIC/Clarity", "step:1", FeedsEdge) {
+		t.Error("expected EPIC/Clarity to feed step 1")
+	}
+
+	g = BuildGraph(p, false)
+
+	for _, n := range g.Nodes {
+		if n.Kind == EntityNode {
+			t.Fatal("expected no entity nodes")
+		}
+	}
+}
+
+func TestGraphWriters(t *testing.T) {
+	g := BuildGraph(parseTestData(t), true)
+
+	var buf bytes.Buffer
+
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `"step:1" -> "step:2" [label="precedes", style=solid];`) {
+		t.Error("expected precedes edge in DOT output")
+	}
+
+	buf.Reset()
+
+	if err := g.WriteGraphML(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc graphmlDoc
+
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Graph.Nodes) != len(g.Nodes) || len(doc.Graph.Edges) != len(g.Edges) {
+		t.Errorf("expected %d nodes and %d edges in GraphML", len(g.Nodes), len(g.Edges))
+	}
+
+	buf.Reset()
+
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var out Graph
+
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out.Nodes) != len(g.Nodes) || len(out.Edges) != len(g.Edges) {
+		t.Errorf("expected %d nodes and %d edges in JSON", len(g.Nodes), len(g.Edges))
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	dms "github.com/chop-dbhi/data-models-service/client"
+)
+
+// TemplateEntities returns the rows of the entities file with a row per
+// table and field of the model, excluding the ignored tables and fields. If
+// prev is not nil, the availability and comment of the entities parsed from
+// an older provenance are carried over for the entities that still exist.
+func TemplateEntities(dm *dms.Model, ignores []string, prev *Parser) [][]string {
+	igidx := make(map[string]struct{}, len(ignores))
+
+	for _, name := range ignores {
+		igidx[name] = struct{}{}
+	}
+
+	var rows [][]string
+
+	add := func(name string) {
+		if _, ok := igidx[name]; ok {
+			return
+		}
+
+		row := make([]string, len(entityColumns))
+		row[0] = name
+
+		if prev != nil {
+			if e, ok := prev.entities[name]; ok {
+				row[1] = e.Availability
+				row[3] = e.Comment
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	for _, t := range dm.Tables.List() {
+		if _, ok := igidx[t.Name]; ok {
+			continue
+		}
+
+		add(t.Name)
+
+		for _, f := range t.Fields.List() {
+			add(fmt.Sprintf("%s%s%s", t.Name, EntityDelim, f.Name))
+		}
+	}
+
+	return rows
+}
+
+// writeCSV writes the header and rows to a new file. Existing files are
+// only overwritten if force is true.
+func writeCSV(name string, header []string, rows [][]string, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+
+	if !force {
+		flags |= os.O_EXCL
+	}
+
+	f, err := os.OpenFile(name, flags, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	w.Write(header)
+	w.WriteAll(rows)
+
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// WriteTemplate writes the provenance files to the directory with the
+// entities rows and only the header for the other files. Unless forced,
+// no file is written if any of them exists.
+func WriteTemplate(dir string, entities [][]string, force bool) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	if !force {
+		for _, pf := range provFiles {
+			name := filepath.Join(dir, pf.Name)
+
+			if _, err := os.Stat(name); err == nil {
+				return fmt.Errorf("File '%s' already exists", name)
+			} else if !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	for _, pf := range provFiles {
+		var rows [][]string
+
+		if pf.Name == EntitiesFile {
+			rows = entities
+		}
+
+		if err := writeCSV(filepath.Join(dir, pf.Name), pf.Columns, rows, force); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runInit(args []string) {
+	var (
+		opts   modelOptions
+		from   string
+		ignore string
+		force  bool
+	)
+
+	fs := flag.NewFlagSet("pedsnet-etlprov init", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: pedsnet-etlprov init [options] <dir>\n\n")
+		fmt.Fprintf(os.Stderr, "Writes a template of the provenance files for the model revision to <dir>.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+
+	opts.register(fs)
+	fs.StringVar(&from, "from", "", "Older provenance to carry over the availability and comments of the entities from.")
+	fs.StringVar(&ignore, "ignore", "", "Comma-separated list of entities to exclude.")
+	fs.BoolVar(&force, "force", false, "Overwrite existing files.")
+
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	dir := fs.Arg(0)
+
+	if from != "" {
+		if err := checkPath(from); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	dm, err := opts.load()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Problem fetching model data\n> %s\n", err)
+		os.Exit(1)
+	}
+
+	var prev *Parser
+
+	// Entities of the older provenance that are no longer in the model are
+	// rejected by the parser, so the errors are not printed.
+	if from != "" {
+		prev = NewParser(dm)
+
+		if err := prev.Read(from, func(string, []error) {}); err != nil {
+			fmt.Fprintf(os.Stderr, "Problem parsing provenance in '%s'\n> %s\n", from, err)
+			os.Exit(1)
+		}
+	}
+
+	entities := TemplateEntities(dm, ignoredEntities(ignore, dm), prev)
+
+	if err := WriteTemplate(dir, entities, force); err != nil {
+		fmt.Fprintf(os.Stderr, "Problem writing template\n> %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Wrote %d entities for model '%s/%s' to '%s'\n", len(entities), dm.Name, dm.Version, dir)
+}
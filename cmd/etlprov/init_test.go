@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateEntities(t *testing.T) {
+	prev := parseTestData(t)
+
+	// Availability of a field that is not in the model is not carried over.
+	prev.entities["person.old_field"] = &Entity{Name: "person.old_field", Availability: "available"}
+
+	rows := TemplateEntities(model, pedsnetIgnores, prev)
+
+	names := make(map[string][]string, len(rows))
+
+	for _, row := range rows {
+		names[row[0]] = row
+	}
+
+	if _, ok := names["concept"]; ok {
+		t.Error("expected vocabulary tables to be excluded")
+	}
+
+	if _, ok := names["person.old_field"]; ok {
+		t.Error("expected fields not in the model to be excluded")
+	}
+
+	e := prev.entities["care_site.care_site_source_value"]
+	row, ok := names[e.Name]
+
+	if !ok {
+		t.Fatalf("expected %s", e.Name)
+	}
+
+	if row[1] != e.Availability || row[3] != e.Comment {
+		t.Errorf("expected availability and comment to be carried over, got %v", row)
+	}
+}
+
+func TestWriteTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etlprov")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	rows := TemplateEntities(model, pedsnetIgnores, nil)
+
+	if err := WriteTemplate(dir, rows, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing files are not overwritten.
+	if err := WriteTemplate(dir, rows, false); err == nil {
+		t.Error("expected error for existing files")
+	}
+
+	// No file is written if any file exists.
+	other, err := ioutil.TempDir("", "etlprov")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(other)
+
+	if err := ioutil.WriteFile(filepath.Join(other, PeopleFile), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteTemplate(other, rows, false); err == nil {
+		t.Error("expected error for existing people file")
+	}
+
+	if _, err := os.Stat(filepath.Join(other, EntitiesFile)); !os.IsNotExist(err) {
+		t.Errorf("expected %s to not be written", EntitiesFile)
+	}
+
+	// The template is parsed without errors other than the blank availability.
+	p := NewParser(model)
+	m := make(errorMap)
+
+	if err := p.ReadDir(dir, m.Handle); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m[EntitiesFile]) != len(rows) {
+		t.Errorf("expected %d errors for the blank availability, got %d", len(rows), len(m[EntitiesFile]))
+	}
+
+	for _, name := range []string{StepsFile, ToolsFile, SourcesFile, PeopleFile} {
+		if len(m[name]) != 0 {
+			t.Errorf("expected no errors for %s, got %v", name, m[name])
+		}
+	}
+}
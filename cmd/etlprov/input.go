@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+	"gopkg.in/yaml.v2"
+)
+
+// Columns of the provenance files in order.
+var (
+	entityColumns = []string{"entity", "availability", "transmitting", "comment", "truncation", "limit"}
+	stepColumns   = []string{"step", "description", "entities", "previous step", "time"}
+	toolColumns   = []string{"name", "steps", "usage", "version"}
+	sourceColumns = []string{"name", "steps", "usage", "version"}
+	personColumns = []string{"name", "email", "role", "steps"}
+)
+
+// rowFunc calls the function with the line and values of each row of a
+// file, excluding the header.
+type rowFunc func(func(int, []string)) error
+
+// csvRows returns the rows of a CSV file.
+func csvRows(r io.Reader) rowFunc {
+	return func(f func(int, []string)) error {
+		return ReadRows(r, f)
+	}
+}
+
+// sliceRows returns the rows of a sheet. The first row is the header and
+// the line is the row number in the sheet.
+func sliceRows(rows [][]string) rowFunc {
+	return func(f func(int, []string)) error {
+		for i, row := range rows {
+			if i == 0 {
+				continue
+			}
+
+			trimSpace(row)
+
+			if isEmpty(row) {
+				continue
+			}
+
+			f(i+1, row)
+		}
+
+		return nil
+	}
+}
+
+// padRow extends the row with empty values up to n columns.
+func padRow(row []string, n int) []string {
+	for len(row) < n {
+		row = append(row, "")
+	}
+
+	return row
+}
+
+// provFile is one of the provenance files. The kind is the name of the sheet
+// in a workbook and the key in a JSON or YAML document.
+type provFile struct {
+	Name     string
+	Kind     string
+	Columns  []string
+	Required bool
+
+	read func(p *Parser, name string, rows rowFunc) []error
+}
+
+// provFiles are the provenance files in the order they must be read since
+// steps reference entities and the others reference steps.
+var provFiles = []*provFile{
+	{Name: EntitiesFile, Kind: "entities", Columns: entityColumns, Required: true, read: (*Parser).readEntities},
+	{Name: StepsFile, Kind: "steps", Columns: stepColumns, Required: true, read: (*Parser).readSteps},
+	{Name: ToolsFile, Kind: "tools", Columns: toolColumns, read: (*Parser).readTools},
+	{Name: SourcesFile, Kind: "sources", Columns: sourceColumns, read: (*Parser).readSources},
+	{Name: PeopleFile, Kind: "people", Columns: personColumns, read: (*Parser).readPeople},
+}
+
+// missingFile returns the warning for a missing optional file.
+func missingFile(name string) error {
+	return &ValidationError{
+		File:     name,
+		Severity: SeverityWarning,
+		Code:     MissingFileCode,
+		Message:  fmt.Sprintf("File '%s' is missing", name),
+	}
+}
+
+// Extensions of the files that can be read in place of a directory.
+var (
+	workbookExts = []string{".xlsx"}
+	documentExts = []string{".json", ".yaml", ".yml"}
+)
+
+func hasExt(name string, exts []string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+
+	for _, x := range exts {
+		if ext == x {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Read reads the provenance from a directory of CSV files, an XLSX workbook
+// or a JSON or YAML document depending on the path.
+func (p *Parser) Read(path string, handle ErrorHandler) error {
+	switch {
+	case hasExt(path, workbookExts):
+		return p.ReadWorkbook(path, handle)
+
+	case hasExt(path, documentExts):
+		f, err := os.Open(path)
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		return p.ReadDocument(f, strings.ToLower(filepath.Ext(path)) == ".json", handle)
+	}
+
+	return p.ReadDir(path, handle)
+}
+
+// sheetKind returns the kind of file for a sheet name, e.g. "Entities" or
+// "entities.csv".
+func sheetKind(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".csv")
+}
+
+// ReadWorkbook reads an XLSX workbook with a sheet per file named after the
+// file, e.g. "entities" or "entities.csv". The first row of each sheet is
+// the header.
+func (p *Parser) ReadWorkbook(name string, handle ErrorHandler) error {
+	wb, err := xlsx.OpenFile(name)
+
+	if err != nil {
+		return err
+	}
+
+	sheets, err := wb.ToSlice()
+
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int, len(wb.Sheets))
+
+	for i, s := range wb.Sheets {
+		index[sheetKind(s.Name)] = i
+	}
+
+	for _, pf := range provFiles {
+		i, ok := index[pf.Kind]
+
+		if !ok {
+			if pf.Required {
+				return fmt.Errorf("Sheet '%s' is missing", pf.Kind)
+			}
+
+			handle(pf.Kind, []error{missingFile(pf.Kind)})
+			continue
+		}
+
+		sheet := wb.Sheets[i].Name
+		handle(sheet, pf.read(p, sheet, sliceRows(sheets[i])))
+	}
+
+	return nil
+}
+
+// documentValue converts a value of a JSON or YAML document to the string
+// in the CSV files. Lists, such as the steps of a tool, are comma-separated.
+func documentValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+
+	case string:
+		return x
+
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+
+	case []interface{}:
+		toks := make([]string, len(x))
+
+		for i, y := range x {
+			toks[i] = documentValue(y)
+		}
+
+		return strings.Join(toks, TokenDelim)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// documentRows returns the rows of the list of objects keyed by column. The
+// line is the position of the object in the list.
+func documentRows(objs []map[string]interface{}, columns []string) rowFunc {
+	return func(f func(int, []string)) error {
+		for i, obj := range objs {
+			keys := make(map[string]interface{}, len(obj))
+
+			for k, v := range obj {
+				keys[strings.ToLower(k)] = v
+			}
+
+			row := make([]string, len(columns))
+
+			for j, c := range columns {
+				row[j] = documentValue(keys[c])
+
+				// Allow underscores for columns with spaces.
+				if row[j] == "" {
+					row[j] = documentValue(keys[strings.Replace(c, " ", "_", -1)])
+				}
+			}
+
+			trimSpace(row)
+
+			if isEmpty(row) {
+				continue
+			}
+
+			f(i+1, row)
+		}
+
+		return nil
+	}
+}
+
+// ReadDocument reads a JSON or YAML document with a list of objects per file
+// keyed by the kind of file, e.g. "entities". The keys of the objects are the
+// columns of the file.
+func (p *Parser) ReadDocument(r io.Reader, isJSON bool, handle ErrorHandler) error {
+	var doc map[string][]map[string]interface{}
+
+	if isJSON {
+		if err := json.NewDecoder(r).Decode(&doc); err != nil {
+			return err
+		}
+	} else {
+		b, err := ioutil.ReadAll(r)
+
+		if err != nil {
+			return err
+		}
+
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return err
+		}
+	}
+
+	for _, pf := range provFiles {
+		objs, ok := doc[pf.Kind]
+
+		if !ok {
+			if pf.Required {
+				return fmt.Errorf("List of '%s' is missing", pf.Kind)
+			}
+
+			handle(pf.Kind, []error{missingFile(pf.Kind)})
+			continue
+		}
+
+		handle(pf.Kind, pf.read(p, pf.Kind, documentRows(objs, pf.Columns)))
+	}
+
+	return nil
+}
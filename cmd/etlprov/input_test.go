@@ -0,0 +1,196 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tealeg/xlsx"
+)
+
+// readTestRows reads the rows of a file in the test data.
+func readTestRows(t *testing.T, name string) [][]string {
+	f, err := os.Open(filepath.Join("test_data", name))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	var rows [][]string
+
+	if err := ReadRows(f, func(line int, row []string) {
+		rows = append(rows, row)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return rows
+}
+
+func TestReadDirMissingOptional(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etlprov")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{EntitiesFile, StepsFile, ToolsFile, SourcesFile} {
+		b, err := ioutil.ReadFile(filepath.Join("test_data", name))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewParser(model)
+	m := make(errorMap)
+
+	if err := p.ReadDir(dir, m.Handle); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := m[PeopleFile]
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 warning for people, got %d", len(errs))
+	}
+
+	if e := errs[0].(*ValidationError); e.Severity != SeverityWarning || e.Code != MissingFileCode {
+		t.Errorf("unexpected error %+v", e)
+	}
+
+	// Steps are required.
+	os.Remove(filepath.Join(dir, StepsFile))
+
+	if err := NewParser(model).ReadDir(dir, m.Handle); err == nil {
+		t.Error("expected error for missing steps")
+	}
+}
+
+func TestReadWorkbook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etlprov")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	f := xlsx.NewFile()
+
+	for _, pf := range provFiles {
+		sheet, err := f.AddSheet(strings.ToUpper(pf.Kind[:1]) + pf.Kind[1:])
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rows := append([][]string{pf.Columns}, readTestRows(t, pf.Name)...)
+
+		for _, row := range rows {
+			r := sheet.AddRow()
+
+			for _, v := range row {
+				r.AddCell().SetString(v)
+			}
+		}
+	}
+
+	fn := filepath.Join(dir, "provenance.xlsx")
+
+	if err := f.Save(fn); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewParser(model)
+	m := make(errorMap)
+
+	if err := p.Read(fn, m.Handle); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, errs := range m {
+		if len(errs) != 0 {
+			t.Errorf("got %d errors for %s: %v", len(errs), k, errs)
+		}
+	}
+
+	expected := parseTestData(t)
+
+	if len(p.Entities()) != len(expected.Entities()) || len(p.Steps()) != len(expected.Steps()) || len(p.Sources()) != len(expected.Sources()) {
+		t.Errorf("workbook does not match the CSV files")
+	}
+}
+
+func TestReadDocument(t *testing.T) {
+	js := `{
+		"entities": [
+			{"entity": "person", "availability": "available", "transmitting": true},
+			{"entity": "person.person_id", "availability": "available"}
+		],
+		"steps": [
+			{"step": 1, "description": "Extract", "entities": ["person.person_id"]},
+			{"step": 2, "description": "Load", "entities": "person.person_id", "previous_step": 1},
+			{"step": 3, "description": "Bad", "entities": "person.person_id", "previous step": 9}
+		],
+		"tools": [
+			{"name": "PostgreSQL", "steps": "1-2", "version": 9.3}
+		]
+	}`
+
+	p := NewParser(model)
+	m := make(errorMap)
+
+	if err := p.ReadDocument(strings.NewReader(js), true, m.Handle); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Steps()) != 3 || p.steps[2].PreviousStep != p.steps[1] {
+		t.Errorf("steps not parsed")
+	}
+
+	if v := p.tools["PostgreSQL"].Version; v != "9.3" {
+		t.Errorf("expected version 9.3, got %s", v)
+	}
+
+	if errs := m["steps"]; len(errs) != 1 || errs[0].(*ValidationError).Line != 3 {
+		t.Errorf("expected error for the third step, got %v", errs)
+	}
+
+	for _, k := range []string{"sources", "people"} {
+		if errs := m[k]; len(errs) != 1 || errs[0].(*ValidationError).Code != MissingFileCode {
+			t.Errorf("expected missing warning for %s, got %v", k, errs)
+		}
+	}
+
+	yml := `
+entities:
+  - entity: person.person_id
+    availability: available
+steps:
+  - step: 1
+    description: Extract
+    entities: person.person_id
+`
+
+	p = NewParser(model)
+	m = make(errorMap)
+
+	if err := p.ReadDocument(strings.NewReader(yml), false, m.Handle); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Steps()) != 1 || len(p.Entities()) != 1 {
+		t.Errorf("expected 1 step and entity, got %d and %d", len(p.Steps()), len(p.Entities()))
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// How a step references the entity of a lineage.
+const (
+	ViaEntity   = "entity"
+	ViaTable    = "table"
+	ViaAll      = "all"
+	ViaAncestor = "ancestor"
+)
+
+// LineageStep is a step in the lineage of an entity. Chain is the IDs of the
+// step and its previous steps from the first step.
+type LineageStep struct {
+	ID          int    `json:"id"`
+	Description string `json:"description"`
+	Via         string `json:"via"`
+	Chain       []int  `json:"chain,omitempty"`
+}
+
+// LineageItem is a tool, source or person attributed to steps of a lineage.
+type LineageItem struct {
+	Name  string `json:"name"`
+	Steps []int  `json:"steps"`
+}
+
+// Lineage is the steps that touch an entity, their previous steps and the
+// tools, sources and people attributed to them.
+type Lineage struct {
+	Entity  string         `json:"entity"`
+	Steps   []*LineageStep `json:"steps"`
+	Tools   []*LineageItem `json:"tools"`
+	Sources []*LineageItem `json:"sources"`
+	People  []*LineageItem `json:"people"`
+}
+
+// touches returns how the step references the entity, if at all. A table
+// is touched by steps that reference it or any of its fields.
+func touches(s *Step, name string) string {
+	table := strings.SplitN(name, EntityDelim, 2)[0]
+	isTable := table == name
+
+	var direct, viaTable bool
+
+	for _, e := range s.Entities {
+		switch {
+		case e.Name == name:
+			direct = true
+		case e.Name == table:
+			viaTable = true
+		case isTable && strings.HasPrefix(e.Name, table+EntityDelim):
+			direct = true
+		}
+	}
+
+	switch {
+	case s.All && (direct || viaTable):
+		return ViaAll
+	case viaTable && !isTable:
+		return ViaTable
+	case direct || viaTable:
+		return ViaEntity
+	}
+
+	return ""
+}
+
+// lineageItems returns the items with the steps in the lineage.
+func lineageItems(names []string, steps [][]*Step, in map[*Step]struct{}) []*LineageItem {
+	items := []*LineageItem{}
+
+	for i, name := range names {
+		var ids []int
+
+		for _, s := range steps[i] {
+			if _, ok := in[s]; ok {
+				ids = append(ids, s.ID)
+			}
+		}
+
+		if len(ids) == 0 {
+			continue
+		}
+
+		sort.Ints(ids)
+		items = append(items, &LineageItem{Name: name, Steps: ids})
+	}
+
+	return items
+}
+
+// BuildLineage returns the lineage of the entity, which is a table or field.
+func BuildLineage(p *Parser, name string) (*Lineage, error) {
+	name = strings.ToLower(name)
+
+	if _, ok := p.entities[name]; !ok {
+		return nil, fmt.Errorf("Entity '%s' not defined", name)
+	}
+
+	l := &Lineage{
+		Entity: name,
+		Steps:  []*LineageStep{},
+	}
+
+	in := make(map[*Step]struct{})
+	ancestors := make(map[*Step]struct{})
+
+	for _, s := range p.Steps() {
+		via := touches(s, name)
+
+		if via == "" {
+			continue
+		}
+
+		in[s] = struct{}{}
+
+		ls := &LineageStep{
+			ID:          s.ID,
+			Description: s.Description,
+			Via:         via,
+		}
+
+		for x := s; x != nil; x = x.PreviousStep {
+			ls.Chain = append([]int{x.ID}, ls.Chain...)
+
+			if x != s {
+				ancestors[x] = struct{}{}
+			}
+		}
+
+		l.Steps = append(l.Steps, ls)
+	}
+
+	// Previous steps that do not touch the entity themselves.
+	for s := range ancestors {
+		if _, ok := in[s]; ok {
+			continue
+		}
+
+		in[s] = struct{}{}
+
+		l.Steps = append(l.Steps, &LineageStep{
+			ID:          s.ID,
+			Description: s.Description,
+			Via:         ViaAncestor,
+		})
+	}
+
+	sort.Slice(l.Steps, func(i, j int) bool {
+		return l.Steps[i].ID < l.Steps[j].ID
+	})
+
+	var (
+		names []string
+		steps [][]*Step
+	)
+
+	for _, t := range p.Tools() {
+		names = append(names, t.Name)
+		steps = append(steps, t.Steps)
+	}
+
+	l.Tools = lineageItems(names, steps, in)
+	names, steps = nil, nil
+
+	for _, s := range p.Sources() {
+		names = append(names, s.Name)
+		steps = append(steps, s.Steps)
+	}
+
+	l.Sources = lineageItems(names, steps, in)
+	names, steps = nil, nil
+
+	for _, x := range p.People() {
+		names = append(names, x.Name)
+		steps = append(steps, x.Steps)
+	}
+
+	l.People = lineageItems(names, steps, in)
+
+	return l, nil
+}
+
+// WriteJSON writes the lineage as JSON.
+func (l *Lineage) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
+
+func joinInts(ids []int, sep string) string {
+	toks := make([]string, len(ids))
+
+	for i, id := range ids {
+		toks[i] = fmt.Sprint(id)
+	}
+
+	return strings.Join(toks, sep)
+}
+
+// WriteText writes the steps with the chain of the steps that touch the
+// entity followed by the tools, sources and people.
+func (l *Lineage) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Lineage of '%s'\n", l.Entity)
+	fmt.Fprintln(w, "---")
+
+	if len(l.Steps) == 0 {
+		fmt.Fprintln(w, "No steps")
+	} else {
+		fmt.Fprintln(w, "Steps")
+	}
+
+	for _, s := range l.Steps {
+		fmt.Fprintf(w, "* Step %d (%s): %s\n", s.ID, s.Via, s.Description)
+
+		if len(s.Chain) > 1 {
+			fmt.Fprintf(w, "    %s\n", joinInts(s.Chain, " > "))
+		}
+	}
+
+	for _, g := range []struct {
+		Title string
+		Items []*LineageItem
+	}{
+		{"Tools", l.Tools},
+		{"Sources", l.Sources},
+		{"People", l.People},
+	} {
+		if len(g.Items) == 0 {
+			continue
+		}
+
+		fmt.Fprintln(w, "---")
+		fmt.Fprintln(w, g.Title)
+
+		for _, x := range g.Items {
+			fmt.Fprintf(w, "* %s: steps %s\n", x.Name, joinInts(x.Steps, ", "))
+		}
+	}
+
+	return nil
+}
+
+// lineageWriters are the output formats of the lineage.
+var lineageWriters = map[string]func(l *Lineage, w io.Writer) error{
+	"text": (*Lineage).WriteText,
+	"json": (*Lineage).WriteJSON,
+}
+
+func runLineage(args []string) {
+	var (
+		opts   modelOptions
+		format string
+	)
+
+	fs := flag.NewFlagSet("pedsnet-etlprov lineage", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: pedsnet-etlprov lineage [options] <entity> <dir|file>\n\n")
+		fmt.Fprintf(os.Stderr, "Lists the steps that touch <entity>, their previous steps and the tools, sources and people involved.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+
+	opts.register(fs)
+	fs.StringVar(&format, "format", "text", "Output format: text or json.")
+
+	fs.Parse(args)
+
+	write, ok := lineageWriters[format]
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown format '%s'\n", format)
+		os.Exit(1)
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	entity := fs.Arg(0)
+	dir := "."
+
+	if fs.NArg() > 1 {
+		dir = fs.Arg(1)
+	}
+
+	p := newParser(&opts, dir)
+
+	// Errors are printed, but the lineage is built with what was parsed.
+	readPath(p, dir, 10)
+
+	l, err := BuildLineage(p, entity)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := write(l, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Problem writing lineage\n> %s\n", err)
+		os.Exit(1)
+	}
+}
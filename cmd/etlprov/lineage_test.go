@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBuildLineage(t *testing.T) {
+	p := NewParser(model)
+
+	for _, name := range []string{"person", "person.person_id", "person.year_of_birth", "death", "death.person_id"} {
+		p.entities[name] = &Entity{Name: name}
+	}
+
+	all := p.Entities()
+
+	s1 := &Step{ID: 1, Entities: all, All: true}
+	s2 := &Step{ID: 2, Entities: []*Entity{p.entities["death.person_id"]}, PreviousStep: s1}
+	s3 := &Step{ID: 3, Entities: []*Entity{p.entities["person"], p.entities["person.person_id"], p.entities["person.year_of_birth"]}, PreviousStep: s2}
+	s4 := &Step{ID: 4, Entities: []*Entity{p.entities["person.year_of_birth"]}, PreviousStep: s2}
+	s5 := &Step{ID: 5, Entities: []*Entity{p.entities["death.person_id"]}}
+
+	for _, s := range []*Step{s1, s2, s3, s4, s5} {
+		p.steps[s.ID] = s
+	}
+
+	p.tools["PostgreSQL"] = &Tool{Name: "PostgreSQL", Steps: []*Step{s2, s5}}
+	p.sources["Clarity"] = &Source{Name: "Clarity", Steps: []*Step{s5}}
+
+	l, err := BuildLineage(p, "Person.Year_Of_Birth")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	via := make(map[int]string)
+
+	for _, s := range l.Steps {
+		via[s.ID] = s.Via
+	}
+
+	expected := map[int]string{
+		1: ViaAll,
+		2: ViaAncestor,
+		3: ViaTable,
+		4: ViaEntity,
+	}
+
+	if len(via) != len(expected) {
+		t.Errorf("expected %d steps, got %v", len(expected), via)
+	}
+
+	for id, v := range expected {
+		if via[id] != v {
+			t.Errorf("step %d: expected %s, got %s", id, v, via[id])
+		}
+	}
+
+	if len(l.Tools) != 1 || len(l.Tools[0].Steps) != 1 || l.Tools[0].Steps[0] != 2 {
+		t.Errorf("unexpected tools %+v", l.Tools)
+	}
+
+	if len(l.Sources) != 0 {
+		t.Errorf("expected no sources, got %+v", l.Sources)
+	}
+
+	var buf bytes.Buffer
+
+	if err := l.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "* Step 4 (entity): \n    1 > 2 > 4\n") {
+		t.Errorf("unexpected text output:\n%s", buf.String())
+	}
+
+	if _, err := BuildLineage(p, "person.foo"); err == nil {
+		t.Error("expected error for undefined entity")
+	}
+}
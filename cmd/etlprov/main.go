@@ -3,10 +3,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
 
+	"github.com/PEDSnet/tools/cmd/internal/datamodels"
 	dms "github.com/chop-dbhi/data-models-service/client"
 )
 
@@ -23,150 +25,293 @@ var pedsnetIgnores = []string{
 	"vocabulary",
 }
 
-func main() {
-	var (
-		dir      string
-		model    string
-		version  string
-		service  string
-		ignore   string
-		truncate bool
-		ver      bool
-	)
+// ignoredEntities returns the comma-separated entities to ignore or the
+// defaults for the model.
+func ignoredEntities(ignore string, dm *dms.Model) []string {
+	if ignore != "" {
+		return strings.Split(ignore, ",")
+	}
 
-	flag.StringVar(&model, "model", "pedsnet", "Name of the data model to validate against.")
-	flag.StringVar(&version, "version", "", "Version of the data model to validate against.")
-	flag.StringVar(&service, "service", "http://data-models.origins.link", "URL to the data models service.")
-	flag.StringVar(&ignore, "ignore", "", "Comma-separated list of entities to ignore.")
-	flag.BoolVar(&truncate, "truncate", true, "Truncate the list of errors.")
-	flag.BoolVar(&ver, "v", false, "Prints the version.")
+	// Special case defaults.
+	if dm.Name == "pedsnet" {
+		return pedsnetIgnores
+	}
 
-	flag.Parse()
+	return nil
+}
 
-	if ver {
-		fmt.Println(progVersion)
-		return
+// command is a mode of the program selected by the first argument.
+type command struct {
+	Short string
+	Run   func(args []string)
+}
+
+var commands = map[string]*command{
+	"init": {
+		Short: "Writes a template of the provenance files for the model.",
+		Run:   runInit,
+	},
+	"diff": {
+		Short: "Compares the provenance of two submissions.",
+		Run:   runDiff,
+	},
+	"export": {
+		Short: "Exports the provenance as a graph.",
+		Run:   runExport,
+	},
+	"lineage": {
+		Short: "Lists the steps, tools, sources and people behind an entity.",
+		Run:   runLineage,
+	},
+	"prov": {
+		Short: "Writes the provenance as PROV-JSON or PROV-N.",
+		Run:   runProv,
+	},
+	"timing": {
+		Short: "Computes the total time of each chain of steps.",
+		Run:   runTiming,
+	},
+}
+
+// modelOptions are the options for fetching the model revision.
+type modelOptions struct {
+	model   string
+	version string
+	service string
+	file    string
+	cache   string
+	offline bool
+}
+
+func (o *modelOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.model, "model", "pedsnet", "Name of the data model to validate against.")
+	fs.StringVar(&o.version, "version", "", "Version of the data model to validate against.")
+	fs.StringVar(&o.service, "service", dms.DefaultServiceURL, "URL to the data models service.")
+	fs.StringVar(&o.file, "model-file", "", "Local JSON file of the model revision. Takes precedence over the service.")
+	fs.StringVar(&o.cache, "model-cache", datamodels.DefaultCacheDir(), "Directory of cached model revisions.")
+	fs.BoolVar(&o.offline, "offline", false, "Only read the model revision from the cache.")
+}
+
+// load fetches the model revision.
+func (o *modelOptions) load() (*dms.Model, error) {
+	provider := &datamodels.Provider{
+		File:     o.file,
+		Service:  o.service,
+		CacheDir: o.cache,
+		Offline:  o.offline,
 	}
 
-	args := flag.Args()
+	var dm dms.Model
 
-	if len(args) == 0 {
-		dir = "."
-	} else {
-		dir = args[0]
+	if err := provider.Decode(o.model, o.version, &dm); err != nil {
+		return nil, err
 	}
 
-	// Ensure the directory exists. This is performed here to save a remote call.
-	stat, err := os.Stat(dir)
+	return &dm, nil
+}
+
+// checkPath ensures the directory, workbook or document exists. This is
+// performed before fetching the model to save a remote call.
+func checkPath(path string) error {
+	stat, err := os.Stat(path)
 
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		return err
 	}
 
-	if !stat.IsDir() {
-		fmt.Fprintf(os.Stderr, "'%s' not a directory\n", stat.Name())
-		os.Exit(1)
+	if !stat.IsDir() && !hasExt(path, workbookExts) && !hasExt(path, documentExts) {
+		return fmt.Errorf("'%s' not a directory, workbook or JSON or YAML document", stat.Name())
 	}
 
-	client, err := dms.New(service)
+	return nil
+}
 
-	if err != nil {
+// newParser ensures the path exists and returns a parser for the model
+// revision.
+func newParser(opts *modelOptions, path string) *Parser {
+	if err := checkPath(path); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	if err = client.Ping(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error communicating with service\n> %s\n", err)
+	dm, err := opts.load()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Problem fetching model data\n> %s\n", err)
 		os.Exit(1)
 	}
 
-	dm, err := client.ModelRevision(model, version)
+	return NewParser(dm)
+}
 
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Problem fetching model data\n> %s\n", err)
+// readPath parses the provenance in the directory, workbook or document.
+// Errors in the files are printed to stderr.
+func readPath(p *Parser, path string, limit int) {
+	errPrinter := ErrorPrinter{
+		Limit:  limit,
+		Writer: os.Stderr,
+	}
+
+	if err := p.Read(path, errPrinter.Handle); err != nil {
+		fmt.Fprintf(os.Stderr, "Problem parsing provenance in '%s'\n> %s\n", path, err)
 		os.Exit(1)
 	}
+}
 
-	fmt.Printf("Validating against model '%s/%s'\n", model, version)
-	fmt.Printf("Scanning files in '%s'\n", dir)
+func usage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "usage: pedsnet-etlprov [<command>] [options] <dir|file>\n\n")
+		fmt.Fprintf(os.Stderr, "Validates the provenance in <dir|file> unless a command is given.\n")
+		fmt.Fprintf(os.Stderr, "The provenance is a directory of CSV files, an XLSX workbook or a JSON or YAML document.\n\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
 
-	p := NewParser(dm)
+		var names []string
 
-	var limit int
+		for name := range commands {
+			names = append(names, name)
+		}
 
-	if truncate {
-		limit = 10
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].Short)
+		}
+
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		fs.PrintDefaults()
 	}
+}
 
-	errPrinter := ErrorPrinter{
-		Limit:  limit,
-		Writer: os.Stderr,
+func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd.Run(os.Args[2:])
+			return
+		}
 	}
 
-	if err = p.ReadDir(dir, errPrinter.Handle); err != nil {
-		fmt.Fprintf(os.Stderr, "Problem parsing files in directory '%s'\n> %s\n", dir, err)
+	runValidate(os.Args[1:])
+}
+
+// reportWriters are the output formats of the validation report other than
+// text, which is written differently.
+var reportWriters = map[string]func(r *Report, w io.Writer) error{
+	"json":  (*Report).WriteJSON,
+	"junit": (*Report).WriteJUnit,
+}
+
+func runValidate(args []string) {
+	var (
+		dir      string
+		opts     modelOptions
+		ignore   string
+		format   string
+		data     string
+		schema   string
+		truncate bool
+		graph    bool
+		ver      bool
+	)
+
+	fs := flag.NewFlagSet("pedsnet-etlprov", flag.ExitOnError)
+	fs.Usage = usage(fs)
+
+	opts.register(fs)
+	fs.StringVar(&ignore, "ignore", "", "Comma-separated list of entities to ignore.")
+	fs.StringVar(&format, "format", "text", "Output format: text, json or junit.")
+	fs.StringVar(&data, "data", "", "Directory of the site's CSV data files to check the availability of the entities against.")
+	fs.StringVar(&schema, "schema", "", "Schema dump of the site's database to check the availability of the entities against.")
+	fs.BoolVar(&truncate, "truncate", true, "Truncate the list of errors.")
+	fs.BoolVar(&graph, "graph", true, "Check the structure of the step graph.")
+	fs.BoolVar(&ver, "v", false, "Prints the version.")
+
+	fs.Parse(args)
+
+	if ver {
+		fmt.Println(progVersion)
+		return
+	}
+
+	write, ok := reportWriters[format]
+
+	if !ok && format != "text" {
+		fmt.Fprintf(os.Stderr, "Unknown format '%s'\n", format)
 		os.Exit(1)
 	}
 
-	// Determine missing entities.
-	var ignores []string
+	if data != "" && schema != "" {
+		fmt.Fprintln(os.Stderr, "Only one of -data or -schema can be specified")
+		os.Exit(1)
+	}
 
-	if ignore != "" {
-		ignores = strings.Split(ignore, ",")
+	args = fs.Args()
+
+	if len(args) == 0 {
+		dir = "."
 	} else {
-		// Special case defaults.
-		if dm.Name == "pedsnet" {
-			ignores = pedsnetIgnores
-		}
+		dir = args[0]
 	}
 
-	names := p.MissingEntities(true, ignores)
+	var limit int
 
-	if len(names) > 0 {
-		fmt.Println("---")
+	if truncate {
+		limit = 10
+	}
 
-		if len(names) == 1 {
-			fmt.Fprintln(os.Stderr, "1 entity is missing from the model")
-		} else {
-			fmt.Fprintf(os.Stderr, "%d entities are missing from the model\n", len(names))
-		}
+	p := newParser(&opts, dir)
 
-		sort.Strings(names)
-		errs := make([]error, len(names))
+	if format == "text" {
+		fmt.Printf("Validating against model '%s/%s'\n", opts.model, opts.version)
+		fmt.Printf("Scanning files in '%s'\n", dir)
+	}
 
-		for i, n := range names {
-			errs[i] = fmt.Errorf(n)
-		}
+	report := NewReport(opts.model, opts.version, dir)
 
-		printErrors(os.Stderr, errs, limit)
+	if err := p.Read(dir, report.Handle); err != nil {
+		fmt.Fprintf(os.Stderr, "Problem parsing provenance in '%s'\n> %s\n", dir, err)
+		os.Exit(1)
 	}
 
-	entities := p.EntitiesWithoutSteps()
+	report.Add(p.Validate(ignoredEntities(ignore, p.Model), graph)...)
 
-	if len(entities) > 0 {
-		fmt.Println("---")
+	if data != "" || schema != "" {
+		var (
+			site *SiteData
+			err  error
+		)
 
-		if len(entities) == 1 {
-			fmt.Fprintln(os.Stderr, "1 entity does not have steps")
+		if data != "" {
+			site, err = ReadDataDir(data)
 		} else {
-			fmt.Fprintf(os.Stderr, "%d entities do not have steps\n", len(entities))
+			site, err = ReadSchema(schema)
 		}
 
-		errs := make([]error, len(entities))
-
-		for i, e := range entities {
-			errs[i] = fmt.Errorf(e.Name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Problem reading the site's data\n> %s\n", err)
+			os.Exit(1)
 		}
 
-		printErrors(os.Stderr, errs, limit)
+		report.Add(p.CheckData(site)...)
 	}
+	report.Count(p)
 
-	fmt.Println("---")
+	if format == "text" {
+		report.WriteText(os.Stderr, limit)
 
-	fmt.Printf("%d entities\n", len(p.Entities()))
-	fmt.Printf("%d steps\n", len(p.Steps()))
-	fmt.Printf("%d tools\n", len(p.Tools()))
-	fmt.Printf("%d sources\n", len(p.Sources()))
-	fmt.Printf("%d persons\n", len(p.People()))
+		fmt.Println("---")
+
+		fmt.Printf("%d entities\n", report.Counts.Entities)
+		fmt.Printf("%d steps\n", report.Counts.Steps)
+		fmt.Printf("%d tools\n", report.Counts.Tools)
+		fmt.Printf("%d sources\n", report.Counts.Sources)
+		fmt.Printf("%d persons\n", report.Counts.People)
+	} else if err := write(report, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Problem writing report\n> %s\n", err)
+		os.Exit(1)
+	}
+
+	// Fail if any error-severity findings exist.
+	if !report.Valid {
+		os.Exit(1)
+	}
 }
@@ -1,5 +1,7 @@
 package main
 
+import "time"
+
 type Entity struct {
 	Name         string
 	Availability string
@@ -15,6 +17,15 @@ type Step struct {
 	Entities     []*Entity
 	PreviousStep *Step
 	Time         string
+
+	// Duration of the step parsed from the time. Start and End are set if
+	// the time is given as timestamps.
+	Duration time.Duration
+	Start    time.Time
+	End      time.Time
+
+	// All is true if the step applies to all entities.
+	All bool
 }
 
 type Tool struct {
@@ -6,8 +6,10 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	dms "github.com/chop-dbhi/data-models-service/client"
 )
@@ -29,6 +31,66 @@ var entitySplitter = regexp.MustCompile(`[,\s+]`)
 // Regex for a step range, taking the form "N-M".
 var stepRange = regexp.MustCompile(`(\d+)\s*-\s*(\d+)`)
 
+// TimeDelim separates the start and end timestamps of a step time.
+const TimeDelim = "/"
+
+// Accepted layouts of the start and end timestamps of a step time.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+func parseTimestamp(v string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Invalid timestamp '%s'", v)
+}
+
+// parseStepTime parses the time of a step which is either a duration, e.g.
+// "45m" or "1h30m", or the start and end timestamps separated by a slash,
+// e.g. "2016-03-01 08:00/2016-03-01 08:45".
+func parseStepTime(v string) (start, end time.Time, d time.Duration, err error) {
+	if !strings.Contains(v, TimeDelim) {
+		d, err = time.ParseDuration(v)
+
+		if err != nil {
+			err = newError("time", InvalidValueCode, "Invalid duration '%s'", v)
+		} else if d <= 0 {
+			err = newError("time", InvalidValueCode, "Duration '%s' must be positive", v)
+		}
+
+		return
+	}
+
+	toks := strings.SplitN(v, TimeDelim, 2)
+
+	if start, err = parseTimestamp(strings.TrimSpace(toks[0])); err != nil {
+		err = withColumn(err, "time")
+		return
+	}
+
+	if end, err = parseTimestamp(strings.TrimSpace(toks[1])); err != nil {
+		err = withColumn(err, "time")
+		return
+	}
+
+	if !end.After(start) {
+		err = newError("time", InvalidValueCode, "End of time '%s' is not after the start", v)
+		return
+	}
+
+	d = end.Sub(start)
+
+	return
+}
+
 // Parses the availability string ensuring it is valid.
 func parseAvailability(v string) (string, error) {
 	x := strings.ToLower(v)
@@ -38,7 +100,7 @@ func parseAvailability(v string) (string, error) {
 		return x, nil
 	}
 
-	return "", fmt.Errorf("Invalid choice for availability: %s", v)
+	return "", newError("availability", InvalidValueCode, "Invalid choice for availability: %s", v)
 }
 
 // Parses the transmitting boolean ensuring it is valid.
@@ -52,7 +114,7 @@ func parseTransmitting(v string) (bool, error) {
 		return false, nil
 	}
 
-	return false, fmt.Errorf("Invalid choice for transmitting: %s", v)
+	return false, newError("transmitting", InvalidValueCode, "Invalid choice for transmitting: %s", v)
 }
 
 type Parser struct {
@@ -67,7 +129,7 @@ type Parser struct {
 
 func (p *Parser) parseTool(record []string) (*Tool, error) {
 	if record[0] == "" {
-		return nil, fmt.Errorf("Tool name required")
+		return nil, newError("name", RequiredValueCode, "Tool name required")
 	}
 
 	t := &Tool{
@@ -79,7 +141,7 @@ func (p *Parser) parseTool(record []string) (*Tool, error) {
 	steps, err := p.parseStepString(record[1])
 
 	if err != nil {
-		return t, err
+		return t, withColumn(err, "steps")
 	}
 
 	t.Steps = steps
@@ -102,7 +164,7 @@ func (p *Parser) parseStepString(v string) ([]*Step, error) {
 			u, _ := strconv.Atoi(match[2])
 
 			if u < l {
-				return nil, fmt.Errorf("Invalid step range %s", t)
+				return nil, newError("", InvalidValueCode, "Invalid step range %s", t)
 			}
 
 			// Ensure the upper and lower bound explicitly match in the
@@ -125,23 +187,23 @@ func (p *Parser) parseStepString(v string) ([]*Step, error) {
 			}
 
 			if !lm {
-				return nil, fmt.Errorf("Low step in range not defined %d", l)
+				return nil, newError("", UndefinedStepCode, "Low step in range not defined %d", l)
 			}
 
 			if !um {
-				return nil, fmt.Errorf("High step in range not defined %d", u)
+				return nil, newError("", UndefinedStepCode, "High step in range not defined %d", u)
 			}
 		} else if t != "" {
 			i, err := strconv.Atoi(t)
 
 			if err != nil {
-				return nil, fmt.Errorf("Invalid step number %s", t)
+				return nil, newError("", InvalidValueCode, "Invalid step number %s", t)
 			}
 
 			s, ok := p.steps[i]
 
 			if !ok {
-				return nil, fmt.Errorf("Step %d not defined", i)
+				return nil, newError("", UndefinedStepCode, "Step %d not defined", i)
 			}
 
 			steps = append(steps, s)
@@ -160,14 +222,14 @@ func (p *Parser) parseStep(record []string) (*Step, error) {
 	)
 
 	if record[0] == "" {
-		return nil, fmt.Errorf("Step ID required")
+		return nil, newError("step", RequiredValueCode, "Step ID required")
 	}
 
 	// Try to convert into a number.
 	id, err = strconv.Atoi(record[0])
 
 	if err != nil {
-		return nil, err
+		return nil, newError("step", InvalidValueCode, "Invalid step ID '%s'", record[0])
 	}
 
 	s := &Step{
@@ -181,31 +243,36 @@ func (p *Parser) parseStep(record []string) (*Step, error) {
 	entities, err := p.parseEntityString(record[2])
 
 	if err != nil {
-		return s, err
+		return s, withColumn(err, "entities")
 	}
 
 	s.Entities = entities
+	s.All = appliesToAll(record[2])
 
 	// Special case of returning the step wit the error to
 	// prevent cascading errors.
 	if record[3] != "" {
 		// Parse the previous step if specified.
 		if pid, err = strconv.Atoi(record[3]); err != nil {
-			err = fmt.Errorf("Error parsing previous step '%s'", record[3])
+			err = newError("previous step", InvalidValueCode, "Error parsing previous step '%s'", record[3])
 		} else if prev, ok = p.steps[pid]; !ok {
-			err = fmt.Errorf("Step %d does not exist", pid)
+			err = newError("previous step", UndefinedStepCode, "Step %d does not exist", pid)
 		}
 
 		s.PreviousStep = prev
 	}
 
+	if err == nil && s.Time != "" {
+		s.Start, s.End, s.Duration, err = parseStepTime(s.Time)
+	}
+
 	return s, err
 }
 
 // parseSource parses a source record.
 func (p *Parser) parseSource(record []string) (*Source, error) {
 	if record[0] == "" {
-		return nil, fmt.Errorf("Source name required")
+		return nil, newError("name", RequiredValueCode, "Source name required")
 	}
 
 	s := &Source{
@@ -217,7 +284,7 @@ func (p *Parser) parseSource(record []string) (*Source, error) {
 	steps, err := p.parseStepString(record[1])
 
 	if err != nil {
-		return s, err
+		return s, withColumn(err, "steps")
 	}
 
 	s.Steps = steps
@@ -228,7 +295,7 @@ func (p *Parser) parseSource(record []string) (*Source, error) {
 // parsePerson parses a person record.
 func (p *Parser) parsePerson(record []string) (*Person, error) {
 	if record[0] == "" {
-		return nil, fmt.Errorf("Person name required")
+		return nil, newError("name", RequiredValueCode, "Person name required")
 	}
 
 	b := &Person{
@@ -240,7 +307,7 @@ func (p *Parser) parsePerson(record []string) (*Person, error) {
 	steps, err := p.parseStepString(record[3])
 
 	if err != nil {
-		return b, err
+		return b, withColumn(err, "steps")
 	}
 
 	b.Steps = steps
@@ -252,7 +319,7 @@ func (p *Parser) parseEntity(record []string) (*Entity, error) {
 	name, err := p.validateEntityName(record[0])
 
 	if err != nil {
-		return nil, err
+		return nil, withColumn(err, "entity")
 	}
 
 	e := &Entity{
@@ -288,9 +355,9 @@ func (p *Parser) validateEntityName(name string) (string, error) {
 
 	if table == nil {
 		if len(toks) > 1 {
-			return "", fmt.Errorf("Unknown table '%s' for field '%s'", toks[0], toks[1])
+			return "", newError("", UnknownEntityCode, "Unknown table '%s' for field '%s'", toks[0], toks[1])
 		}
-		return "", fmt.Errorf("Unknown table '%s'", toks[0])
+		return "", newError("", UnknownEntityCode, "Unknown table '%s'", toks[0])
 	}
 
 	if len(toks) == 1 {
@@ -300,12 +367,23 @@ func (p *Parser) validateEntityName(name string) (string, error) {
 	field := table.Fields.Get(toks[1])
 
 	if field == nil {
-		return "", fmt.Errorf("Unknown field '%s'", name)
+		return "", newError("", UnknownEntityCode, "Unknown field '%s'", name)
 	}
 
 	return name, nil
 }
 
+// appliesToAll returns true if the entity string refers to all entities.
+func appliesToAll(s string) bool {
+	for _, name := range entitySplitter.Split(strings.ToLower(s), -1) {
+		if name == "all" || name == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
 // parseEntityString parses a string and validates it against an entity parser
 // that is populated with entities.
 func (p *Parser) parseEntityString(s string) ([]*Entity, error) {
@@ -342,7 +420,7 @@ func (p *Parser) parseEntityString(s string) ([]*Entity, error) {
 			if e, ok := p.entities[name]; ok {
 				entities = append(entities, e)
 			} else {
-				return nil, fmt.Errorf("Entity '%s' not defined", name)
+				return nil, newError("", UndefinedEntityCode, "Entity '%s' not defined", name)
 			}
 
 			for _, f := range t.Fields.List() {
@@ -351,7 +429,7 @@ func (p *Parser) parseEntityString(s string) ([]*Entity, error) {
 				if e, ok := p.entities[n]; ok {
 					entities = append(entities, e)
 				} else {
-					return nil, fmt.Errorf("Entity '%s' not defined", n)
+					return nil, newError("", UndefinedEntityCode, "Entity '%s' not defined", n)
 				}
 			}
 
@@ -361,7 +439,7 @@ func (p *Parser) parseEntityString(s string) ([]*Entity, error) {
 		if e, ok := p.entities[name]; ok {
 			entities = append(entities, e)
 		} else {
-			return nil, fmt.Errorf("Entity '%s' not defined", name)
+			return nil, newError("", UndefinedEntityCode, "Entity '%s' not defined", name)
 		}
 	}
 
@@ -369,23 +447,29 @@ func (p *Parser) parseEntityString(s string) ([]*Entity, error) {
 }
 
 func (p *Parser) ReadEntities(r io.Reader) []error {
+	return p.readEntities(EntitiesFile, csvRows(r))
+}
+
+func (p *Parser) readEntities(name string, rows rowFunc) []error {
 	var (
 		e    *Entity
 		err  error
 		errs []error
 	)
 
-	err = ReadRows(r, func(row []string) {
+	err = rows(func(line int, row []string) {
+		row = padRow(row, len(entityColumns))
+
 		e, err = p.parseEntity(row)
 
 		if err != nil {
-			errs = append(errs, err)
+			errs = append(errs, locate(err, name, line))
 		}
 
 		if e != nil {
 			if _, ok := p.entities[e.Name]; ok {
-				err = fmt.Errorf("Duplicate entity '%s' found", e.Name)
-				errs = append(errs, err)
+				err = newError("entity", DuplicateCode, "Duplicate entity '%s' found", e.Name)
+				errs = append(errs, locate(err, name, line))
 				return
 			}
 
@@ -395,31 +479,37 @@ func (p *Parser) ReadEntities(r io.Reader) []error {
 
 	// Error returned while reading.
 	if err != nil {
-		return []error{err}
+		return []error{locate(err, name, 0)}
 	}
 
 	return errs
 }
 
 func (p *Parser) ReadSteps(r io.Reader) []error {
+	return p.readSteps(StepsFile, csvRows(r))
+}
+
+func (p *Parser) readSteps(name string, rows rowFunc) []error {
 	var (
 		s    *Step
 		err  error
 		errs []error
 	)
 
-	err = ReadRows(r, func(row []string) {
+	err = rows(func(line int, row []string) {
+		row = padRow(row, len(stepColumns))
+
 		s, err = p.parseStep(row)
 
 		// Special case to log steps to prevent cascading errors.
 		if err != nil {
-			errs = append(errs, err)
+			errs = append(errs, locate(err, name, line))
 		}
 
 		if s != nil {
 			if _, ok := p.steps[s.ID]; ok {
-				err = fmt.Errorf("Duplicate step '%d', found", s.ID)
-				errs = append(errs, err)
+				err = newError("step", DuplicateCode, "Duplicate step '%d' found", s.ID)
+				errs = append(errs, locate(err, name, line))
 				return
 			}
 
@@ -429,30 +519,36 @@ func (p *Parser) ReadSteps(r io.Reader) []error {
 
 	// Error returned while reading.
 	if err != nil {
-		return []error{err}
+		return []error{locate(err, name, 0)}
 	}
 
 	return errs
 }
 
 func (p *Parser) ReadTools(r io.Reader) []error {
+	return p.readTools(ToolsFile, csvRows(r))
+}
+
+func (p *Parser) readTools(name string, rows rowFunc) []error {
 	var (
 		t    *Tool
 		err  error
 		errs []error
 	)
 
-	err = ReadRows(r, func(row []string) {
+	err = rows(func(line int, row []string) {
+		row = padRow(row, len(toolColumns))
+
 		t, err = p.parseTool(row)
 
 		if err != nil {
-			errs = append(errs, err)
+			errs = append(errs, locate(err, name, line))
 		}
 
 		if t != nil {
 			if _, ok := p.tools[t.Name]; ok {
-				err = fmt.Errorf("Duplicate tool '%s', found", t.Name)
-				errs = append(errs, err)
+				err = newError("name", DuplicateCode, "Duplicate tool '%s' found", t.Name)
+				errs = append(errs, locate(err, name, line))
 				return
 			}
 
@@ -462,31 +558,37 @@ func (p *Parser) ReadTools(r io.Reader) []error {
 
 	// Error returned while reading.
 	if err != nil {
-		return []error{err}
+		return []error{locate(err, name, 0)}
 	}
 
 	return errs
 }
 
 func (p *Parser) ReadSources(r io.Reader) []error {
+	return p.readSources(SourcesFile, csvRows(r))
+}
+
+func (p *Parser) readSources(name string, rows rowFunc) []error {
 	var (
 		s    *Source
 		err  error
 		errs []error
 	)
 
-	err = ReadRows(r, func(row []string) {
+	err = rows(func(line int, row []string) {
+		row = padRow(row, len(sourceColumns))
+
 		s, err = p.parseSource(row)
 
 		if err != nil {
-			errs = append(errs, err)
+			errs = append(errs, locate(err, name, line))
 			return
 		}
 
 		if s != nil {
 			if _, ok := p.sources[s.Name]; ok {
-				err = fmt.Errorf("Duplicate source '%s', found", s.Name)
-				errs = append(errs, err)
+				err = newError("name", DuplicateCode, "Duplicate source '%s' found", s.Name)
+				errs = append(errs, locate(err, name, line))
 				return
 			}
 
@@ -496,31 +598,37 @@ func (p *Parser) ReadSources(r io.Reader) []error {
 
 	// Error returned while reading.
 	if err != nil {
-		return []error{err}
+		return []error{locate(err, name, 0)}
 	}
 
 	return errs
 }
 
 func (p *Parser) ReadPeople(r io.Reader) []error {
+	return p.readPeople(PeopleFile, csvRows(r))
+}
+
+func (p *Parser) readPeople(name string, rows rowFunc) []error {
 	var (
 		v    *Person
 		err  error
 		errs []error
 	)
 
-	err = ReadRows(r, func(row []string) {
+	err = rows(func(line int, row []string) {
+		row = padRow(row, len(personColumns))
+
 		v, err = p.parsePerson(row)
 
 		if err != nil {
-			errs = append(errs, err)
+			errs = append(errs, locate(err, name, line))
 			return
 		}
 
 		if v != nil {
 			if _, ok := p.people[v.Name]; ok {
-				err = fmt.Errorf("Duplicate step '%v', found", v.Name)
-				errs = append(errs, err)
+				err = newError("name", DuplicateCode, "Duplicate person '%v' found", v.Name)
+				errs = append(errs, locate(err, name, line))
 				return
 			}
 
@@ -530,7 +638,7 @@ func (p *Parser) ReadPeople(r io.Reader) []error {
 
 	// Error returned while reading.
 	if err != nil {
-		return []error{err}
+		return []error{locate(err, name, 0)}
 	}
 
 	return errs
@@ -604,7 +712,7 @@ func (p *Parser) MissingEntities(req bool, ignores []string) []string {
 	return names
 }
 
-// Entities returns the entities that have been parsed.
+// Entities returns the entities that have been parsed sorted by name.
 func (p *Parser) Entities() []*Entity {
 	i := 0
 	a := make([]*Entity, len(p.entities))
@@ -614,10 +722,14 @@ func (p *Parser) Entities() []*Entity {
 		i++
 	}
 
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].Name < a[j].Name
+	})
+
 	return a
 }
 
-// Steps returns the steps that have been parsed.
+// Steps returns the steps that have been parsed sorted by ID.
 func (p *Parser) Steps() []*Step {
 	i := 0
 	a := make([]*Step, len(p.steps))
@@ -627,10 +739,14 @@ func (p *Parser) Steps() []*Step {
 		i++
 	}
 
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].ID < a[j].ID
+	})
+
 	return a
 }
 
-// Sources returns the sources that have been parsed.
+// Sources returns the sources that have been parsed sorted by name.
 func (p *Parser) Sources() []*Source {
 	i := 0
 	a := make([]*Source, len(p.sources))
@@ -640,10 +756,14 @@ func (p *Parser) Sources() []*Source {
 		i++
 	}
 
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].Name < a[j].Name
+	})
+
 	return a
 }
 
-// Tools returns the tools that have been parsed.
+// Tools returns the tools that have been parsed sorted by name.
 func (p *Parser) Tools() []*Tool {
 	i := 0
 	a := make([]*Tool, len(p.tools))
@@ -653,10 +773,14 @@ func (p *Parser) Tools() []*Tool {
 		i++
 	}
 
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].Name < a[j].Name
+	})
+
 	return a
 }
 
-// People returns the people that have been parsed.
+// People returns the people that have been parsed sorted by name.
 func (p *Parser) People() []*Person {
 	i := 0
 	a := make([]*Person, len(p.people))
@@ -666,82 +790,35 @@ func (p *Parser) People() []*Person {
 		i++
 	}
 
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].Name < a[j].Name
+	})
+
 	return a
 }
 
+// ReadDir reads the files in the directory. Optional files that are missing
+// are passed to the handler as a warning.
 func (p *Parser) ReadDir(dir string, handle ErrorHandler) error {
-	var (
-		name string
-		file *os.File
-		err  error
-		errs []error
-	)
-
-	// Entities.
-	name = filepath.Join(dir, EntitiesFile)
-	file, err = os.Open(name)
-
-	if err != nil {
-		return err
-	}
-
-	errs = p.ReadEntities(file)
-
-	file.Close()
-	handle(EntitiesFile, errs)
-
-	// Steps.
-	name = filepath.Join(dir, StepsFile)
-	file, err = os.Open(name)
-
-	if err != nil {
-		return err
-	}
-
-	errs = p.ReadSteps(file)
-
-	file.Close()
-	handle(StepsFile, errs)
+	for _, pf := range provFiles {
+		name := filepath.Join(dir, pf.Name)
+		file, err := os.Open(name)
 
-	// Tools.
-	name = filepath.Join(dir, ToolsFile)
-	file, err = os.Open(name)
-
-	if err != nil {
-		return err
-	}
-
-	errs = p.ReadTools(file)
-
-	file.Close()
-	handle(ToolsFile, errs)
-
-	// Sources.
-	name = filepath.Join(dir, SourcesFile)
-	file, err = os.Open(name)
-
-	if err != nil {
-		return err
-	}
-
-	errs = p.ReadSources(file)
+		if os.IsNotExist(err) && !pf.Required {
+			handle(pf.Name, []error{missingFile(pf.Name)})
+			continue
+		}
 
-	file.Close()
-	handle(SourcesFile, errs)
+		if err != nil {
+			return err
+		}
 
-	// People.
-	name = filepath.Join(dir, PeopleFile)
-	file, err = os.Open(name)
+		errs := pf.read(p, pf.Name, csvRows(file))
 
-	if err != nil {
-		return err
+		file.Close()
+		handle(pf.Name, errs)
 	}
 
-	errs = p.ReadPeople(file)
-	file.Close()
-
-	handle(PeopleFile, errs)
-
 	return nil
 }
 
@@ -764,15 +841,29 @@ type ErrorPrinter struct {
 	Writer io.Writer
 }
 
-// Print implements the ErrorHandler
+// Print implements the ErrorHandler. Errors and warnings are counted
+// separately using the severity of the validation errors.
 func (p *ErrorPrinter) Handle(name string, errs []error) {
-	if len(errs) > 0 {
+	bySeverity := make(map[string][]error)
+
+	for _, err := range errs {
+		s := asValidationError(err).Severity
+		bySeverity[s] = append(bySeverity[s], err)
+	}
+
+	for _, severity := range []string{SeverityError, SeverityWarning} {
+		errs := bySeverity[severity]
+
+		if len(errs) == 0 {
+			continue
+		}
+
 		fmt.Fprintln(p.Writer, "---")
 
 		if len(errs) == 1 {
-			fmt.Fprintf(p.Writer, "1 error has been detected for '%s'\n", name)
+			fmt.Fprintf(p.Writer, "1 %s has been detected for '%s'\n", severity, name)
 		} else {
-			fmt.Fprintf(p.Writer, "%d errors have been detected for '%s'\n", len(errs), name)
+			fmt.Fprintf(p.Writer, "%d %ss have been detected for '%s'\n", len(errs), severity, name)
 		}
 
 		printErrors(p.Writer, errs, p.Limit)
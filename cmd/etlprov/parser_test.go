@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
+	"github.com/PEDSnet/tools/cmd/internal/datamodels"
 	dms "github.com/chop-dbhi/data-models-service/client"
 )
 
@@ -15,8 +18,16 @@ func (m errorMap) Handle(name string, errs []error) {
 var model *dms.Model
 
 func init() {
-	client, _ := dms.New("http://data-models.origins.link")
-	model, _ = client.ModelRevision("pedsnet", "2.0.0")
+	// Tables and fields of the model referenced by the test data.
+	p := &datamodels.Provider{
+		File: "test_data/pedsnet-2.0.0.json",
+	}
+
+	model = new(dms.Model)
+
+	if err := p.Decode("pedsnet", "2.0.0", model); err != nil {
+		panic(err)
+	}
 }
 
 func TestEntityParser(t *testing.T) {
@@ -102,3 +113,29 @@ func TestParser(t *testing.T) {
 		}
 	}
 }
+
+func TestErrorPrinter(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := ErrorPrinter{Writer: &buf}
+
+	p.Handle(ToolsFile, []error{missingFile(ToolsFile)})
+
+	if s := buf.String(); !strings.Contains(s, "1 warning has been detected for 'tools.csv'") {
+		t.Errorf("expected a warning, got %s", s)
+	}
+
+	buf.Reset()
+
+	p.Handle(StepsFile, []error{
+		newError("step", InvalidValueCode, "Invalid step"),
+		newError("step", InvalidValueCode, "Invalid step"),
+		&ValidationError{Severity: SeverityWarning, Code: StepWithoutToolCheck, Message: "No tool"},
+	})
+
+	s := buf.String()
+
+	if !strings.Contains(s, "2 errors have been detected") || !strings.Contains(s, "1 warning has been detected") {
+		t.Errorf("expected errors and warnings to be counted separately, got %s", s)
+	}
+}
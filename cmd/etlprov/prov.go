@@ -0,0 +1,455 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// DefaultProvNamespace is the namespace of the steps, tools, sources and
+// people in PROV output.
+const DefaultProvNamespace = "urn:pedsnet:etl:"
+
+// Prefix of the steps, tools, sources and people.
+const provPrefix = "etl"
+
+// Characters that are not allowed in the local part of an identifier.
+var provInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_.\-]+`)
+
+// provAttr is an attribute of a PROV record. The value is a qualified name
+// if QName is true and a string otherwise.
+type provAttr struct {
+	Key   string
+	Value string
+	QName bool
+}
+
+// provArg is an argument of a PROV relation. Key is the name of the
+// argument in PROV-JSON.
+type provArg struct {
+	Key   string
+	Value string
+}
+
+// provRecord is an entity, activity or agent.
+type provRecord struct {
+	ID    string
+	Attrs []provAttr
+}
+
+// provRelation is a relation between records. Times are the number of
+// optional time arguments of the relation in PROV-N.
+type provRelation struct {
+	Kind  string
+	Args  []provArg
+	Times int
+	Attrs []provAttr
+}
+
+// ProvDocument is the provenance of an ETL in the PROV data model. Steps are
+// activities, tools and sources are entities used by the steps, people are
+// agents associated with the steps and model tables and fields are entities
+// generated by the steps.
+type ProvDocument struct {
+	Prefixes   [][2]string
+	Entities   []*provRecord
+	Activities []*provRecord
+	Agents     []*provRecord
+	Relations  []*provRelation
+}
+
+// provLocal returns a valid local part of an identifier.
+func provLocal(kind, name string) string {
+	return kind + "-" + strings.Trim(provInvalidChars.ReplaceAllString(name, "_"), "_")
+}
+
+func provStepID(s *Step) string {
+	return fmt.Sprintf("%s:%s", provPrefix, provLocal("step", fmt.Sprint(s.ID)))
+}
+
+// addAttr adds a string attribute if the value is not empty.
+func addAttr(attrs []provAttr, key, value string) []provAttr {
+	if value == "" {
+		return attrs
+	}
+
+	return append(attrs, provAttr{Key: key, Value: value})
+}
+
+// BuildProv builds the PROV document of the parsed provenance. The model
+// namespace is the namespace of the tables and fields.
+func BuildProv(p *Parser, namespace, modelNamespace string) *ProvDocument {
+	modelPrefix := provInvalidChars.ReplaceAllString(p.Model.Name, "_")
+
+	doc := &ProvDocument{
+		Prefixes: [][2]string{
+			{provPrefix, namespace},
+			{modelPrefix, modelNamespace},
+		},
+	}
+
+	for _, s := range p.Steps() {
+		attrs := []provAttr{
+			{Key: "prov:type", Value: provPrefix + ":Step", QName: true},
+			{Key: "prov:label", Value: fmt.Sprintf("Step %d", s.ID)},
+		}
+
+		attrs = addAttr(attrs, provPrefix+":description", s.Description)
+		attrs = addAttr(attrs, provPrefix+":time", s.Time)
+
+		doc.Activities = append(doc.Activities, &provRecord{
+			ID:    provStepID(s),
+			Attrs: attrs,
+		})
+
+		if s.PreviousStep != nil {
+			doc.Relations = append(doc.Relations, &provRelation{
+				Kind: "wasInformedBy",
+				Args: []provArg{
+					{"prov:informed", provStepID(s)},
+					{"prov:informant", provStepID(s.PreviousStep)},
+				},
+			})
+		}
+
+		for _, e := range sortedEntities(s.Entities) {
+			doc.Relations = append(doc.Relations, &provRelation{
+				Kind: "wasGeneratedBy",
+				Args: []provArg{
+					{"prov:entity", fmt.Sprintf("%s:%s", modelPrefix, e.Name)},
+					{"prov:activity", provStepID(s)},
+				},
+				Times: 1,
+			})
+		}
+	}
+
+	for _, e := range p.Entities() {
+		kind := "Table"
+
+		if strings.Contains(e.Name, EntityDelim) {
+			kind = "Field"
+		}
+
+		attrs := []provAttr{
+			{Key: "prov:type", Value: provPrefix + ":" + kind, QName: true},
+			{Key: "prov:label", Value: e.Name},
+			{Key: provPrefix + ":availability", Value: e.Availability},
+			{Key: provPrefix + ":transmitting", Value: yesNo(e.Transmitting)},
+		}
+
+		attrs = addAttr(attrs, provPrefix+":comment", e.Comment)
+
+		doc.Entities = append(doc.Entities, &provRecord{
+			ID:    fmt.Sprintf("%s:%s", modelPrefix, e.Name),
+			Attrs: attrs,
+		})
+	}
+
+	used := func(kind, name, usage, version string, steps []*Step) {
+		id := fmt.Sprintf("%s:%s", provPrefix, provLocal(strings.ToLower(kind), name))
+
+		attrs := []provAttr{
+			{Key: "prov:type", Value: provPrefix + ":" + kind, QName: true},
+			{Key: "prov:label", Value: name},
+		}
+
+		attrs = addAttr(attrs, provPrefix+":usage", usage)
+		attrs = addAttr(attrs, provPrefix+":version", version)
+
+		doc.Entities = append(doc.Entities, &provRecord{
+			ID:    id,
+			Attrs: attrs,
+		})
+
+		for _, s := range sortedSteps(steps) {
+			doc.Relations = append(doc.Relations, &provRelation{
+				Kind: "used",
+				Args: []provArg{
+					{"prov:activity", provStepID(s)},
+					{"prov:entity", id},
+				},
+				Times: 1,
+			})
+		}
+	}
+
+	for _, t := range p.Tools() {
+		used("Tool", t.Name, t.Usage, t.Version, t.Steps)
+	}
+
+	for _, s := range p.Sources() {
+		used("Source", s.Name, s.Usage, s.Version, s.Steps)
+	}
+
+	for _, x := range p.People() {
+		id := fmt.Sprintf("%s:%s", provPrefix, provLocal("person", x.Name))
+
+		attrs := []provAttr{
+			{Key: "prov:type", Value: "prov:Person", QName: true},
+			{Key: "prov:label", Value: x.Name},
+		}
+
+		attrs = addAttr(attrs, provPrefix+":email", x.Email)
+
+		doc.Agents = append(doc.Agents, &provRecord{
+			ID:    id,
+			Attrs: attrs,
+		})
+
+		for _, s := range sortedSteps(x.Steps) {
+			doc.Relations = append(doc.Relations, &provRelation{
+				Kind: "wasAssociatedWith",
+				Args: []provArg{
+					{"prov:activity", provStepID(s)},
+					{"prov:agent", id},
+				},
+				Times: 1,
+				Attrs: addAttr(nil, "prov:role", x.Role),
+			})
+		}
+	}
+
+	return doc
+}
+
+// jsonAttrs returns the attributes as a PROV-JSON object.
+func jsonAttrs(attrs []provAttr) map[string]interface{} {
+	m := make(map[string]interface{}, len(attrs))
+
+	for _, a := range attrs {
+		if a.QName {
+			m[a.Key] = map[string]string{
+				"$":    a.Value,
+				"type": "prov:QUALIFIED_NAME",
+			}
+		} else {
+			m[a.Key] = a.Value
+		}
+	}
+
+	return m
+}
+
+// WriteJSON writes the document as PROV-JSON.
+func (d *ProvDocument) WriteJSON(w io.Writer) error {
+	out := make(map[string]interface{})
+
+	prefixes := make(map[string]string)
+
+	for _, p := range d.Prefixes {
+		prefixes[p[0]] = p[1]
+	}
+
+	out["prefix"] = prefixes
+
+	records := map[string][]*provRecord{
+		"entity":   d.Entities,
+		"activity": d.Activities,
+		"agent":    d.Agents,
+	}
+
+	for kind, rs := range records {
+		if len(rs) == 0 {
+			continue
+		}
+
+		m := make(map[string]interface{}, len(rs))
+
+		for _, r := range rs {
+			m[r.ID] = jsonAttrs(r.Attrs)
+		}
+
+		out[kind] = m
+	}
+
+	// Relations are identified by blank nodes numbered by kind.
+	counts := make(map[string]int)
+
+	for _, r := range d.Relations {
+		m, ok := out[r.Kind].(map[string]interface{})
+
+		if !ok {
+			m = make(map[string]interface{})
+			out[r.Kind] = m
+		}
+
+		counts[r.Kind]++
+
+		attrs := jsonAttrs(r.Attrs)
+
+		for _, a := range r.Args {
+			attrs[a.Key] = a.Value
+		}
+
+		m[fmt.Sprintf("_:%s%d", r.Kind, counts[r.Kind])] = attrs
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// provnQuote quotes a string literal for PROV-N.
+func provnQuote(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+	)
+
+	return `"` + r.Replace(s) + `"`
+}
+
+// provnAttrs returns the attributes in PROV-N.
+func provnAttrs(attrs []provAttr) string {
+	toks := make([]string, len(attrs))
+
+	for i, a := range attrs {
+		if a.QName {
+			toks[i] = fmt.Sprintf("%s='%s'", a.Key, a.Value)
+		} else {
+			toks[i] = fmt.Sprintf("%s=%s", a.Key, provnQuote(a.Value))
+		}
+	}
+
+	return "[" + strings.Join(toks, ", ") + "]"
+}
+
+// WriteProvN writes the document in PROV-N notation.
+func (d *ProvDocument) WriteProvN(w io.Writer) error {
+	fmt.Fprintln(w, "document")
+
+	for _, p := range d.Prefixes {
+		fmt.Fprintf(w, "  prefix %s <%s>\n", p[0], p[1])
+	}
+
+	if len(d.Entities) > 0 {
+		fmt.Fprintln(w, "")
+	}
+
+	for _, r := range d.Entities {
+		fmt.Fprintf(w, "  entity(%s, %s)\n", r.ID, provnAttrs(r.Attrs))
+	}
+
+	if len(d.Activities) > 0 {
+		fmt.Fprintln(w, "")
+	}
+
+	for _, r := range d.Activities {
+		fmt.Fprintf(w, "  activity(%s, -, -, %s)\n", r.ID, provnAttrs(r.Attrs))
+	}
+
+	if len(d.Agents) > 0 {
+		fmt.Fprintln(w, "")
+	}
+
+	for _, r := range d.Agents {
+		fmt.Fprintf(w, "  agent(%s, %s)\n", r.ID, provnAttrs(r.Attrs))
+	}
+
+	if len(d.Relations) > 0 {
+		fmt.Fprintln(w, "")
+	}
+
+	for _, r := range d.Relations {
+		args := make([]string, len(r.Args), len(r.Args)+r.Times+1)
+
+		for i, a := range r.Args {
+			args[i] = a.Value
+		}
+
+		for i := 0; i < r.Times; i++ {
+			args = append(args, "-")
+		}
+
+		if len(r.Attrs) > 0 {
+			args = append(args, provnAttrs(r.Attrs))
+		}
+
+		fmt.Fprintf(w, "  %s(%s)\n", r.Kind, strings.Join(args, ", "))
+	}
+
+	_, err := fmt.Fprintln(w, "endDocument")
+	return err
+}
+
+// provWriters are the PROV output formats.
+var provWriters = map[string]func(d *ProvDocument, w io.Writer) error{
+	"json":  (*ProvDocument).WriteJSON,
+	"provn": (*ProvDocument).WriteProvN,
+}
+
+func runProv(args []string) {
+	var (
+		opts      modelOptions
+		format    string
+		output    string
+		namespace string
+	)
+
+	fs := flag.NewFlagSet("pedsnet-etlprov prov", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: pedsnet-etlprov prov [options] <dir|file>\n\n")
+		fmt.Fprintf(os.Stderr, "Writes the provenance in <dir|file> in the W3C PROV data model.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+
+	opts.register(fs)
+	fs.StringVar(&format, "format", "json", "Output format: json (PROV-JSON) or provn (PROV-N).")
+	fs.StringVar(&output, "o", "", "File to write the document to. Defaults to stdout.")
+	fs.StringVar(&namespace, "namespace", DefaultProvNamespace, "Namespace of the steps, tools, sources and people.")
+
+	fs.Parse(args)
+
+	write, ok := provWriters[format]
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown format '%s'\n", format)
+		os.Exit(1)
+	}
+
+	dir := "."
+
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	p := newParser(&opts, dir)
+
+	// Errors are printed, but the document is written with what was parsed.
+	readPath(p, dir, 10)
+
+	// Tables and fields are identified by the model revision in the
+	// data models service.
+	modelNamespace := fmt.Sprintf("%s/models/%s/%s/", strings.TrimRight(opts.service, "/"), p.Model.Name, p.Model.Version)
+
+	doc := BuildProv(p, namespace, modelNamespace)
+
+	var w io.Writer = os.Stdout
+
+	if output != "" {
+		f, err := os.Create(output)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		defer f.Close()
+		w = f
+	}
+
+	if err := write(doc, w); err != nil {
+		fmt.Fprintf(os.Stderr, "Problem writing document\n> %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestProvJSON(t *testing.T) {
+	p := parseTestData(t)
+
+	// The person in the test data is not attributed to any steps.
+	p.people["Jane Doe"] = &Person{
+		Name:  "Jane Doe",
+		Role:  "ETL Developer",
+		Steps: []*Step{p.steps[1]},
+	}
+
+	doc := BuildProv(p, DefaultProvNamespace, "http://data-models.origins.link/models/pedsnet/2.0.0/")
+
+	var buf bytes.Buffer
+
+	if err := doc.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var raw map[string]json.RawMessage
+
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records and relations by kind and identifier.
+	out := make(map[string]map[string]map[string]interface{})
+
+	for k, v := range raw {
+		if k == "prefix" {
+			continue
+		}
+
+		var m map[string]map[string]interface{}
+
+		if err := json.Unmarshal(v, &m); err != nil {
+			t.Fatalf("%s: %s", k, err)
+		}
+
+		out[k] = m
+	}
+
+	if n := len(out["activity"]); n != len(p.Steps()) {
+		t.Errorf("expected %d activities, got %d", len(p.Steps()), n)
+	}
+
+	if _, ok := out["entity"]["pedsnet:person.person_id"]; !ok {
+		t.Error("expected pedsnet:person.person_id entity")
+	}
+
+	if _, ok := out["agent"]["etl:person-Evanette_Burrows"]; !ok {
+		t.Error("expected agent for the person")
+	}
+
+	var informed bool
+
+	for _, r := range out["wasInformedBy"] {
+		if r["prov:informed"] == "etl:step-2" && r["prov:informant"] == "etl:step-1" {
+			informed = true
+		}
+	}
+
+	if !informed {
+		t.Error("expected step 2 to be informed by step 1")
+	}
+
+	var associated bool
+
+	for _, r := range out["wasAssociatedWith"] {
+		if r["prov:activity"] == "etl:step-1" && r["prov:agent"] == "etl:person-Jane_Doe" && r["prov:role"] == "ETL Developer" {
+			associated = true
+		}
+	}
+
+	if !associated {
+		t.Error("expected step 1 to be associated with Jane Doe")
+	}
+
+	for _, kind := range []string{"used", "wasGeneratedBy"} {
+		if len(out[kind]) == 0 {
+			t.Errorf("expected %s relations", kind)
+		}
+	}
+}
+
+func TestProvN(t *testing.T) {
+	p := parseTestData(t)
+
+	doc := BuildProv(p, DefaultProvNamespace, "http://data-models.origins.link/models/pedsnet/2.0.0/")
+
+	var buf bytes.Buffer
+
+	if err := doc.WriteProvN(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+
+	for _, exp := range []string{
+		"document\n",
+		"  prefix etl <urn:pedsnet:etl:>\n",
+		"  activity(etl:step-1, -, -, [prov:type='etl:Step', prov:label=\"Step 1\"",
+		"  wasInformedBy(etl:step-2, etl:step-1)\n",
+		"  wasGeneratedBy(pedsnet:person, etl:step-1, -)\n",
+		"  used(etl:step-1, etl:source-EPIC_Clarity, -)\n",
+		"endDocument\n",
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("expected output to contain %q", exp)
+		}
+	}
+
+	if q := provnQuote("a \"b\"\n"); q != `"a \"b\"\n"` {
+		t.Errorf("unexpected quoted string %s", q)
+	}
+}
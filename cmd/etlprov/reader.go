@@ -15,21 +15,46 @@ func trimSpace(slice []string) []string {
 	return slice
 }
 
+// ureader converts carriage returns to newlines. The newline of a CRLF
+// pair is dropped so lines are not doubled.
 type ureader struct {
 	r io.Reader
+
+	// The last byte read was a carriage return.
+	cr bool
 }
 
 func (c *ureader) Read(buf []byte) (int, error) {
-	n, err := c.r.Read(buf)
+	for {
+		n, err := c.r.Read(buf)
+
+		var m int
+
+		for _, b := range buf[:n] {
+			if c.cr {
+				c.cr = false
+
+				if b == '\n' {
+					continue
+				}
+			}
+
+			if b == '\r' {
+				b = '\n'
+				c.cr = true
+			}
 
-	// Replace carriage returns with newlines
-	for i, b := range buf {
-		if b == '\r' {
-			buf[i] = '\n'
+			buf[m] = b
+			m++
+		}
+
+		// Only a dropped newline was read.
+		if m == 0 && n > 0 && err == nil {
+			continue
 		}
-	}
 
-	return n, err
+		return m, err
+	}
 }
 
 func isEmpty(r []string) bool {
@@ -42,9 +67,10 @@ func isEmpty(r []string) bool {
 	return true
 }
 
-// ReadRows reads rows from an io.Reader and processes each with the function.
-func ReadRows(r io.Reader, f func([]string)) error {
-	cr := csv.NewReader(&ureader{r})
+// ReadRows reads rows from an io.Reader and processes each with the function
+// along with the line the row starts on.
+func ReadRows(r io.Reader, f func(int, []string)) error {
+	cr := csv.NewReader(&ureader{r: r})
 
 	cr.Comment = '#'
 	cr.LazyQuotes = true
@@ -76,7 +102,9 @@ func ReadRows(r io.Reader, f func([]string)) error {
 			continue
 		}
 
-		f(row)
+		line, _ := cr.FieldPos(0)
+
+		f(line, row)
 	}
 
 	return nil
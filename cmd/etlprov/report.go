@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Counts are the number of items parsed from the files.
+type Counts struct {
+	Entities int `json:"entities"`
+	Steps    int `json:"steps"`
+	Tools    int `json:"tools"`
+	Sources  int `json:"sources"`
+	People   int `json:"people"`
+}
+
+// Report is the result of validating the provenance files in a directory.
+type Report struct {
+	Model   string             `json:"model"`
+	Version string             `json:"version"`
+	Dir     string             `json:"dir"`
+	Valid   bool               `json:"valid"`
+	Files   []string           `json:"files"`
+	Counts  Counts             `json:"counts"`
+	Errors  []*ValidationError `json:"errors"`
+}
+
+// NewReport initializes an empty report.
+func NewReport(model, version, dir string) *Report {
+	return &Report{
+		Model:   model,
+		Version: version,
+		Dir:     dir,
+		Valid:   true,
+		Files:   []string{},
+		Errors:  []*ValidationError{},
+	}
+}
+
+// Add adds errors to the report.
+func (r *Report) Add(errs ...*ValidationError) {
+	for _, e := range errs {
+		if e.Severity == SeverityError {
+			r.Valid = false
+		}
+
+		r.Errors = append(r.Errors, e)
+	}
+}
+
+// Handle implements the ErrorHandler. Errors that are not validation errors
+// are attributed to the file.
+func (r *Report) Handle(name string, errs []error) {
+	seen := false
+
+	for _, f := range r.Files {
+		if f == name {
+			seen = true
+			break
+		}
+	}
+
+	if !seen {
+		r.Files = append(r.Files, name)
+	}
+
+	for _, err := range errs {
+		e := asValidationError(err)
+
+		if e.File == "" {
+			e.File = name
+		}
+
+		r.Add(e)
+	}
+}
+
+// Count sets the number of items parsed by the parser.
+func (r *Report) Count(p *Parser) {
+	r.Counts = Counts{
+		Entities: len(p.entities),
+		Steps:    len(p.steps),
+		Tools:    len(p.tools),
+		Sources:  len(p.sources),
+		People:   len(p.people),
+	}
+}
+
+// group is the errors of a file with the same code.
+type group struct {
+	File   string
+	Code   string
+	Errors []*ValidationError
+}
+
+// groups returns the errors grouped by file and code in the order they
+// were added.
+func (r *Report) groups() []*group {
+	var groups []*group
+
+	index := make(map[[2]string]*group)
+
+	for _, e := range r.Errors {
+		k := [2]string{e.File, e.Code}
+
+		g, ok := index[k]
+
+		if !ok {
+			g = &group{File: e.File, Code: e.Code}
+			index[k] = g
+			groups = append(groups, g)
+		}
+
+		g.Errors = append(g.Errors, e)
+	}
+
+	return groups
+}
+
+// WriteText writes the errors grouped by file and code. Each group is
+// truncated to limit errors unless limit is zero.
+func (r *Report) WriteText(w io.Writer, limit int) error {
+	for _, g := range r.groups() {
+		fmt.Fprintln(w, "---")
+
+		severity := g.Errors[0].Severity
+
+		if len(g.Errors) == 1 {
+			fmt.Fprintf(w, "1 %s [%s] has been detected for '%s'\n", severity, g.Code, g.File)
+		} else {
+			fmt.Fprintf(w, "%d %ss [%s] have been detected for '%s'\n", len(g.Errors), severity, g.Code, g.File)
+		}
+
+		errs := make([]error, len(g.Errors))
+
+		for i, e := range g.Errors {
+			errs[i] = e
+		}
+
+		printErrors(w, errs, limit)
+	}
+
+	return nil
+}
+
+// WriteJSON writes the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+// WriteJUnit writes the report as JUnit XML for CI systems. Each file read
+// is a test suite with a failed test case per error and a skipped test case
+// per warning. Files without errors have a single passing test case.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	doc := junitSuites{
+		Name: "pedsnet-etlprov",
+	}
+
+	index := make(map[string]int, len(r.Files))
+
+	for i, f := range r.Files {
+		index[f] = i
+		doc.Suites = append(doc.Suites, junitSuite{Name: f})
+	}
+
+	for _, e := range r.Errors {
+		i, ok := index[e.File]
+
+		if !ok {
+			i = len(doc.Suites)
+			index[e.File] = i
+			doc.Suites = append(doc.Suites, junitSuite{Name: e.File})
+		}
+
+		s := &doc.Suites[i]
+
+		name := e.Code
+
+		if e.Line > 0 {
+			name = fmt.Sprintf("%s (line %d)", e.Code, e.Line)
+		}
+
+		c := junitCase{
+			Name:      name,
+			Classname: e.File,
+		}
+
+		m := &junitMessage{
+			Message: e.Message,
+			Type:    e.Code,
+			Text:    e.Error(),
+		}
+
+		if e.Severity == SeverityError {
+			c.Failure = m
+			s.Failures++
+		} else {
+			c.Skipped = m
+			s.Skipped++
+		}
+
+		s.Cases = append(s.Cases, c)
+	}
+
+	for i := range doc.Suites {
+		s := &doc.Suites[i]
+
+		if len(s.Cases) == 0 {
+			s.Cases = append(s.Cases, junitCase{
+				Name:      "valid",
+				Classname: s.Name,
+			})
+		}
+
+		s.Tests = len(s.Cases)
+		doc.Tests += s.Tests
+		doc.Failures += s.Failures
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadEntitiesErrors(t *testing.T) {
+	p := NewParser(model)
+
+	data := strings.Join([]string{
+		"entity,availability,transmitting,comment,truncation,limit",
+		"person.person_id,available,yes,,,",
+		"person.gender_concept_id,maybe,yes,,,",
+		"person.person_id,available,yes,,,",
+	}, "\n")
+
+	errs := p.ReadEntities(strings.NewReader(data))
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(errs))
+	}
+
+	e, ok := errs[0].(*ValidationError)
+
+	if !ok {
+		t.Fatalf("expected validation error, got %T", errs[0])
+	}
+
+	if e.File != EntitiesFile || e.Line != 3 || e.Column != "availability" || e.Code != InvalidValueCode {
+		t.Errorf("unexpected error %+v", e)
+	}
+
+	e = errs[1].(*ValidationError)
+
+	if e.Line != 4 || e.Code != DuplicateCode {
+		t.Errorf("unexpected error %+v", e)
+	}
+}
+
+func TestReadEntitiesCRLF(t *testing.T) {
+	p := NewParser(model)
+
+	data := strings.Join([]string{
+		"entity,availability,transmitting,comment,truncation,limit",
+		"person.person_id,maybe,yes,,,",
+		"person.gender_concept_id,maybe,yes,,,",
+		"person.race_concept_id,maybe,yes,,,",
+	}, "\r\n")
+
+	// A one byte reader splits each CRLF pair across reads.
+	for _, r := range []io.Reader{strings.NewReader(data), iotest.OneByteReader(strings.NewReader(data))} {
+		errs := p.ReadEntities(r)
+
+		var lines []int
+
+		for _, err := range errs {
+			lines = append(lines, err.(*ValidationError).Line)
+		}
+
+		if len(lines) != 3 || lines[0] != 2 || lines[1] != 3 || lines[2] != 4 {
+			t.Errorf("expected errors on lines 2, 3 and 4, got %v", lines)
+		}
+
+		p = NewParser(model)
+	}
+}
+
+func TestReport(t *testing.T) {
+	r := NewReport("pedsnet", "2.0.0", "test_data")
+
+	for _, name := range []string{EntitiesFile, StepsFile, ToolsFile, SourcesFile, PeopleFile} {
+		r.Handle(name, nil)
+	}
+
+	r.Add(&ValidationError{
+		File:     StepsFile,
+		Severity: SeverityWarning,
+		Code:     StepWithoutToolCheck,
+		Message:  "Step 1: step does not have a tool",
+	})
+
+	if !r.Valid {
+		t.Error("expected warnings to be valid")
+	}
+
+	r.Handle(ToolsFile, []error{newError("name", RequiredValueCode, "Tool name required")})
+
+	if r.Valid {
+		t.Error("expected errors to be invalid")
+	}
+
+	if r.Errors[1].File != ToolsFile {
+		t.Errorf("expected file to be set, got %s", r.Errors[1].File)
+	}
+
+	var buf bytes.Buffer
+
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var out Report
+
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Valid || len(out.Errors) != 2 {
+		t.Errorf("unexpected report %+v", out)
+	}
+
+	buf.Reset()
+
+	if err := r.WriteJUnit(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc junitSuites
+
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// One case per error and a passing case for the other three files.
+	if doc.Tests != 5 || doc.Failures != 1 {
+		t.Errorf("expected 5 tests and 1 failure, got %d and %d", doc.Tests, doc.Failures)
+	}
+}
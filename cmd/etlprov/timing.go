@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StepTiming is the duration of a step.
+type StepTiming struct {
+	ID          int           `json:"id"`
+	Description string        `json:"description"`
+	Duration    time.Duration `json:"-"`
+	Seconds     float64       `json:"seconds"`
+}
+
+// ChainTiming is the total duration of a chain of steps from a step without
+// a previous step to a step that is not the previous step of another step.
+// Steps without a time do not count towards the total.
+type ChainTiming struct {
+	Steps    []int         `json:"steps"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"seconds"`
+	Unknown  int           `json:"unknown"`
+
+	// Longest step of the chain, if any step has a time.
+	Longest *StepTiming `json:"longest,omitempty"`
+}
+
+// Timing is the timing of the steps of the pipeline.
+type Timing struct {
+	Chains  []*ChainTiming `json:"chains"`
+	Longest []*StepTiming  `json:"longest"`
+}
+
+func newStepTiming(s *Step) *StepTiming {
+	return &StepTiming{
+		ID:          s.ID,
+		Description: s.Description,
+		Duration:    s.Duration,
+		Seconds:     s.Duration.Seconds(),
+	}
+}
+
+// BuildTiming computes the total duration of each chain of steps, sorted by
+// the longest first, and the top longest steps.
+func BuildTiming(p *Parser, top int) *Timing {
+	steps := p.Steps()
+
+	hasNext := make(map[*Step]struct{})
+
+	for _, s := range steps {
+		if s.PreviousStep != nil {
+			hasNext[s.PreviousStep] = struct{}{}
+		}
+	}
+
+	t := &Timing{
+		Chains:  []*ChainTiming{},
+		Longest: []*StepTiming{},
+	}
+
+	for _, s := range steps {
+		if _, ok := hasNext[s]; ok {
+			continue
+		}
+
+		c := &ChainTiming{}
+
+		for x := s; x != nil; x = x.PreviousStep {
+			c.Steps = append(c.Steps, x.ID)
+
+			if x.Duration == 0 {
+				c.Unknown++
+				continue
+			}
+
+			c.Duration += x.Duration
+
+			if c.Longest == nil || x.Duration > c.Longest.Duration {
+				c.Longest = newStepTiming(x)
+			}
+		}
+
+		// Steps are collected from the end of the chain.
+		for i, j := 0, len(c.Steps)-1; i < j; i, j = i+1, j-1 {
+			c.Steps[i], c.Steps[j] = c.Steps[j], c.Steps[i]
+		}
+
+		c.Seconds = c.Duration.Seconds()
+		t.Chains = append(t.Chains, c)
+	}
+
+	sort.SliceStable(t.Chains, func(i, j int) bool {
+		return t.Chains[i].Duration > t.Chains[j].Duration
+	})
+
+	for _, s := range steps {
+		if s.Duration > 0 {
+			t.Longest = append(t.Longest, newStepTiming(s))
+		}
+	}
+
+	sort.SliceStable(t.Longest, func(i, j int) bool {
+		return t.Longest[i].Duration > t.Longest[j].Duration
+	})
+
+	if top > 0 && len(t.Longest) > top {
+		t.Longest = t.Longest[:top]
+	}
+
+	return t
+}
+
+// WriteJSON writes the timing as JSON with durations in seconds.
+func (t *Timing) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+// WriteText writes the total duration of each chain with its longest step
+// followed by the longest steps of the pipeline.
+func (t *Timing) WriteText(w io.Writer) error {
+	fmt.Fprintln(w, "Chains")
+
+	for _, c := range t.Chains {
+		ids := make([]string, len(c.Steps))
+
+		for i, id := range c.Steps {
+			ids[i] = fmt.Sprint(id)
+		}
+
+		fmt.Fprintf(w, "* %s: %s", strings.Join(ids, " > "), c.Duration)
+
+		if c.Longest != nil {
+			fmt.Fprintf(w, ", longest step %d (%s)", c.Longest.ID, c.Longest.Duration)
+		}
+
+		if c.Unknown > 0 {
+			fmt.Fprintf(w, ", %d without time", c.Unknown)
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "---")
+	fmt.Fprintln(w, "Longest steps")
+
+	if len(t.Longest) == 0 {
+		_, err := fmt.Fprintln(w, "No steps have a time")
+		return err
+	}
+
+	for _, s := range t.Longest {
+		fmt.Fprintf(w, "* Step %d: %s %s\n", s.ID, s.Duration, s.Description)
+	}
+
+	return nil
+}
+
+// timingWriters are the output formats of the timing.
+var timingWriters = map[string]func(t *Timing, w io.Writer) error{
+	"text": (*Timing).WriteText,
+	"json": (*Timing).WriteJSON,
+}
+
+func runTiming(args []string) {
+	var (
+		opts   modelOptions
+		format string
+		top    int
+	)
+
+	fs := flag.NewFlagSet("pedsnet-etlprov timing", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: pedsnet-etlprov timing [options] <dir|file>\n\n")
+		fmt.Fprintf(os.Stderr, "Computes the total time of each chain of steps in <dir|file>.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+
+	opts.register(fs)
+	fs.StringVar(&format, "format", "text", "Output format: text or json.")
+	fs.IntVar(&top, "top", 10, "Number of longest steps to show. Zero shows all.")
+
+	fs.Parse(args)
+
+	write, ok := timingWriters[format]
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown format '%s'\n", format)
+		os.Exit(1)
+	}
+
+	dir := "."
+
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	p := newParser(&opts, dir)
+
+	// Errors are printed, but the timing is computed with what was parsed.
+	readPath(p, dir, 10)
+
+	if err := write(BuildTiming(p, top), os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Problem writing timing\n> %s\n", err)
+		os.Exit(1)
+	}
+}
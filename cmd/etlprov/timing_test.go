@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStepTime(t *testing.T) {
+	valid := map[string]time.Duration{
+		"45m":                               45 * time.Minute,
+		"1h30m":                             90 * time.Minute,
+		"2016-03-01 08:00/2016-03-01 08:45": 45 * time.Minute,
+		"2016-03-01T08:00:00Z / 2016-03-01T10:00:00Z": 2 * time.Hour,
+	}
+
+	for v, d := range valid {
+		_, _, x, err := parseStepTime(v)
+
+		if err != nil {
+			t.Errorf("%s: %s", v, err)
+		} else if x != d {
+			t.Errorf("%s: expected %s, got %s", v, d, x)
+		}
+	}
+
+	for _, v := range []string{"45 minutes", "-5m", "2016-03-01 08:45/2016-03-01 08:00", "yesterday/today"} {
+		_, _, _, err := parseStepTime(v)
+
+		if err == nil {
+			t.Errorf("%s: expected error", v)
+		} else if e := err.(*ValidationError); e.Column != "time" {
+			t.Errorf("%s: expected time column, got %s", v, e.Column)
+		}
+	}
+}
+
+func TestBuildTiming(t *testing.T) {
+	p := NewParser(model)
+
+	s1 := &Step{ID: 1, Duration: 10 * time.Minute}
+	s2 := &Step{ID: 2, Duration: time.Hour, PreviousStep: s1}
+	s3 := &Step{ID: 3, PreviousStep: s2}
+	s4 := &Step{ID: 4, Duration: 5 * time.Minute, PreviousStep: s1}
+
+	for _, s := range []*Step{s1, s2, s3, s4} {
+		p.steps[s.ID] = s
+	}
+
+	tm := BuildTiming(p, 2)
+
+	if len(tm.Chains) != 2 {
+		t.Fatalf("expected 2 chains, got %d", len(tm.Chains))
+	}
+
+	c := tm.Chains[0]
+
+	if c.Duration != 70*time.Minute || c.Unknown != 1 || c.Longest.ID != 2 {
+		t.Errorf("unexpected chain %+v", c)
+	}
+
+	if len(c.Steps) != 3 || c.Steps[0] != 1 || c.Steps[2] != 3 {
+		t.Errorf("expected steps 1 to 3, got %v", c.Steps)
+	}
+
+	if len(tm.Longest) != 2 || tm.Longest[0].ID != 2 || tm.Longest[1].ID != 1 {
+		t.Errorf("unexpected longest steps %+v", tm.Longest)
+	}
+
+	var buf bytes.Buffer
+
+	if err := tm.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "* 1 > 2 > 3: 1h10m0s, longest step 2 (1h0m0s), 1 without time") {
+		t.Errorf("unexpected text output:\n%s", buf.String())
+	}
+}
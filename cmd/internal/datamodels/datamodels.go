@@ -0,0 +1,261 @@
+// Package datamodels provides model revisions from the data models service
+// with a local cache. Revisions are stored as the JSON returned by the
+// service, so they can be decoded by any version of the service client.
+package datamodels
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	dms "github.com/chop-dbhi/data-models-service/client"
+)
+
+// DefaultCacheDir returns the default directory model revisions are cached in.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "pedsnet", "models")
+}
+
+// Revision identifies a revision of a model.
+type Revision struct {
+	Name    string
+	Version string
+}
+
+func (r Revision) String() string {
+	return fmt.Sprintf("%s/%s", r.Name, r.Version)
+}
+
+// ParseRevision parses a revision of the form <model>/<version>.
+func ParseRevision(s string) (Revision, error) {
+	toks := strings.SplitN(s, "/", 2)
+
+	if len(toks) != 2 || toks[0] == "" || toks[1] == "" {
+		return Revision{}, fmt.Errorf("Invalid model revision '%s'. The format is <model>/<version>.", s)
+	}
+
+	return Revision{Name: toks[0], Version: toks[1]}, nil
+}
+
+// Provider provides model revisions from a local file, the service or the
+// cache.
+type Provider struct {
+	// File is a local JSON file of a model revision. If set, it is used
+	// instead of the service if it is the requested revision.
+	File string
+
+	// Service is the URL of the data models service.
+	Service string
+
+	// CacheDir is the directory of cached revisions. Revisions fetched from
+	// the service are written to it.
+	CacheDir string
+
+	// Offline only reads revisions from the cache.
+	Offline bool
+
+	// Client used to make requests to the service.
+	Client *http.Client
+}
+
+func (p *Provider) cachePath(name, version string) string {
+	return filepath.Join(p.CacheDir, name, fmt.Sprintf("%s.json", version))
+}
+
+// Fetch fetches a model revision from the service.
+func (p *Provider) Fetch(name, version string) ([]byte, error) {
+	client := p.Client
+
+	if client == nil {
+		client = &http.Client{
+			Timeout: 30 * time.Second,
+		}
+	}
+
+	service := p.Service
+
+	if service == "" {
+		service = dms.DefaultServiceURL
+	}
+
+	url := fmt.Sprintf("%s/models/%s/%s", strings.TrimSuffix(service, "/"), name, version)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error fetching model '%s/%s': %s", name, version, resp.Status)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if !json.Valid(b) {
+		return nil, fmt.Errorf("Invalid JSON for model '%s/%s'", name, version)
+	}
+
+	return b, nil
+}
+
+// Pull fetches a model revision from the service and writes it to the cache.
+func (p *Provider) Pull(name, version string) ([]byte, error) {
+	b, err := p.Fetch(name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.CacheDir == "" {
+		return b, nil
+	}
+
+	path := p.cachePath(name, version)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Get returns the JSON of a model revision. If a file is set, it is read.
+// Otherwise the revision is fetched from the service and cached. If the
+// service cannot be reached, the cached revision is used.
+func (p *Provider) Get(name, version string) ([]byte, error) {
+	if p.File != "" {
+		return p.readFile(name, version)
+	}
+
+	if p.Offline {
+		return p.cached(name, version)
+	}
+
+	b, err := p.Pull(name, version)
+	if err == nil {
+		return b, nil
+	}
+
+	if b, cerr := p.cached(name, version); cerr == nil {
+		return b, nil
+	}
+
+	return nil, err
+}
+
+// readFile reads the file of the provider and checks it is the requested
+// revision. An empty name or version matches any.
+func (p *Provider) readFile(name, version string) ([]byte, error) {
+	b, err := ioutil.ReadFile(p.File)
+	if err != nil {
+		return nil, err
+	}
+
+	var r Revision
+
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("Error decoding model file '%s': %s", p.File, err)
+	}
+
+	if (name != "" && r.Name != name) || (version != "" && r.Version != version) {
+		return nil, fmt.Errorf("Model file '%s' is for '%s', not '%s/%s'", p.File, r, name, version)
+	}
+
+	return b, nil
+}
+
+func (p *Provider) cached(name, version string) ([]byte, error) {
+	if p.CacheDir == "" {
+		return nil, fmt.Errorf("Model '%s/%s' is not cached", name, version)
+	}
+
+	b, err := ioutil.ReadFile(p.cachePath(name, version))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("Model '%s/%s' is not cached", name, version)
+	}
+
+	return b, err
+}
+
+// Decode gets a model revision and decodes it into v.
+func (p *Provider) Decode(name, version string, v interface{}) error {
+	b, err := p.Get(name, version)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("Error decoding model '%s/%s': %s", name, version, err)
+	}
+
+	return nil
+}
+
+// Cached returns the revisions in the cache.
+func (p *Provider) Cached() ([]Revision, error) {
+	var revs []Revision
+
+	if p.CacheDir == "" {
+		return revs, nil
+	}
+
+	names, err := ioutil.ReadDir(p.CacheDir)
+	if os.IsNotExist(err) {
+		return revs, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, n := range names {
+		if !n.IsDir() {
+			continue
+		}
+
+		fis, err := ioutil.ReadDir(filepath.Join(p.CacheDir, n.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fi := range fis {
+			if filepath.Ext(fi.Name()) != ".json" {
+				continue
+			}
+
+			revs = append(revs, Revision{
+				Name:    n.Name(),
+				Version: strings.TrimSuffix(fi.Name(), ".json"),
+			})
+		}
+	}
+
+	sort.Slice(revs, func(i, j int) bool {
+		return revs[i].String() < revs[j].String()
+	})
+
+	return revs, nil
+}
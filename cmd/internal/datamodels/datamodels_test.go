@@ -0,0 +1,125 @@
+package datamodels
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testModel = `{"name": "pedsnet", "version": "2.3.0", "tables": []}`
+
+func TestProviderFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "datamodels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/pedsnet/2.3.0" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte(testModel))
+	}))
+
+	p := &Provider{
+		Service:  ts.URL,
+		CacheDir: dir,
+	}
+
+	var m struct {
+		Name    string
+		Version string
+	}
+
+	if err := p.Decode("pedsnet", "2.3.0", &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Name != "pedsnet" || m.Version != "2.3.0" {
+		t.Errorf("expected pedsnet/2.3.0, got %s/%s", m.Name, m.Version)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "pedsnet", "2.3.0.json")); err != nil {
+		t.Errorf("expected revision to be cached: %s", err)
+	}
+
+	// Service is unreachable.
+	ts.Close()
+
+	if _, err := p.Get("pedsnet", "2.3.0"); err != nil {
+		t.Errorf("expected cached revision, got %s", err)
+	}
+
+	if _, err := p.Get("pedsnet", "2.2.0"); err == nil {
+		t.Error("expected error for revision that is not cached")
+	}
+
+	p.Offline = true
+
+	if _, err := p.Get("pedsnet", "2.3.0"); err != nil {
+		t.Errorf("expected cached revision offline, got %s", err)
+	}
+
+	revs, err := p.Cached()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revs) != 1 || revs[0].String() != "pedsnet/2.3.0" {
+		t.Errorf("expected pedsnet/2.3.0 to be cached, got %v", revs)
+	}
+}
+
+func TestProviderFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "datamodels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString(testModel)
+	f.Close()
+
+	p := &Provider{
+		File:    f.Name(),
+		Service: "http://127.0.0.1:0",
+	}
+
+	b, err := p.Get("pedsnet", "2.3.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != testModel {
+		t.Errorf("expected file contents, got %s", b)
+	}
+
+	// Any version is accepted if none is requested.
+	if _, err := p.Get("pedsnet", ""); err != nil {
+		t.Errorf("expected file for any version, got %s", err)
+	}
+
+	for _, r := range []Revision{{"pedsnet", "2.2.0"}, {"i2b2", "2.3.0"}} {
+		if _, err := p.Get(r.Name, r.Version); err == nil {
+			t.Errorf("expected error for %s", r)
+		}
+	}
+}
+
+func TestParseRevision(t *testing.T) {
+	if r, err := ParseRevision("pedsnet/2.3.0"); err != nil || r.Name != "pedsnet" || r.Version != "2.3.0" {
+		t.Errorf("unexpected revision %v: %v", r, err)
+	}
+
+	for _, s := range []string{"pedsnet", "/2.3.0", "pedsnet/"} {
+		if _, err := ParseRevision(s); err == nil {
+			t.Errorf("expected error for '%s'", s)
+		}
+	}
+}